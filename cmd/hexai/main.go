@@ -19,6 +19,13 @@ func main() {
 		return
 	}
 
+	if args := flag.Args(); hexaicli.IsPromptsCommand(args) {
+		if err := hexaicli.RunPrompts(args, os.Stdout, os.Stderr); err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
 	if err := hexaicli.Run(context.Background(), flag.Args(), os.Stdin, os.Stdout, os.Stderr); err != nil {
 		os.Exit(1)
 	}
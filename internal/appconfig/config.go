@@ -18,15 +18,49 @@ type App struct {
 	ContextMode        string `json:"context_mode"`
 	ContextWindowLines int    `json:"context_window_lines"`
 	MaxContextTokens   int    `json:"max_context_tokens"`
-	LogPreviewLimit    int    `json:"log_preview_limit"`
+	LogPreviewLimit    *int   `json:"log_preview_limit"`
+	// Never read files from disk in the LSP (only open documents are used).
+	NoDiskIO *bool `json:"no_disk_io"`
 	// Single knob for LSP requests; if set, overrides hardcoded temps in LSP.
     CodingTemperature *float64 `json:"coding_temperature"`
     // Minimum identifier characters required for manual (TriggerKind=1) invoke
     // to proceed without structural triggers. 0 means always allow.
-    ManualInvokeMinPrefix int `json:"manual_invoke_min_prefix"`
+    ManualInvokeMinPrefix *int `json:"manual_invoke_min_prefix"`
 
 	TriggerCharacters []string `json:"trigger_characters"`
 	Provider          string   `json:"provider"`
+	// Optional secondary provider for hedged completion requests; empty disables hedging.
+	HedgeProvider string `json:"hedge_provider"`
+	// Delay before the hedged request is fired at the secondary provider.
+	HedgeDelayMs int `json:"hedge_delay_ms"`
+	// LLM explanations on textDocument/hover (nil keeps the default: off).
+	HoverEnabled *bool `json:"hover_enabled"`
+	// Minimum time without typing before a hover reaches the LLM.
+	HoverMinDelayMs int `json:"hover_min_delay_ms"`
+	// Languages (LSP language ids, e.g. "go") reviewed by the LLM on save; empty disables reviews.
+	ReviewLanguages []string `json:"review_languages"`
+	// Quiet period after a save before the review request is sent.
+	ReviewDebounceMs int `json:"review_debounce_ms"`
+	// Provider for workspace embeddings ("ollama" or "openai"); empty disables the index.
+	EmbeddingProvider string `json:"embedding_provider"`
+	EmbeddingModel    string `json:"embedding_model"`
+	// Number of similar workspace chunks added to prompts when the index is enabled; 0 adds none.
+	RetrievalTopK *int `json:"retrieval_top_k"`
+	// Also add similar chunks to completions, at the cost of one query embedding per request (nil keeps the default: off).
+	CompletionRetrieval *bool `json:"completion_retrieval"`
+	// Token budget for the transcript of a chat session; older turns are summarised.
+	ChatHistoryTokens int `json:"chat_history_tokens"`
+	// Tell the user via window/showMessage when an ignored file is kept out of a request.
+	IgnoreShowMessage *bool `json:"ignore_show_message"`
+	// Replace secrets in all outgoing text with placeholders (nil keeps the default: on).
+	RedactSecrets *bool `json:"redact_secrets"`
+	// Extra regular expressions for secrets; a capture group limits redaction to the group.
+	RedactPatterns []string `json:"redact_patterns"`
+	// Per-language overrides keyed by LSP language id (e.g. "go", "markdown").
+	Languages map[string]LanguageConfig `json:"languages"`
+	// Project directories whose .hexai.json may set provider, base URL and
+	// redaction keys; only read from the user config and env.
+	TrustedProjects []string `json:"trusted_projects"`
 
 	// Provider-specific options
 	OpenAIBaseURL string `json:"openai_base_url"`
@@ -43,31 +77,60 @@ type App struct {
 	CopilotTemperature *float64 `json:"copilot_temperature"`
 }
 
+// LanguageConfig overrides general settings for one language. Unset fields
+// keep the general setting.
+type LanguageConfig struct {
+	ContextMode       string   `json:"context_mode"`
+	TriggerCharacters []string `json:"trigger_characters"`
+	MaxTokens         int      `json:"max_tokens"`
+	// Overrides coding_temperature (explicit 0.0 allowed).
+	Temperature *float64 `json:"temperature"`
+	// Model for primary requests; hexai.switchModel still takes precedence.
+	Model string `json:"model"`
+}
+
 // Constructor: defaults for App (kept first among functions)
 func newDefaultConfig() App {
 	// Coding-friendly default temperature across providers
 	// Users can override per provider in config.json (including 0.0).
 	t := 0.2
+	previewLimit, minPrefix, topK := 100, 0, 4
 	return App{
 		MaxTokens:          4000,
 		ContextMode:        "always-full",
 		ContextWindowLines: 120,
 		MaxContextTokens:   4000,
-		LogPreviewLimit:    100,
+		LogPreviewLimit:    &previewLimit,
 		CodingTemperature:  &t,
 		OpenAITemperature:  &t,
 		OllamaTemperature:  &t,
         CopilotTemperature: &t,
-        ManualInvokeMinPrefix: 0,
+        ManualInvokeMinPrefix: &minPrefix,
+        HedgeDelayMs:       400,
+        HoverMinDelayMs:    300,
+        ReviewDebounceMs:   1500,
+        RetrievalTopK:      &topK,
+        ChatHistoryTokens:  6000,
     }
 }
 
 // Load reads configuration from a file and merges with defaults.
 // It respects the XDG Base Directory Specification.
 func Load(logger *log.Logger) App {
+    cfg, _ := LoadProject(logger, "", nil)
+    return cfg
+}
+
+// LoadProject is Load with the project config found from dir upward (see
+// FindProjectConfig) and then the editor settings (nil for none) merged
+// between the user config and env. Unless the project is listed in
+// trusted_projects, their sensitive keys are dropped. An empty dir skips
+// the project config.
+func LoadProject(logger *log.Logger, dir string, editor *App) (App, Project) {
     cfg := newDefaultConfig()
+    var proj Project
     if logger == nil {
-        return cfg // Return defaults if no logger is provided (e.g. in tests)
+        return cfg, proj // Return defaults if no logger is provided (e.g. in tests)
     }
 
     configPath, err := getConfigPath()
@@ -82,11 +145,41 @@ func Load(logger *log.Logger) App {
         // apply any environment overrides below.
     }
 
-    // Environment overrides (take precedence over file)
-    if envCfg := loadFromEnv(logger); envCfg != nil {
+    envCfg := loadFromEnv(logger)
+    trusted := cfg.TrustedProjects
+    if envCfg != nil && envCfg.TrustedProjects != nil {
+        trusted = envCfg.TrustedProjects
+    }
+    if proj.Path = FindProjectConfig(dir); proj.Path != "" {
+        if projCfg, err := loadFromFile(proj.Path, logger); err == nil && projCfg != nil {
+            projCfg.TrustedProjects = nil // a project cannot trust itself
+            proj.Trusted = isTrusted(projectDir(proj.Path), trusted)
+            if !proj.Trusted {
+                proj.Dropped = projCfg.dropSensitive(&cfg)
+            }
+            if len(proj.Dropped) > 0 {
+                logger.Printf("untrusted project config %s: ignoring %s", proj.Path, strings.Join(proj.Dropped, ", "))
+            }
+            cfg.mergeWith(projCfg)
+        }
+    }
+    if editor != nil {
+        settings := *editor
+        settings.TrustedProjects = nil
+        if dir == "" || !isTrusted(filepath.Clean(dir), trusted) {
+            proj.SettingsDropped = settings.dropSensitive(&cfg)
+        }
+        if len(proj.SettingsDropped) > 0 {
+            logger.Printf("untrusted editor settings: ignoring %s", strings.Join(proj.SettingsDropped, ", "))
+        }
+        cfg.mergeWith(&settings)
+    }
+
+    // Environment overrides (take precedence over files)
+    if envCfg != nil {
         cfg.mergeWith(envCfg)
     }
-    return cfg
+    return cfg, proj
 }
 
 // Private helpers
@@ -130,13 +223,16 @@ func (a *App) mergeBasics(other *App) {
 	if other.MaxContextTokens > 0 {
 		a.MaxContextTokens = other.MaxContextTokens
 	}
-	if other.LogPreviewLimit >= 0 {
+	if other.LogPreviewLimit != nil && *other.LogPreviewLimit >= 0 { // allow explicit 0
 		a.LogPreviewLimit = other.LogPreviewLimit
 	}
+	if other.NoDiskIO != nil {
+		a.NoDiskIO = other.NoDiskIO
+	}
     if other.CodingTemperature != nil { // allow explicit 0.0
         a.CodingTemperature = other.CodingTemperature
     }
-    if other.ManualInvokeMinPrefix >= 0 {
+    if other.ManualInvokeMinPrefix != nil && *other.ManualInvokeMinPrefix >= 0 { // allow explicit 0
         a.ManualInvokeMinPrefix = other.ManualInvokeMinPrefix
     }
 	if len(other.TriggerCharacters) > 0 {
@@ -145,6 +241,83 @@ func (a *App) mergeBasics(other *App) {
 	if s := strings.TrimSpace(other.Provider); s != "" {
 		a.Provider = s
 	}
+	if s := strings.TrimSpace(other.HedgeProvider); s != "" {
+		a.HedgeProvider = s
+	}
+	if other.HedgeDelayMs > 0 {
+		a.HedgeDelayMs = other.HedgeDelayMs
+	}
+	if other.HoverEnabled != nil { // allow explicit false
+		a.HoverEnabled = other.HoverEnabled
+	}
+	if other.HoverMinDelayMs > 0 {
+		a.HoverMinDelayMs = other.HoverMinDelayMs
+	}
+	if other.ReviewLanguages != nil { // allow an explicit empty list to disable
+		a.ReviewLanguages = slices.Clone(other.ReviewLanguages)
+	}
+	if other.ReviewDebounceMs > 0 {
+		a.ReviewDebounceMs = other.ReviewDebounceMs
+	}
+	if s := strings.TrimSpace(other.EmbeddingProvider); s != "" {
+		a.EmbeddingProvider = s
+	}
+	if s := strings.TrimSpace(other.EmbeddingModel); s != "" {
+		a.EmbeddingModel = s
+	}
+	if other.RetrievalTopK != nil && *other.RetrievalTopK >= 0 { // allow explicit 0
+		a.RetrievalTopK = other.RetrievalTopK
+	}
+	if other.CompletionRetrieval != nil { // allow explicit false
+		a.CompletionRetrieval = other.CompletionRetrieval
+	}
+	if other.ChatHistoryTokens > 0 {
+		a.ChatHistoryTokens = other.ChatHistoryTokens
+	}
+	if other.IgnoreShowMessage != nil { // allow explicit false
+		a.IgnoreShowMessage = other.IgnoreShowMessage
+	}
+	if other.RedactSecrets != nil { // allow explicit false
+		a.RedactSecrets = other.RedactSecrets
+	}
+	if len(other.RedactPatterns) > 0 {
+		a.RedactPatterns = slices.Clone(other.RedactPatterns)
+	}
+	a.mergeLanguages(other.Languages)
+	if other.TrustedProjects != nil {
+		a.TrustedProjects = slices.Clone(other.TrustedProjects)
+	}
+}
+
+// mergeLanguages merges per-language sections field by field, so a later
+// source can change one setting of a language and keep the others.
+func (a *App) mergeLanguages(other map[string]LanguageConfig) {
+	for lang, o := range other {
+		lang = strings.ToLower(strings.TrimSpace(lang))
+		if lang == "" {
+			continue
+		}
+		if a.Languages == nil {
+			a.Languages = make(map[string]LanguageConfig)
+		}
+		l := a.Languages[lang]
+		if s := strings.TrimSpace(o.ContextMode); s != "" {
+			l.ContextMode = s
+		}
+		if len(o.TriggerCharacters) > 0 {
+			l.TriggerCharacters = slices.Clone(o.TriggerCharacters)
+		}
+		if o.MaxTokens > 0 {
+			l.MaxTokens = o.MaxTokens
+		}
+		if o.Temperature != nil { // allow explicit 0.0
+			l.Temperature = o.Temperature
+		}
+		if s := strings.TrimSpace(o.Model); s != "" {
+			l.Model = s
+		}
+		a.Languages[lang] = l
+	}
 }
 
 // mergeProviderFields merges per-provider configuration.
@@ -179,17 +352,69 @@ func (a *App) mergeProviderFields(other *App) {
 }
 
 func getConfigPath() (string, error) {
-	var configPath string
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.json"), nil
+}
+
+// ConfigDir returns the Hexai configuration directory, honoring
+// XDG_CONFIG_HOME (usually ~/.config/hexai).
+func ConfigDir() (string, error) {
 	if xdgConfigHome := os.Getenv("XDG_CONFIG_HOME"); xdgConfigHome != "" {
-		configPath = filepath.Join(xdgConfigHome, "hexai", "config.json")
-	} else {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return "", fmt.Errorf("cannot find user home directory: %v", err)
-		}
-		configPath = filepath.Join(home, ".config", "hexai", "config.json")
+		return filepath.Join(xdgConfigHome, "hexai"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot find user home directory: %v", err)
 	}
-    return configPath, nil
+	return filepath.Join(home, ".config", "hexai"), nil
+}
+
+// PromptsDir returns the directory holding user prompt template overrides.
+func PromptsDir() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "prompts"), nil
+}
+
+// IgnorePath returns the user-wide ignore file (gitignore syntax) listing
+// files that are never sent to a provider.
+func IgnorePath() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ignore"), nil
+}
+
+// CacheDir returns the Hexai cache directory, honoring XDG_CACHE_HOME
+// (usually ~/.cache/hexai).
+func CacheDir() (string, error) {
+	if xdgCacheHome := os.Getenv("XDG_CACHE_HOME"); xdgCacheHome != "" {
+		return filepath.Join(xdgCacheHome, "hexai"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot find user home directory: %v", err)
+	}
+	return filepath.Join(home, ".cache", "hexai"), nil
+}
+
+// DataDir returns the Hexai data directory, honoring XDG_DATA_HOME
+// (usually ~/.local/share/hexai).
+func DataDir() (string, error) {
+	if xdgDataHome := os.Getenv("XDG_DATA_HOME"); xdgDataHome != "" {
+		return filepath.Join(xdgDataHome, "hexai"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot find user home directory: %v", err)
+	}
+	return filepath.Join(home, ".local", "share", "hexai"), nil
 }
 
 // --- Environment overrides ---
@@ -219,6 +444,27 @@ func loadFromEnv(logger *log.Logger) *App {
         }
         return &f, true
     }
+    parseBoolPtr := func(k string) (*bool, bool) {
+        v := getenv(k)
+        if v == "" { return nil, false }
+        b, err := strconv.ParseBool(v)
+        if err != nil {
+            if logger != nil { logger.Printf("invalid %s: %v", k, err) }
+            return nil, false
+        }
+        return &b, true
+    }
+    parseList := func(k string) ([]string, bool) {
+        v := getenv(k)
+        if v == "" { return nil, false }
+        var out []string
+        for _, p := range strings.Split(v, ",") {
+            if t := strings.TrimSpace(p); t != "" {
+                out = append(out, t)
+            }
+        }
+        return out, true
+    }
 
     if n, ok := parseInt("HEXAI_MAX_TOKENS"); ok {
         out.MaxTokens = n; any = true
@@ -233,27 +479,65 @@ func loadFromEnv(logger *log.Logger) *App {
         out.MaxContextTokens = n; any = true
     }
     if n, ok := parseInt("HEXAI_LOG_PREVIEW_LIMIT"); ok {
-        out.LogPreviewLimit = n; any = true
+        out.LogPreviewLimit = &n; any = true
+    }
+    if b, ok := parseBoolPtr("HEXAI_NO_DISK_IO"); ok {
+        out.NoDiskIO = b; any = true
     }
     if n, ok := parseInt("HEXAI_MANUAL_INVOKE_MIN_PREFIX"); ok {
-        out.ManualInvokeMinPrefix = n; any = true
+        out.ManualInvokeMinPrefix = &n; any = true
     }
     if f, ok := parseFloatPtr("HEXAI_CODING_TEMPERATURE"); ok {
         out.CodingTemperature = f; any = true
     }
-    if s := getenv("HEXAI_TRIGGER_CHARACTERS"); s != "" {
-        parts := strings.Split(s, ",")
-        out.TriggerCharacters = nil
-        for _, p := range parts {
-            if t := strings.TrimSpace(p); t != "" {
-                out.TriggerCharacters = append(out.TriggerCharacters, t)
-            }
-        }
-        any = true
+    if l, ok := parseList("HEXAI_TRIGGER_CHARACTERS"); ok {
+        out.TriggerCharacters = l; any = true
     }
     if s := getenv("HEXAI_PROVIDER"); s != "" {
         out.Provider = s; any = true
     }
+    if s := getenv("HEXAI_HEDGE_PROVIDER"); s != "" {
+        out.HedgeProvider = s; any = true
+    }
+    if n, ok := parseInt("HEXAI_HEDGE_DELAY_MS"); ok {
+        out.HedgeDelayMs = n; any = true
+    }
+    if b, ok := parseBoolPtr("HEXAI_HOVER_ENABLED"); ok {
+        out.HoverEnabled = b; any = true
+    }
+    if n, ok := parseInt("HEXAI_HOVER_MIN_DELAY_MS"); ok {
+        out.HoverMinDelayMs = n; any = true
+    }
+    if l, ok := parseList("HEXAI_REVIEW_LANGUAGES"); ok {
+        out.ReviewLanguages = l; any = true
+    }
+    if n, ok := parseInt("HEXAI_REVIEW_DEBOUNCE_MS"); ok {
+        out.ReviewDebounceMs = n; any = true
+    }
+    if s := getenv("HEXAI_EMBEDDING_PROVIDER"); s != "" {
+        out.EmbeddingProvider = s; any = true
+    }
+    if s := getenv("HEXAI_EMBEDDING_MODEL"); s != "" {
+        out.EmbeddingModel = s; any = true
+    }
+    if n, ok := parseInt("HEXAI_RETRIEVAL_TOP_K"); ok {
+        out.RetrievalTopK = &n; any = true
+    }
+    if b, ok := parseBoolPtr("HEXAI_COMPLETION_RETRIEVAL"); ok {
+        out.CompletionRetrieval = b; any = true
+    }
+    if n, ok := parseInt("HEXAI_CHAT_HISTORY_TOKENS"); ok {
+        out.ChatHistoryTokens = n; any = true
+    }
+    if b, ok := parseBoolPtr("HEXAI_IGNORE_SHOW_MESSAGE"); ok {
+        out.IgnoreShowMessage = b; any = true
+    }
+    if b, ok := parseBoolPtr("HEXAI_REDACT_SECRETS"); ok {
+        out.RedactSecrets = b; any = true
+    }
+    if l, ok := parseList("HEXAI_TRUSTED_PROJECTS"); ok {
+        out.TrustedProjects = l; any = true
+    }
 
     // Provider-specific
     if s := getenv("HEXAI_OPENAI_BASE_URL"); s != "" { out.OpenAIBaseURL = s; any = true }
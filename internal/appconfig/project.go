@@ -0,0 +1,149 @@
+// Summary: Project-local config (.hexai.json or .hexai/config.json) discovery and the trust allowlist guarding sensitive keys.
+package appconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ProjectFiles are the project config file names, relative to a project
+// directory, in order of preference.
+var ProjectFiles = []string{".hexai.json", filepath.Join(".hexai", "config.json")}
+
+// Project describes the project config applied by LoadProject.
+type Project struct {
+	Path    string   // config file; empty when none was found
+	Trusted bool     // the project directory is listed in trusted_projects
+	Dropped []string // sensitive keys ignored because the project is untrusted
+	// Sensitive editor settings ignored because the workspace is untrusted
+	SettingsDropped []string
+}
+
+// ParseSettings decodes settings pushed by the editor with
+// workspace/didChangeConfiguration: either an object with a "hexai" section
+// or the section itself, using the config.json keys. Empty or null
+// settings yield nil.
+func ParseSettings(raw []byte) (*App, error) {
+	raw = []byte(strings.TrimSpace(string(raw)))
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var sections map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &sections); err != nil {
+		return nil, fmt.Errorf("invalid settings: %v", err)
+	}
+	if section, ok := sections["hexai"]; ok {
+		raw = section
+	}
+	var out App
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, fmt.Errorf("invalid hexai settings: %v", err)
+	}
+	return &out, nil
+}
+
+// WatchedFiles returns the config files whose changes should trigger a
+// reload for the project in dir: the user config and the project config,
+// or the places a project config may be created when there is none yet.
+func WatchedFiles(dir string) []string {
+	var out []string
+	if path, err := getConfigPath(); err == nil {
+		out = append(out, path)
+	}
+	if path := FindProjectConfig(dir); path != "" {
+		return append(out, path)
+	}
+	if dir != "" {
+		for _, name := range ProjectFiles {
+			out = append(out, filepath.Join(dir, name))
+		}
+	}
+	return out
+}
+
+// FindProjectConfig returns the first project config file found in dir or
+// one of its parents, or "" when there is none. The user config directory
+// is never treated as a project.
+func FindProjectConfig(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	userDir, _ := ConfigDir()
+	for {
+		for _, name := range ProjectFiles {
+			path := filepath.Join(dir, name)
+			if fi, err := os.Stat(path); err == nil && !fi.IsDir() && filepath.Dir(path) != userDir {
+				return path
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// projectDir returns the project directory of a project config file.
+func projectDir(path string) string {
+	dir := filepath.Dir(path)
+	if filepath.Base(dir) == ".hexai" {
+		return filepath.Dir(dir)
+	}
+	return dir
+}
+
+// isTrusted reports whether dir is one of the trusted directories or below
+// one. A leading "~/" in a trusted entry is the home directory.
+func isTrusted(dir string, trusted []string) bool {
+	home, _ := os.UserHomeDir()
+	for _, t := range trusted {
+		t = strings.TrimSpace(t)
+		if strings.HasPrefix(t, "~/") && home != "" {
+			t = filepath.Join(home, t[2:])
+		}
+		if t == "" || !filepath.IsAbs(t) {
+			continue
+		}
+		rel, err := filepath.Rel(filepath.Clean(t), dir)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// dropSensitive clears the keys an untrusted project must not set, since
+// they could send code to another endpoint or weaken redaction or disk
+// isolation, and returns their names. Its redact_patterns are appended to
+// those of base rather than replacing them, and no_disk_io may only be
+// turned on.
+func (a *App) dropSensitive(base *App) []string {
+	var dropped []string
+	drop := func(name string, set bool, reset func()) {
+		if set {
+			dropped = append(dropped, name)
+			reset()
+		}
+	}
+	drop("provider", a.Provider != "", func() { a.Provider = "" })
+	drop("hedge_provider", a.HedgeProvider != "", func() { a.HedgeProvider = "" })
+	drop("embedding_provider", a.EmbeddingProvider != "", func() { a.EmbeddingProvider = "" })
+	drop("openai_base_url", a.OpenAIBaseURL != "", func() { a.OpenAIBaseURL = "" })
+	drop("ollama_base_url", a.OllamaBaseURL != "", func() { a.OllamaBaseURL = "" })
+	drop("copilot_base_url", a.CopilotBaseURL != "", func() { a.CopilotBaseURL = "" })
+	drop("redact_secrets", a.RedactSecrets != nil, func() { a.RedactSecrets = nil })
+	drop("no_disk_io", a.NoDiskIO != nil && !*a.NoDiskIO, func() { a.NoDiskIO = nil })
+	if len(a.RedactPatterns) > 0 {
+		a.RedactPatterns = append(slices.Clone(base.RedactPatterns), a.RedactPatterns...)
+	}
+	return dropped
+}
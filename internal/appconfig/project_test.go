@@ -0,0 +1,170 @@
+// Summary: Tests for project config discovery, merge order and the trust allowlist.
+package appconfig
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, text string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindProjectConfig_WalksUp(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, ".hexai", "config.json"), `{}`)
+	sub := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if got := FindProjectConfig(sub); got != filepath.Join(root, ".hexai", "config.json") {
+		t.Fatalf("unexpected project config %q", got)
+	}
+	writeFile(t, filepath.Join(root, "a", ".hexai.json"), `{}`)
+	if got := FindProjectConfig(sub); got != filepath.Join(root, "a", ".hexai.json") {
+		t.Fatalf("nearest project config should win, got %q", got)
+	}
+}
+
+func TestLoadProject_UntrustedDropsSensitiveKeys(t *testing.T) {
+	cfgHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", cfgHome)
+	t.Setenv("HEXAI_MAX_TOKENS", "")
+	root := t.TempDir()
+	writeFile(t, filepath.Join(cfgHome, "hexai", "config.json"), `{"max_tokens": 100, "openai_base_url": "https://user.example"}`)
+	writeFile(t, filepath.Join(root, ".hexai.json"),
+		`{"max_tokens": 200, "openai_base_url": "https://evil.example", "redact_secrets": false, "trusted_projects": ["/"]}`)
+	logger := log.New(io.Discard, "", 0)
+
+	cfg, proj := LoadProject(logger, root, nil)
+	if proj.Trusted || len(proj.Dropped) != 2 {
+		t.Fatalf("expected untrusted project with 2 dropped keys, got %+v", proj)
+	}
+	if cfg.MaxTokens != 200 || cfg.OpenAIBaseURL != "https://user.example" || cfg.RedactSecrets != nil {
+		t.Fatalf("unexpected config: max_tokens=%d base_url=%s redact=%v", cfg.MaxTokens, cfg.OpenAIBaseURL, cfg.RedactSecrets)
+	}
+
+	writeFile(t, filepath.Join(cfgHome, "hexai", "config.json"), `{"trusted_projects": ["`+root+`"]}`)
+	cfg, proj = LoadProject(logger, filepath.Join(root, "sub"), nil)
+	if !proj.Trusted || cfg.OpenAIBaseURL != "https://evil.example" {
+		t.Fatalf("trusted project should apply all keys: %+v base_url=%s", proj, cfg.OpenAIBaseURL)
+	}
+
+	t.Setenv("HEXAI_MAX_TOKENS", "300")
+	if cfg, _ = LoadProject(logger, root, nil); cfg.MaxTokens != 300 {
+		t.Fatalf("env should win over the project config, got %d", cfg.MaxTokens)
+	}
+}
+
+func TestLoadProject_EditorSettings(t *testing.T) {
+	cfgHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", cfgHome)
+	t.Setenv("HEXAI_MAX_TOKENS", "")
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, ".hexai.json"), `{"max_tokens": 200}`)
+	editor, err := ParseSettings([]byte(`{"hexai": {"max_tokens": 300, "provider": "openai"}, "other": {"x": 1}}`))
+	if err != nil || editor == nil {
+		t.Fatalf("ParseSettings: %v", err)
+	}
+	if none, err := ParseSettings([]byte(`null`)); none != nil || err != nil {
+		t.Fatalf("null settings should yield nil, got %v %v", none, err)
+	}
+	logger := log.New(io.Discard, "", 0)
+
+	cfg, proj := LoadProject(logger, root, editor)
+	if cfg.MaxTokens != 300 || cfg.Provider != "" || len(proj.SettingsDropped) != 1 {
+		t.Fatalf("editor settings should win over the project but drop provider: max_tokens=%d provider=%q %+v", cfg.MaxTokens, cfg.Provider, proj)
+	}
+	writeFile(t, filepath.Join(cfgHome, "hexai", "config.json"), `{"trusted_projects": ["`+root+`"]}`)
+	if cfg, _ = LoadProject(logger, root, editor); cfg.Provider != "openai" {
+		t.Fatalf("trusted workspace should accept the provider setting, got %q", cfg.Provider)
+	}
+}
+
+func TestLoadProject_UntrustedCannotWeakenRedactionOrDiskIO(t *testing.T) {
+	cfgHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", cfgHome)
+	root := t.TempDir()
+	writeFile(t, filepath.Join(cfgHome, "hexai", "config.json"), `{"redact_patterns": ["internal-[0-9]+"], "no_disk_io": true}`)
+	writeFile(t, filepath.Join(root, ".hexai.json"), `{"redact_patterns": ["acme-[a-z]+"], "no_disk_io": false}`)
+	logger := log.New(io.Discard, "", 0)
+
+	cfg, proj := LoadProject(logger, root, nil)
+	if len(proj.Dropped) != 1 || proj.Dropped[0] != "no_disk_io" {
+		t.Fatalf("expected no_disk_io to be dropped, got %+v", proj)
+	}
+	if cfg.NoDiskIO == nil || !*cfg.NoDiskIO {
+		t.Fatalf("an untrusted project must not turn off no_disk_io")
+	}
+	if len(cfg.RedactPatterns) != 2 || cfg.RedactPatterns[0] != "internal-[0-9]+" || cfg.RedactPatterns[1] != "acme-[a-z]+" {
+		t.Fatalf("untrusted redact_patterns should be appended, got %q", cfg.RedactPatterns)
+	}
+
+	editor := &App{RedactPatterns: []string{}, NoDiskIO: new(bool)}
+	if cfg, proj = LoadProject(logger, root, editor); !*cfg.NoDiskIO || len(cfg.RedactPatterns) != 2 || len(proj.SettingsDropped) != 1 {
+		t.Fatalf("untrusted editor settings must not weaken isolation: no_disk_io=%v patterns=%q %+v", *cfg.NoDiskIO, cfg.RedactPatterns, proj)
+	}
+
+	writeFile(t, filepath.Join(root, ".hexai.json"), `{"no_disk_io": true}`)
+	writeFile(t, filepath.Join(cfgHome, "hexai", "config.json"), `{}`)
+	if cfg, proj = LoadProject(logger, root, nil); len(proj.Dropped) != 0 || cfg.NoDiskIO == nil || !*cfg.NoDiskIO {
+		t.Fatalf("an untrusted project may turn on no_disk_io: %+v", proj)
+	}
+
+	writeFile(t, filepath.Join(cfgHome, "hexai", "config.json"), `{"trusted_projects": ["`+root+`"], "redact_patterns": ["internal-[0-9]+"]}`)
+	writeFile(t, filepath.Join(root, ".hexai.json"), `{"redact_patterns": ["acme-[a-z]+"]}`)
+	if cfg, _ = LoadProject(logger, root, nil); len(cfg.RedactPatterns) != 1 || cfg.RedactPatterns[0] != "acme-[a-z]+" {
+		t.Fatalf("a trusted project replaces redact_patterns, got %q", cfg.RedactPatterns)
+	}
+}
+
+func TestLoadProject_MissingIntKeysKeepLowerLayers(t *testing.T) {
+	cfgHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", cfgHome)
+	t.Setenv("HEXAI_LOG_PREVIEW_LIMIT", "")
+	t.Setenv("HEXAI_MANUAL_INVOKE_MIN_PREFIX", "")
+	root := t.TempDir()
+	writeFile(t, filepath.Join(cfgHome, "hexai", "config.json"), `{"log_preview_limit": 7, "manual_invoke_min_prefix": 2}`)
+	writeFile(t, filepath.Join(root, ".hexai.json"), `{"max_tokens": 200}`)
+	editor, err := ParseSettings([]byte(`{"hexai": {"max_tokens": 300}}`))
+	if err != nil {
+		t.Fatalf("ParseSettings: %v", err)
+	}
+	logger := log.New(io.Discard, "", 0)
+
+	cfg, _ := LoadProject(logger, root, editor)
+	if *cfg.LogPreviewLimit != 7 || *cfg.ManualInvokeMinPrefix != 2 {
+		t.Fatalf("layers without the keys must keep them: log_preview_limit=%d manual_invoke_min_prefix=%d", *cfg.LogPreviewLimit, *cfg.ManualInvokeMinPrefix)
+	}
+	writeFile(t, filepath.Join(root, ".hexai.json"), `{"log_preview_limit": 0, "manual_invoke_min_prefix": 0}`)
+	if cfg, _ = LoadProject(logger, root, nil); *cfg.LogPreviewLimit != 0 || *cfg.ManualInvokeMinPrefix != 0 {
+		t.Fatalf("an explicit 0 must apply: log_preview_limit=%d manual_invoke_min_prefix=%d", *cfg.LogPreviewLimit, *cfg.ManualInvokeMinPrefix)
+	}
+}
+
+func TestLoadProject_ExplicitZeroRetrievalTopK(t *testing.T) {
+	cfgHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", cfgHome)
+	t.Setenv("HEXAI_RETRIEVAL_TOP_K", "")
+	root := t.TempDir()
+	writeFile(t, filepath.Join(cfgHome, "hexai", "config.json"), `{"retrieval_top_k": 6}`)
+	logger := log.New(io.Discard, "", 0)
+
+	if cfg, _ := LoadProject(logger, root, nil); *cfg.RetrievalTopK != 6 || cfg.CompletionRetrieval != nil {
+		t.Fatalf("unexpected retrieval settings: top_k=%d completion_retrieval=%v", *cfg.RetrievalTopK, cfg.CompletionRetrieval)
+	}
+	writeFile(t, filepath.Join(root, ".hexai.json"), `{"retrieval_top_k": 0}`)
+	if cfg, _ := LoadProject(logger, root, nil); *cfg.RetrievalTopK != 0 {
+		t.Fatalf("an explicit 0 must disable retrieval, got %d", *cfg.RetrievalTopK)
+	}
+}
@@ -0,0 +1,48 @@
+// Summary: "hexai prompts dump" subcommand; writes the embedded default prompt templates for editing.
+package hexaicli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"hexai/internal/appconfig"
+	"hexai/internal/logging"
+	"hexai/internal/prompts"
+)
+
+// IsPromptsCommand reports whether args invoke the "prompts dump" subcommand
+// rather than a free-form prompt.
+func IsPromptsCommand(args []string) bool {
+	return len(args) >= 2 && args[0] == "prompts" && args[1] == "dump"
+}
+
+// RunPrompts implements "hexai prompts dump [-force] [DIR]". DIR defaults to
+// $XDG_CONFIG_HOME/hexai/prompts; existing files are kept unless -force is set.
+func RunPrompts(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("prompts dump", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	force := fs.Bool("force", false, "overwrite existing template files")
+	if err := fs.Parse(args[2:]); err != nil {
+		return err
+	}
+	dir := fs.Arg(0)
+	if dir == "" {
+		d, err := appconfig.PromptsDir()
+		if err != nil {
+			fmt.Fprintf(stderr, logging.AnsiBase+"hexai: %v"+logging.AnsiReset+"\n", err)
+			return err
+		}
+		dir = d
+	}
+	written, err := prompts.Dump(dir, *force)
+	for _, path := range written {
+		fmt.Fprintln(stdout, path)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, logging.AnsiBase+"hexai: error: %v"+logging.AnsiReset+"\n", err)
+		return err
+	}
+	fmt.Fprintf(stderr, logging.AnsiBase+"wrote %d of %d prompt templates to %s"+logging.AnsiReset+"\n", len(written), len(prompts.Names()), dir)
+	return nil
+}
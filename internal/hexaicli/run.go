@@ -15,33 +15,41 @@ import (
 	"hexai/internal/appconfig"
 	"hexai/internal/llm"
 	"hexai/internal/logging"
+	"hexai/internal/prompts"
+	"hexai/internal/redact"
 )
 
 // Run executes the Hexai CLI behavior given arguments and I/O streams.
 // It assumes flags have already been parsed by the caller.
 func Run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
     // Load configuration with a logger so file-based config is respected.
+    // The project config is searched from the current directory upward.
     logger := log.New(stderr, "hexai ", log.LstdFlags|log.Lmsgprefix)
-    cfg := appconfig.Load(logger)
+    wd, _ := os.Getwd()
+    cfg, _ := appconfig.LoadProject(logger, wd, nil)
     client, err := newClientFromConfig(cfg)
     if err != nil {
         fmt.Fprintf(stderr, logging.AnsiBase+"hexai: LLM disabled: %v"+logging.AnsiReset+"\n", err)
         return err
     }
 
-	return RunWithClient(ctx, args, stdin, stdout, stderr, client)
+	return runWithPrompts(ctx, args, stdin, stdout, stderr, client, loadPrompts())
 }
 
 // RunWithClient executes the CLI flow using an already-constructed client.
-// Useful for testing and embedding.
+// Useful for testing and embedding. The default prompts are used.
 func RunWithClient(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer, client llm.Client) error {
+	return runWithPrompts(ctx, args, stdin, stdout, stderr, client, nil)
+}
+
+func runWithPrompts(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer, client llm.Client, reg *prompts.Registry) error {
 	input, err := readInput(stdin, args)
 	if err != nil {
 		fmt.Fprintln(stderr, logging.AnsiBase+err.Error()+logging.AnsiReset)
 		return err
 	}
 	printProviderInfo(stderr, client)
-	msgs := buildMessages(input)
+	msgs := buildMessages(reg, input)
 	if err := runChat(ctx, client, msgs, input, stdout, stderr); err != nil {
 		fmt.Fprintf(stderr, logging.AnsiBase+"hexai: error: %v"+logging.AnsiReset+"\n", err)
 		return err
@@ -93,16 +101,39 @@ func newClientFromConfig(cfg appconfig.App) (llm.Client, error) {
     if strings.TrimSpace(cpKey) == "" {
         cpKey = os.Getenv("COPILOT_API_KEY")
     }
-    return llm.NewFromConfig(llmCfg, oaKey, cpKey)
+    client, err := llm.NewFromConfig(llmCfg, oaKey, cpKey)
+    if err != nil {
+        return nil, err
+    }
+    // Redact secrets from stdin and arguments unless redact_secrets is false.
+    if cfg.RedactSecrets != nil && !*cfg.RedactSecrets {
+        return client, nil
+    }
+    red, err := redact.New(cfg.RedactPatterns)
+    if err != nil {
+        return nil, err
+    }
+    return llm.WithRedaction(client, red), nil
+}
+
+// loadPrompts reads prompt overrides from the user prompts directory.
+func loadPrompts() *prompts.Registry {
+	dir, err := appconfig.PromptsDir()
+	if err != nil {
+		return prompts.Default()
+	}
+	return prompts.Load(dir)
 }
 
 // buildMessages creates system and user messages based on input content.
-func buildMessages(input string) []llm.Message {
+// A nil registry renders the default prompts.
+func buildMessages(reg *prompts.Registry, input string) []llm.Message {
 	lower := strings.ToLower(input)
-	system := "You are Hexai CLI. Default to very short, concise answers. If the user asks for commands, output only the commands (one per line) with no commentary or explanation. Only when the word 'explain' appears in the prompt, produce a verbose explanation."
+	name := prompts.CLISystem
 	if strings.Contains(lower, "explain") {
-		system = "You are Hexai CLI. The user requested an explanation. Provide a clear, verbose explanation with reasoning and details. If commands are needed, include them with brief context."
+		name = prompts.CLIExplainSystem
 	}
+	system := reg.Render(name, prompts.Data{Input: input})
 	return []llm.Message{
 		{Role: "system", Content: system},
 		{Role: "user", Content: input},
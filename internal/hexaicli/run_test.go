@@ -61,7 +61,7 @@ func TestReadInput_EmptyError(t *testing.T) {
 
 func TestBuildMessages_DefaultAndExplain(t *testing.T) {
 	// Default concise
-	msgs := buildMessages("list files in folder")
+	msgs := buildMessages(nil, "list files in folder")
 	if len(msgs) != 2 {
 		t.Fatalf("expected 2 messages, got %d", len(msgs))
 	}
@@ -76,7 +76,7 @@ func TestBuildMessages_DefaultAndExplain(t *testing.T) {
 	}
 
 	// Verbose explain
-	msgs2 := buildMessages("please explain how this works")
+	msgs2 := buildMessages(nil, "please explain how this works")
 	if len(msgs2) != 2 {
 		t.Fatalf("expected 2 messages, got %d", len(msgs2))
 	}
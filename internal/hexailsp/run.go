@@ -3,15 +3,21 @@
 package hexailsp
 
 import (
+	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"hexai/internal/appconfig"
 	"hexai/internal/llm"
 	"hexai/internal/logging"
 	"hexai/internal/lsp"
+	"hexai/internal/prompts"
+	"hexai/internal/redact"
 )
 
 // ServerRunner is the minimal interface satisfied by lsp.Server.
@@ -41,10 +47,17 @@ func Run(logPath string, stdin io.Reader, stdout io.Writer, stderr io.Writer) er
 // When factory is nil, lsp.NewServer is used.
 func RunWithFactory(logPath string, stdin io.Reader, stdout io.Writer, logger *log.Logger, cfg appconfig.App, client llm.Client, factory ServerFactory) error {
 	normalizeLoggingConfig(&cfg)
-	client = buildClientIfNil(cfg, client)
+	built, clientErr := buildClientIfNil(cfg, client)
 	factory = ensureFactory(factory)
 
-	opts := makeServerOptions(cfg, strings.TrimSpace(logPath) != "", client)
+	logContext := strings.TrimSpace(logPath) != ""
+	opts := makeServerOptions(cfg, logContext, built)
+	if clientErr != nil {
+		opts.ConfigError = "Hexai: LLM disabled: " + clientErr.Error()
+	}
+	// Only an injected client is kept on reload; a built one is rebuilt
+	opts.ReloadConfig = reloader(logger, logContext, client)
+	opts.ConfigFiles = appconfig.WatchedFiles("")
 	server := factory(stdin, stdout, logger, opts)
 	if err := server.Run(); err != nil {
 		logger.Fatalf("server error: %v", err)
@@ -56,17 +69,151 @@ func RunWithFactory(logPath string, stdin io.Reader, stdout io.Writer, logger *l
 
 func normalizeLoggingConfig(cfg *appconfig.App) {
 	cfg.ContextMode = strings.ToLower(strings.TrimSpace(cfg.ContextMode))
-	if cfg.LogPreviewLimit >= 0 {
-		logging.SetLogPreviewLimit(cfg.LogPreviewLimit)
+	if cfg.LogPreviewLimit != nil && *cfg.LogPreviewLimit >= 0 {
+		logging.SetLogPreviewLimit(*cfg.LogPreviewLimit)
 	}
 }
 
-func buildClientIfNil(cfg appconfig.App, client llm.Client) llm.Client {
+// buildClientIfNil builds the primary client unless one was injected. The
+// error tells the user why the LLM is disabled.
+func buildClientIfNil(cfg appconfig.App, client llm.Client) (llm.Client, error) {
 	if client != nil {
-		return client
+		return client, nil
+	}
+	c, err := newClient(cfg, cfg.Provider)
+	if err != nil {
+		logging.Logf("lsp ", "llm disabled: %v", err)
+		return nil, err
+	}
+	logging.Logf("lsp ", "llm enabled provider=%s model=%s", c.Name(), c.DefaultModel())
+	return c, nil
+}
+
+// reloader returns the hook used at initialize, by the hexai.reloadConfig
+// command and on settings or config file changes. It re-reads the user
+// config, the project config of root, the editor settings and env and
+// rebuilds the LLM clients; an injected client (tests) is kept as is.
+func reloader(logger *log.Logger, logContext bool, injected llm.Client) func(root string, settings json.RawMessage) (lsp.ServerOptions, error) {
+	return func(root string, settings json.RawMessage) (lsp.ServerOptions, error) {
+		editor, err := appconfig.ParseSettings(settings)
+		if err != nil {
+			return lsp.ServerOptions{}, err
+		}
+		cfg, proj := appconfig.LoadProject(logger, root, editor)
+		normalizeLoggingConfig(&cfg)
+		client := injected
+		if client == nil {
+			c, err := newClient(cfg, cfg.Provider)
+			if err != nil {
+				return lsp.ServerOptions{}, err
+			}
+			client = c
+		}
+		logging.Logf("lsp ", "config reloaded provider=%s model=%s project=%s", client.Name(), client.DefaultModel(), proj.Path)
+		opts := makeServerOptions(cfg, logContext, client)
+		opts.ConfigFiles = appconfig.WatchedFiles(root)
+		opts.Notice = projectNotice(proj)
+		return opts, nil
+	}
+}
+
+// projectNotice tells the user which keys of an untrusted project config or
+// of the editor settings were ignored and how to trust the project.
+func projectNotice(proj appconfig.Project) string {
+	var parts []string
+	if len(proj.Dropped) > 0 {
+		parts = append(parts, fmt.Sprintf("%s is not trusted, ignoring %s.", proj.Path, strings.Join(proj.Dropped, ", ")))
+	}
+	if len(proj.SettingsDropped) > 0 {
+		parts = append(parts, fmt.Sprintf("Workspace is not trusted, ignoring editor settings %s.", strings.Join(proj.SettingsDropped, ", ")))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "Hexai: " + strings.Join(parts, " ") + " Add the project directory to trusted_projects in your user config to allow them."
+}
+
+// buildHedgeClient builds the secondary client used for hedged completion
+// requests. It returns nil when hedging is not configured, and an error
+// when the provider cannot be built.
+func buildHedgeClient(cfg appconfig.App) (llm.Client, error) {
+	prov := strings.TrimSpace(cfg.HedgeProvider)
+	if prov == "" {
+		return nil, nil
+	}
+	c, err := newClient(cfg, prov)
+	if err != nil {
+		logging.Logf("lsp ", "llm hedge disabled: %v", err)
+		return nil, fmt.Errorf("hedged requests disabled: %v", err)
+	}
+	logging.Logf("lsp ", "llm hedge enabled provider=%s model=%s delay=%dms", c.Name(), c.DefaultModel(), cfg.HedgeDelayMs)
+	return c, nil
+}
+
+// buildEmbedder builds the embedding client for the workspace index. It
+// returns nil when no embedding provider is configured, and an error when
+// it cannot be built.
+func buildEmbedder(cfg appconfig.App) (llm.Embedder, error) {
+	prov := strings.ToLower(strings.TrimSpace(cfg.EmbeddingProvider))
+	if prov == "" {
+		return nil, nil
+	}
+	baseURL, key := cfg.OllamaBaseURL, ""
+	if prov == "openai" {
+		baseURL, key = cfg.OpenAIBaseURL, openAIKey()
+	}
+	e, err := llm.NewEmbedder(prov, baseURL, cfg.EmbeddingModel, key)
+	if err == nil {
+		var red *redact.Redactor
+		if red, err = buildRedactor(cfg); err == nil {
+			e = llm.RedactEmbedder(e, red)
+		}
+	}
+	if err != nil {
+		logging.Logf("lsp ", "embeddings disabled: %v", err)
+		return nil, fmt.Errorf("embeddings disabled: %v", err)
+	}
+	logging.Logf("lsp ", "embeddings enabled model=%s", e.EmbeddingModel())
+	return e, nil
+}
+
+// indexDir returns the directory for workspace index files, or "" to keep
+// indexes in memory when no cache directory is available.
+func indexDir() string {
+	dir, err := appconfig.CacheDir()
+	if err != nil {
+		logging.Logf("lsp ", "index: %v (not persisted)", err)
+		return ""
 	}
+	return dir
+}
+
+// chatDir returns the directory for chat sessions, or "" when no data
+// directory is available.
+func chatDir() string {
+	dir, err := appconfig.DataDir()
+	if err != nil {
+		logging.Logf("lsp ", "chat: %v (hexai.newChat disabled)", err)
+		return ""
+	}
+	return filepath.Join(dir, "chats")
+}
+
+// userIgnoreFile returns the user-wide ignore file, or "" when the config
+// directory cannot be resolved.
+func userIgnoreFile() string {
+	path, err := appconfig.IgnorePath()
+	if err != nil {
+		logging.Logf("lsp ", "ignore: %v (user ignore file not used)", err)
+		return ""
+	}
+	return path
+}
+
+// newClient builds an LLM client for the given provider from cfg and env keys.
+func newClient(cfg appconfig.App, provider string) (llm.Client, error) {
 	llmCfg := llm.Config{
-		Provider:           cfg.Provider,
+		Provider:           provider,
 		OpenAIBaseURL:      cfg.OpenAIBaseURL,
 		OpenAIModel:        cfg.OpenAIModel,
 		OpenAITemperature:  cfg.OpenAITemperature,
@@ -77,23 +224,50 @@ func buildClientIfNil(cfg appconfig.App, client llm.Client) llm.Client {
 		CopilotModel:       cfg.CopilotModel,
 		CopilotTemperature: cfg.CopilotTemperature,
 	}
-    // Prefer HEXAI_OPENAI_API_KEY; fall back to OPENAI_API_KEY
-    oaKey := os.Getenv("HEXAI_OPENAI_API_KEY")
-    if strings.TrimSpace(oaKey) == "" {
-        oaKey = os.Getenv("OPENAI_API_KEY")
-    }
+    oaKey := openAIKey()
     // Prefer HEXAI_COPILOT_API_KEY; fall back to COPILOT_API_KEY
     cpKey := os.Getenv("HEXAI_COPILOT_API_KEY")
     if strings.TrimSpace(cpKey) == "" {
         cpKey = os.Getenv("COPILOT_API_KEY")
     }
-	if c, err := llm.NewFromConfig(llmCfg, oaKey, cpKey); err != nil {
-		logging.Logf("lsp ", "llm disabled: %v", err)
-		return nil
-	} else {
-		logging.Logf("lsp ", "llm enabled provider=%s model=%s", c.Name(), c.DefaultModel())
-		return c
+	c, err := llm.NewFromConfig(llmCfg, oaKey, cpKey)
+	if err != nil {
+		return nil, err
+	}
+	red, err := buildRedactor(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return llm.WithRedaction(c, red), nil
+}
+
+// buildRedactor returns the secret redactor for outgoing text, or nil when
+// redact_secrets is false. Invalid redact_patterns are an error, so that a
+// typo never silently disables a pattern.
+func buildRedactor(cfg appconfig.App) (*redact.Redactor, error) {
+	if cfg.RedactSecrets != nil && !*cfg.RedactSecrets {
+		logging.Logf("lsp ", "secret redaction disabled")
+		return nil, nil
 	}
+	return redact.New(cfg.RedactPatterns)
+}
+
+// openAIKey prefers HEXAI_OPENAI_API_KEY and falls back to OPENAI_API_KEY.
+func openAIKey() string {
+	if k := os.Getenv("HEXAI_OPENAI_API_KEY"); strings.TrimSpace(k) != "" {
+		return k
+	}
+	return os.Getenv("OPENAI_API_KEY")
+}
+
+// loadPrompts reads prompt overrides from the user prompts directory.
+func loadPrompts() *prompts.Registry {
+	dir, err := appconfig.PromptsDir()
+	if err != nil {
+		logging.Logf("lsp ", "prompts: %v (using defaults)", err)
+		return prompts.Default()
+	}
+	return prompts.Load(dir)
 }
 
 func ensureFactory(factory ServerFactory) ServerFactory {
@@ -106,7 +280,9 @@ func ensureFactory(factory ServerFactory) ServerFactory {
 }
 
 func makeServerOptions(cfg appconfig.App, logContext bool, client llm.Client) lsp.ServerOptions {
-    return lsp.ServerOptions{
+    hedge, hedgeErr := buildHedgeClient(cfg)
+    embedder, embedErr := buildEmbedder(cfg)
+    opts := lsp.ServerOptions{
         LogContext:        logContext,
         MaxTokens:         cfg.MaxTokens,
         ContextMode:       cfg.ContextMode,
@@ -115,6 +291,50 @@ func makeServerOptions(cfg appconfig.App, logContext bool, client llm.Client) ls
         CodingTemperature: cfg.CodingTemperature,
         Client:            client,
         TriggerCharacters: cfg.TriggerCharacters,
-        ManualInvokeMinPrefix: cfg.ManualInvokeMinPrefix,
+        HedgeClient:       hedge,
+        HedgeDelay:        time.Duration(cfg.HedgeDelayMs) * time.Millisecond,
+        Prompts:           loadPrompts(),
+        HoverEnabled:      cfg.HoverEnabled != nil && *cfg.HoverEnabled,
+        HoverMinDelay:     time.Duration(cfg.HoverMinDelayMs) * time.Millisecond,
+        NoDiskIO:          cfg.NoDiskIO != nil && *cfg.NoDiskIO,
+        ReviewLanguages:   cfg.ReviewLanguages,
+        ReviewDebounce:    time.Duration(cfg.ReviewDebounceMs) * time.Millisecond,
+        Embedder:          embedder,
+        IndexDir:          indexDir(),
+        ChatDir:           chatDir(),
+        ChatHistoryTokens: cfg.ChatHistoryTokens,
+        IgnoreFile:        userIgnoreFile(),
+        IgnoreShowMessage: cfg.IgnoreShowMessage != nil && *cfg.IgnoreShowMessage,
+        Languages:         languageOptions(cfg.Languages),
+    }
+    if cfg.ManualInvokeMinPrefix != nil {
+        opts.ManualInvokeMinPrefix = *cfg.ManualInvokeMinPrefix
     }
+    if cfg.RetrievalTopK != nil {
+        opts.RetrievalTopK = *cfg.RetrievalTopK
+    }
+    if cfg.CompletionRetrieval != nil {
+        opts.CompletionRetrieval = *cfg.CompletionRetrieval
+    }
+    for _, err := range []error{hedgeErr, embedErr} {
+        if err != nil {
+            opts.ConfigWarnings = append(opts.ConfigWarnings, err.Error())
+        }
+    }
+    return opts
+}
+
+// languageOptions converts the per-language config sections.
+func languageOptions(in map[string]appconfig.LanguageConfig) map[string]lsp.LanguageOptions {
+	out := make(map[string]lsp.LanguageOptions, len(in))
+	for lang, l := range in {
+		out[lang] = lsp.LanguageOptions{
+			ContextMode:       l.ContextMode,
+			TriggerCharacters: l.TriggerCharacters,
+			MaxTokens:         l.MaxTokens,
+			Temperature:       l.Temperature,
+			Model:             l.Model,
+		}
+	}
+	return out
 }
@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"hexai/internal/appconfig"
@@ -55,6 +56,9 @@ func TestRunWithFactory_UsesDefaultsAndCallsServer(t *testing.T) {
 	if gotOpts.Client != nil { // with no env, openai client fails to build
 		t.Fatalf("expected nil client when API key missing")
 	}
+	if !strings.Contains(gotOpts.ConfigError, "LLM disabled") {
+		t.Fatalf("expected the reason for the disabled LLM in ConfigError, got %q", gotOpts.ConfigError)
+	}
 }
 
 func TestRunWithFactory_BuildsClientWhenKeysPresent(t *testing.T) {
@@ -95,9 +99,10 @@ func TestRunWithFactory_NormalizesContextMode_AndSetsPreviewLimit(t *testing.T)
 	t.Cleanup(func() { logging.SetLogPreviewLimit(0) })
 	var stderr bytes.Buffer
 	logger := log.New(&stderr, "hexai-lsp ", 0)
+	limit := 3
 	cfg := appconfig.App{
 		ContextMode:     "  File-On-New-Func  ",
-		LogPreviewLimit: 3,
+		LogPreviewLimit: &limit,
 	}
 	var gotOpts lsp.ServerOptions
 	factory := func(r io.Reader, w io.Writer, logger *log.Logger, opts lsp.ServerOptions) ServerRunner {
@@ -143,3 +148,41 @@ func TestRunWithFactory_LogContextFlag(t *testing.T) {
 		t.Fatalf("expected LogContext false when logPath is empty")
 	}
 }
+
+func TestRunWithFactory_ReloadConfigRereadsConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("OPENAI_API_KEY", "dummy")
+	logger := log.New(io.Discard, "", 0)
+	var gotOpts lsp.ServerOptions
+	factory := func(r io.Reader, w io.Writer, logger *log.Logger, opts lsp.ServerOptions) ServerRunner {
+		gotOpts = opts
+		return &fakeServer{opts: opts}
+	}
+	if err := RunWithFactory("", bytes.NewBuffer(nil), bytes.NewBuffer(nil), logger, appconfig.Load(nil), nil, factory); err != nil {
+		t.Fatalf("RunWithFactory error: %v", err)
+	}
+	if gotOpts.ReloadConfig == nil {
+		t.Fatalf("expected a ReloadConfig hook")
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "hexai"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if gotOpts.Client == nil || gotOpts.Client.Name() != "openai" {
+		t.Fatalf("expected the openai client at startup, got %v", gotOpts.Client)
+	}
+	cfg := `{"max_tokens": 123, "provider": "ollama", "ollama_model": "qwen2.5-coder:7b"}`
+	if err := os.WriteFile(filepath.Join(dir, "hexai", "config.json"), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	opts, err := gotOpts.ReloadConfig("", nil)
+	if err != nil {
+		t.Fatalf("reload error: %v", err)
+	}
+	if opts.MaxTokens != 123 || opts.Client == nil {
+		t.Fatalf("reload did not apply config: max_tokens=%d client=%v", opts.MaxTokens, opts.Client)
+	}
+	if opts.Client.Name() != "ollama" || opts.Client.DefaultModel() != "qwen2.5-coder:7b" {
+		t.Fatalf("reload did not rebuild the client: %s:%s", opts.Client.Name(), opts.Client.DefaultModel())
+	}
+}
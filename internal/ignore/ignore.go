@@ -0,0 +1,179 @@
+// Summary: Gitignore-syntax matcher for .hexaiignore, .gitignore and the user ignore file; decides which files never reach a provider.
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// WorkspaceFiles are the ignore files read from the workspace root, in
+// order. Later rules win, so .hexaiignore can re-include (!pattern) what
+// .gitignore excludes.
+var WorkspaceFiles = []string{".gitignore", ".hexaiignore"}
+
+// rule is one pattern line of an ignore file.
+type rule struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+	source  string // "file:line: pattern", used as the reason
+}
+
+// Matcher matches paths against ignore rules. A nil Matcher ignores
+// nothing.
+type Matcher struct {
+	root  string
+	rules []rule
+}
+
+// Load reads the ignore files of the workspace root followed by the extra
+// files (e.g. the user ignore file). Missing files are skipped. Patterns
+// are matched relative to root; with an empty root, against the path
+// without its leading slash.
+func Load(root string, extra ...string) *Matcher {
+	m := &Matcher{root: root}
+	var files []string
+	if root != "" {
+		for _, name := range WorkspaceFiles {
+			files = append(files, filepath.Join(root, name))
+		}
+	}
+	for _, path := range append(files, extra...) {
+		if b, err := os.ReadFile(path); err == nil {
+			m.Add(filepath.Base(path), string(b))
+		}
+	}
+	return m
+}
+
+// Add parses the gitignore-syntax text and appends its rules; name
+// identifies the source in reasons.
+func (m *Matcher) Add(name, text string) {
+	for i, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if r, ok := parseRule(line); ok {
+			r.source = name + ":" + strconv.Itoa(i+1) + ": " + strings.TrimSpace(line)
+			m.rules = append(m.rules, r)
+		}
+	}
+}
+
+// Len returns the number of rules.
+func (m *Matcher) Len() int {
+	if m == nil {
+		return 0
+	}
+	return len(m.rules)
+}
+
+// Match reports whether path (absolute, or relative to the root) is
+// ignored and, if so, the rule that ignores it. As in git, a file inside
+// an ignored directory cannot be re-included.
+func (m *Matcher) Match(path string) (reason string, ignored bool) {
+	if m == nil || len(m.rules) == 0 {
+		return "", false
+	}
+	rel := filepath.ToSlash(path)
+	if m.root != "" && filepath.IsAbs(path) {
+		r, err := filepath.Rel(m.root, path)
+		if err != nil || r == ".." || strings.HasPrefix(r, "../") {
+			r = strings.TrimPrefix(rel, "/") // outside the workspace
+		}
+		rel = filepath.ToSlash(r)
+	}
+	rel = strings.TrimPrefix(rel, "/")
+	parts := strings.Split(rel, "/")
+	for i := range parts {
+		reason, ignored = m.matchOne(strings.Join(parts[:i+1], "/"), i < len(parts)-1)
+		if ignored {
+			return reason, true
+		}
+	}
+	return "", false
+}
+
+// matchOne applies the rules to one path; the last matching rule wins.
+func (m *Matcher) matchOne(rel string, dir bool) (string, bool) {
+	reason, ignored := "", false
+	for _, r := range m.rules {
+		if (r.dirOnly && !dir) || !r.re.MatchString(rel) {
+			continue
+		}
+		reason, ignored = r.source, !r.negate
+	}
+	return reason, ignored
+}
+
+// parseRule converts one gitignore line into a rule. Blank lines and
+// comments yield no rule.
+func parseRule(line string) (rule, bool) {
+	line = strings.TrimRight(line, " \t")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule{}, false
+	}
+	var r rule
+	if strings.HasPrefix(line, "!") {
+		r.negate, line = true, line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:] // escaped leading '#' or '!'
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly, line = true, strings.TrimRight(line, "/")
+	}
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return rule{}, false
+	}
+	expr := globToRegexp(line)
+	if !anchored {
+		expr = "(?:.*/)?" + expr
+	}
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return rule{}, false
+	}
+	r.re = re
+	return r, true
+}
+
+// globToRegexp translates gitignore wildcards: "*" and "?" stay within one
+// path segment, "**" spans segments, and [...] classes are kept.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,59 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatcher_GitignoreSyntax(t *testing.T) {
+	m := &Matcher{root: "/w"}
+	m.Add(".hexaiignore", "# secrets\n*.pem\n/config/prod.yaml\nsecrets/\ndata/**/customers.csv\n!public.pem\n")
+	cases := map[string]bool{
+		"/w/key.pem":                    true,
+		"/w/certs/server.pem":           true,
+		"/w/public.pem":                 false,
+		"/w/config/prod.yaml":           true,
+		"/w/sub/config/prod.yaml":       false,
+		"/w/secrets/token.txt":          true,
+		"/w/app/secrets/a/b.go":         true,
+		"/w/secrets":                    false, // dirOnly: a file named secrets
+		"/w/data/customers.csv":         true,
+		"/w/data/2024/q1/customers.csv": true,
+		"/w/main.go":                    false,
+	}
+	for path, want := range cases {
+		if _, got := m.Match(path); got != want {
+			t.Errorf("Match(%s) = %v, want %v", path, got, want)
+		}
+	}
+	if reason, _ := m.Match("/w/key.pem"); reason != ".hexaiignore:2: *.pem" {
+		t.Fatalf("unexpected reason %q", reason)
+	}
+}
+
+func TestMatcher_NoReincludeInsideIgnoredDir(t *testing.T) {
+	m := &Matcher{}
+	m.Add("ignore", "vendor/\n!vendor/keep.go\n")
+	if _, ok := m.Match("/x/vendor/keep.go"); !ok {
+		t.Fatalf("files below an ignored directory stay ignored")
+	}
+	var nilMatcher *Matcher
+	if _, ok := nilMatcher.Match("/x/a.go"); ok {
+		t.Fatalf("nil matcher must ignore nothing")
+	}
+}
+
+func TestLoad_WorkspaceAndUserFiles(t *testing.T) {
+	root := t.TempDir()
+	_ = os.WriteFile(filepath.Join(root, ".gitignore"), []byte(".env\n*.log\n"), 0o644)
+	_ = os.WriteFile(filepath.Join(root, ".hexaiignore"), []byte("!debug.log\n"), 0o644)
+	user := filepath.Join(t.TempDir(), "ignore")
+	_ = os.WriteFile(user, []byte("customers/\n"), 0o644)
+	m := Load(root, user, filepath.Join(root, "missing"))
+	for path, want := range map[string]bool{".env": true, "app.log": true, "debug.log": false, "customers/a.sql": true} {
+		if _, got := m.Match(filepath.Join(root, path)); got != want {
+			t.Errorf("Match(%s) = %v, want %v", path, got, want)
+		}
+	}
+}
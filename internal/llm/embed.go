@@ -0,0 +1,148 @@
+// Summary: Embedding clients for Ollama (/api/embed) and OpenAI (/v1/embeddings) behind the Embedder interface.
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"hexai/internal/logging"
+)
+
+// Embedder turns texts into vectors for similarity search. Vectors of
+// different models are not comparable, so callers key stored vectors by
+// EmbeddingModel.
+type Embedder interface {
+	// Embed returns one vector per input text, in order.
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	// EmbeddingModel returns the provider-qualified model name.
+	EmbeddingModel() string
+}
+
+// NewEmbedder builds an Embedder for provider ("ollama" or "openai"). An
+// empty model selects the provider's default embedding model.
+func NewEmbedder(provider, baseURL, model, apiKey string) (Embedder, error) {
+	switch strings.ToLower(strings.TrimSpace(provider)) {
+	case "ollama":
+		if strings.TrimSpace(model) == "" {
+			model = "nomic-embed-text"
+		}
+		c := newOllama(baseURL, model, nil).(ollamaClient)
+		return ollamaEmbedder{c: c, model: model}, nil
+	case "openai":
+		if strings.TrimSpace(apiKey) == "" {
+			return nil, errors.New("missing OPENAI_API_KEY for embedding provider openai")
+		}
+		if strings.TrimSpace(model) == "" {
+			model = "text-embedding-3-small"
+		}
+		c := newOpenAI(baseURL, model, apiKey, nil).(openAIClient)
+		return openAIEmbedder{c: c, model: model}, nil
+	default:
+		return nil, errors.New("unknown embedding provider: " + provider)
+	}
+}
+
+type ollamaEmbedder struct {
+	c     ollamaClient
+	model string
+}
+
+type ollamaEmbedRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type ollamaEmbedResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+	Error      string      `json:"error,omitempty"`
+}
+
+func (e ollamaEmbedder) EmbeddingModel() string { return "ollama:" + e.model }
+
+func (e ollamaEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	start := time.Now()
+	body, err := json.Marshal(ollamaEmbedRequest{Model: e.model, Input: texts})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := e.c.doJSON(ctx, e.c.baseURL+"/api/embed", body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := handleOllamaNon2xx(resp, start); err != nil {
+		return nil, err
+	}
+	var out ollamaEmbedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	logging.Logf("llm/ollama ", "embed texts=%d duration=%s", len(texts), time.Since(start))
+	return checkEmbeddings(out.Embeddings, len(texts))
+}
+
+type openAIEmbedder struct {
+	c     openAIClient
+	model string
+}
+
+type oaEmbedRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type oaEmbedResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+func (e openAIEmbedder) EmbeddingModel() string { return "openai:" + e.model }
+
+func (e openAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	start := time.Now()
+	body, err := json.Marshal(oaEmbedRequest{Model: e.model, Input: texts})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := e.c.doJSON(ctx, e.c.baseURL+"/embeddings", body, map[string]string{
+		"Authorization": "Bearer " + e.c.apiKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := handleOpenAINon2xx(resp, start); err != nil {
+		return nil, err
+	}
+	var out oaEmbedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	vecs := make([][]float32, len(texts))
+	for _, d := range out.Data {
+		if d.Index >= 0 && d.Index < len(vecs) {
+			vecs[d.Index] = d.Embedding
+		}
+	}
+	logging.Logf("llm/openai ", "embed texts=%d duration=%s", len(texts), time.Since(start))
+	return checkEmbeddings(vecs, len(texts))
+}
+
+// checkEmbeddings verifies that the provider returned a vector per text.
+func checkEmbeddings(vecs [][]float32, n int) ([][]float32, error) {
+	if len(vecs) != n {
+		return nil, fmt.Errorf("embed: got %d vectors for %d texts", len(vecs), n)
+	}
+	for i, v := range vecs {
+		if len(v) == 0 {
+			return nil, fmt.Errorf("embed: empty vector for text %d", i)
+		}
+	}
+	return vecs, nil
+}
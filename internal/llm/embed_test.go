@@ -0,0 +1,55 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOllamaEmbedder_PostsInputsToAPIEmbed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ollamaEmbedRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if r.URL.Path != "/api/embed" || req.Model != "nomic-embed-text" || len(req.Input) != 2 {
+			t.Errorf("unexpected request %s %+v", r.URL.Path, req)
+		}
+		_, _ = w.Write([]byte(`{"embeddings":[[1,0],[0,1]]}`))
+	}))
+	defer srv.Close()
+	e, err := NewEmbedder("ollama", srv.URL, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vecs, err := e.Embed(context.Background(), []string{"a", "b"})
+	if err != nil || len(vecs) != 2 || vecs[1][1] != 1 {
+		t.Fatalf("unexpected result %v %v", vecs, err)
+	}
+	if e.EmbeddingModel() != "ollama:nomic-embed-text" {
+		t.Fatalf("model got %q", e.EmbeddingModel())
+	}
+}
+
+func TestOpenAIEmbedder_OrdersByIndexAndChecksCount(t *testing.T) {
+	body := `{"data":[{"index":1,"embedding":[0,1]},{"index":0,"embedding":[1,0]}]}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embeddings" || r.Header.Get("Authorization") != "Bearer k" {
+			t.Errorf("unexpected request %s auth=%q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+	e, _ := NewEmbedder("openai", srv.URL, "m", "k")
+	vecs, err := e.Embed(context.Background(), []string{"a", "b"})
+	if err != nil || vecs[0][0] != 1 || vecs[1][1] != 1 {
+		t.Fatalf("unexpected result %v %v", vecs, err)
+	}
+	body = `{"data":[{"index":0,"embedding":[1,0]}]}`
+	if _, err := e.Embed(context.Background(), []string{"a", "b"}); err == nil {
+		t.Fatalf("expected error for missing vector")
+	}
+	if _, err := NewEmbedder("openai", srv.URL, "m", ""); err == nil {
+		t.Fatalf("expected error without API key")
+	}
+}
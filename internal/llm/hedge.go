@@ -0,0 +1,93 @@
+// Summary: Hedged requests; fires a backup request at a secondary provider when the primary is slow.
+package llm
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// HedgeOutcome reports which leg of a hedged request produced the result.
+type HedgeOutcome int
+
+const (
+	// HedgeNotFired means the primary answered before the hedge delay elapsed.
+	HedgeNotFired HedgeOutcome = iota
+	// HedgePrimaryWon means the secondary was fired but the primary answered first.
+	HedgePrimaryWon
+	// HedgeSecondaryWon means the secondary was fired and answered first.
+	HedgeSecondaryWon
+)
+
+type hedgeResult[T any] struct {
+	val       T
+	err       error
+	secondary bool
+}
+
+// Hedge runs primary and, if it has not answered within delay, also runs
+// secondary with the same parent context. The first successful result wins and
+// the other leg is cancelled. If the primary fails before the delay elapses,
+// the secondary is fired immediately. An error is returned only when every
+// fired leg failed. A nil secondary disables hedging.
+func Hedge[T any](ctx context.Context, delay time.Duration, primary, secondary func(context.Context) (T, error)) (T, HedgeOutcome, error) {
+	if secondary == nil {
+		v, err := primary(ctx)
+		return v, HedgeNotFired, err
+	}
+	pctx, pcancel := context.WithCancel(ctx)
+	defer pcancel()
+	sctx, scancel := context.WithCancel(ctx)
+	defer scancel()
+
+	results := make(chan hedgeResult[T], 2)
+	run := func(c context.Context, f func(context.Context) (T, error), isSecondary bool) {
+		v, err := f(c)
+		results <- hedgeResult[T]{val: v, err: err, secondary: isSecondary}
+	}
+	go run(pctx, primary, false)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	fired, pending := false, 1
+	var errs []error
+	for {
+		select {
+		case <-timer.C:
+			if !fired {
+				fired, pending = true, pending+1
+				go run(sctx, secondary, true)
+			}
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				return r.val, hedgeOutcomeFor(fired, r.secondary), nil
+			}
+			errs = append(errs, r.err)
+			if !fired && !r.secondary {
+				// Primary failed early: use the secondary as a fallback right away.
+				fired, pending = true, pending+1
+				go run(sctx, secondary, true)
+				continue
+			}
+			if pending == 0 {
+				var zero T
+				return zero, hedgeOutcomeFor(fired, r.secondary), errors.Join(errs...)
+			}
+		case <-ctx.Done():
+			var zero T
+			return zero, hedgeOutcomeFor(fired, false), ctx.Err()
+		}
+	}
+}
+
+func hedgeOutcomeFor(fired, secondary bool) HedgeOutcome {
+	switch {
+	case !fired:
+		return HedgeNotFired
+	case secondary:
+		return HedgeSecondaryWon
+	default:
+		return HedgePrimaryWon
+	}
+}
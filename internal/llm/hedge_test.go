@@ -0,0 +1,67 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func slowLeg(d time.Duration, val string, err error) func(context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		select {
+		case <-time.After(d):
+			return val, err
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+func TestHedge_PrimaryFastDoesNotFire(t *testing.T) {
+	fired := false
+	secondary := func(context.Context) (string, error) { fired = true; return "s", nil }
+	got, outcome, err := Hedge(context.Background(), 50*time.Millisecond, slowLeg(0, "p", nil), secondary)
+	if err != nil || got != "p" || outcome != HedgeNotFired {
+		t.Fatalf("got=%q outcome=%v err=%v", got, outcome, err)
+	}
+	if fired {
+		t.Fatalf("secondary should not fire when primary answers before the delay")
+	}
+}
+
+func TestHedge_SecondaryWinsAndCancelsPrimary(t *testing.T) {
+	cancelled := make(chan struct{})
+	primary := func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		close(cancelled)
+		return "", ctx.Err()
+	}
+	got, outcome, err := Hedge(context.Background(), 10*time.Millisecond, primary, slowLeg(0, "s", nil))
+	if err != nil || got != "s" || outcome != HedgeSecondaryWon {
+		t.Fatalf("got=%q outcome=%v err=%v", got, outcome, err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatalf("expected primary leg to be cancelled")
+	}
+}
+
+func TestHedge_PrimaryErrorFallsBackImmediately(t *testing.T) {
+	start := time.Now()
+	got, outcome, err := Hedge(context.Background(), time.Hour, slowLeg(0, "", errors.New("boom")), slowLeg(0, "s", nil))
+	if err != nil || got != "s" || outcome != HedgeSecondaryWon {
+		t.Fatalf("got=%q outcome=%v err=%v", got, outcome, err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatalf("fallback should not wait for the hedge delay")
+	}
+}
+
+func TestHedge_AllLegsFail(t *testing.T) {
+	_, _, err := Hedge(context.Background(), time.Millisecond, slowLeg(0, "", errors.New("p")), slowLeg(0, "", errors.New("s")))
+	if err == nil {
+		t.Fatalf("expected error when both legs fail")
+	}
+}
@@ -0,0 +1,134 @@
+// Summary: Client and Embedder wrappers that redact secrets from all outgoing text and restore them in responses.
+package llm
+
+import (
+	"context"
+
+	"hexai/internal/logging"
+	"hexai/internal/redact"
+)
+
+// WithRedaction wraps c so that every outgoing message is redacted by r.
+// Placeholders sent in a request are mapped back in its response, so an
+// edit built from the response keeps the user's secret. The optional
+// Streamer and CodeCompleter interfaces of c are preserved. A nil r
+// returns c unchanged.
+func WithRedaction(c Client, r *redact.Redactor) Client {
+	if c == nil || r == nil {
+		return c
+	}
+	base := redactingClient{inner: c, r: r}
+	_, stream := c.(Streamer)
+	_, complete := c.(CodeCompleter)
+	switch {
+	case stream && complete:
+		return redactingStreamCompleter{base}
+	case stream:
+		return redactingStreamer{base}
+	case complete:
+		return redactingCompleter{base}
+	}
+	return base
+}
+
+type redactingClient struct {
+	inner Client
+	r     *redact.Redactor
+}
+
+type redactingStreamer struct{ redactingClient }
+
+type redactingCompleter struct{ redactingClient }
+
+type redactingStreamCompleter struct{ redactingClient }
+
+func (c redactingClient) Name() string         { return c.inner.Name() }
+func (c redactingClient) DefaultModel() string { return c.inner.DefaultModel() }
+
+func (c redactingClient) Chat(ctx context.Context, messages []Message, opts ...RequestOption) (string, error) {
+	sess, msgs := c.redactMessages(messages)
+	text, err := c.inner.Chat(ctx, msgs, opts...)
+	return sess.Restore(text), err
+}
+
+func (c redactingClient) chatStream(ctx context.Context, messages []Message, onDelta func(string), opts ...RequestOption) error {
+	sess, msgs := c.redactMessages(messages)
+	write, flush := sess.RestoreStream(onDelta)
+	err := c.inner.(Streamer).ChatStream(ctx, msgs, write, opts...)
+	flush()
+	return err
+}
+
+func (c redactingClient) codeCompletion(ctx context.Context, prompt, suffix string, n int, language string, temperature float64) ([]string, error) {
+	sess := c.r.Session()
+	prompt, suffix = sess.Redact(prompt), sess.Redact(suffix)
+	c.logCounts(sess)
+	out, err := c.inner.(CodeCompleter).CodeCompletion(ctx, prompt, suffix, n, language, temperature)
+	for i := range out {
+		out[i] = sess.Restore(out[i])
+	}
+	return out, err
+}
+
+func (c redactingStreamer) ChatStream(ctx context.Context, messages []Message, onDelta func(string), opts ...RequestOption) error {
+	return c.chatStream(ctx, messages, onDelta, opts...)
+}
+
+func (c redactingCompleter) CodeCompletion(ctx context.Context, prompt, suffix string, n int, language string, temperature float64) ([]string, error) {
+	return c.codeCompletion(ctx, prompt, suffix, n, language, temperature)
+}
+
+func (c redactingStreamCompleter) ChatStream(ctx context.Context, messages []Message, onDelta func(string), opts ...RequestOption) error {
+	return c.chatStream(ctx, messages, onDelta, opts...)
+}
+
+func (c redactingStreamCompleter) CodeCompletion(ctx context.Context, prompt, suffix string, n int, language string, temperature float64) ([]string, error) {
+	return c.codeCompletion(ctx, prompt, suffix, n, language, temperature)
+}
+
+// redactMessages returns redacted copies of messages; the originals are
+// left untouched.
+func (c redactingClient) redactMessages(messages []Message) (*redact.Session, []Message) {
+	sess := c.r.Session()
+	out := make([]Message, len(messages))
+	for i, m := range messages {
+		m.Content = sess.Redact(m.Content)
+		out[i] = m
+	}
+	c.logCounts(sess)
+	return sess, out
+}
+
+func (c redactingClient) logCounts(sess *redact.Session) {
+	if sum := sess.Summary(); sum != "" {
+		logging.Logf("llm/redact ", "provider=%s redacted %s", c.inner.Name(), sum)
+	}
+}
+
+// RedactEmbedder wraps e so that texts are redacted before embedding. A
+// nil r returns e unchanged.
+func RedactEmbedder(e Embedder, r *redact.Redactor) Embedder {
+	if e == nil || r == nil {
+		return e
+	}
+	return redactingEmbedder{inner: e, r: r}
+}
+
+type redactingEmbedder struct {
+	inner Embedder
+	r     *redact.Redactor
+}
+
+func (e redactingEmbedder) EmbeddingModel() string { return e.inner.EmbeddingModel() }
+
+func (e redactingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	sess := e.r.Session()
+	out := make([]string, len(texts))
+	for i, t := range texts {
+		out[i] = sess.Redact(t)
+	}
+	if sum := sess.Summary(); sum != "" {
+		logging.Logf("llm/redact ", "embeddings redacted %s", sum)
+	}
+	return e.inner.Embed(ctx, out)
+}
@@ -0,0 +1,60 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"hexai/internal/redact"
+)
+
+// echoClient returns the last message it was sent and records it.
+type echoClient struct{ sent []Message }
+
+func (e *echoClient) Chat(_ context.Context, msgs []Message, _ ...RequestOption) (string, error) {
+	e.sent = msgs
+	return msgs[len(msgs)-1].Content, nil
+}
+func (e *echoClient) Name() string         { return "echo" }
+func (e *echoClient) DefaultModel() string { return "m" }
+
+type echoStreamer struct{ echoClient }
+
+func (e *echoStreamer) ChatStream(ctx context.Context, msgs []Message, onDelta func(string), _ ...RequestOption) error {
+	text, _ := e.Chat(ctx, msgs)
+	for i := 0; i < len(text); i += 5 {
+		onDelta(text[i:min(i+5, len(text))])
+	}
+	return nil
+}
+
+func TestWithRedaction_RedactsRequestsAndRestoresResponses(t *testing.T) {
+	r, _ := redact.New(nil)
+	inner := &echoClient{}
+	c := WithRedaction(inner, r)
+	if _, ok := c.(Streamer); ok {
+		t.Fatalf("wrapper must not add interfaces the client lacks")
+	}
+	msg := Message{Role: "user", Content: "API_TOKEN=abcdef123456"}
+	out, err := c.Chat(context.Background(), []Message{msg})
+	if err != nil || out != msg.Content {
+		t.Fatalf("response not restored: %q %v", out, err)
+	}
+	if strings.Contains(inner.sent[0].Content, "abcdef123456") {
+		t.Fatalf("secret reached the provider: %q", inner.sent[0].Content)
+	}
+}
+
+func TestWithRedaction_PreservesStreamer(t *testing.T) {
+	r, _ := redact.New(nil)
+	inner := &echoStreamer{}
+	st, ok := WithRedaction(inner, r).(Streamer)
+	if !ok {
+		t.Fatalf("streaming support lost")
+	}
+	var got strings.Builder
+	_ = st.ChatStream(context.Background(), []Message{{Role: "user", Content: "use Bearer abcdefghijklmnopqrstu"}}, func(s string) { got.WriteString(s) })
+	if got.String() != "use Bearer abcdefghijklmnopqrstu" || strings.Contains(inner.sent[0].Content, "abcdefghijklmnopqrstu") {
+		t.Fatalf("unexpected stream %q sent %q", got.String(), inner.sent[0].Content)
+	}
+}
@@ -0,0 +1,48 @@
+// Summary: Language analysers that extract structured cursor context (enclosing function, receiver, fields, imports).
+package lsp
+
+import "path"
+
+// codeContext is the structured context around a cursor position.
+type codeContext struct {
+	function string   // declaration line of the enclosing function
+	newFunc  bool     // cursor is in a function signature, before its body
+	receiver string   // receiver type of the enclosing method
+	fields   []string // fields of the receiver struct, as "name Type"
+	imports  []string // imported packages
+}
+
+// codeAnalyzer extracts codeContext for documents of one language.
+type codeAnalyzer interface {
+	analyze(d *document, pos Position) codeContext
+}
+
+// analyzers maps file extensions to language-aware analysers. Other files
+// use heuristicAnalyzer.
+var analyzers = map[string]codeAnalyzer{
+	".go": goAnalyzer{},
+}
+
+func analyzerFor(uri string) codeAnalyzer {
+	if a, ok := analyzers[path.Ext(uri)]; ok {
+		return a
+	}
+	return heuristicAnalyzer{}
+}
+
+// codeContext analyses the document at uri around pos.
+func (s *Server) codeContext(uri string, pos Position) codeContext {
+	d := s.getDocument(uri)
+	if d == nil || len(d.lines) == 0 {
+		return codeContext{}
+	}
+	return analyzerFor(uri).analyze(d, Position{Line: clampLine(d, pos.Line), Character: pos.Character})
+}
+
+// heuristicAnalyzer works on any language by matching declaration keywords
+// and braces line by line.
+type heuristicAnalyzer struct{}
+
+func (heuristicAnalyzer) analyze(d *document, pos Position) codeContext {
+	return codeContext{function: heuristicFunction(d.lines, pos.Line), newFunc: heuristicNewFunc(d.lines, pos)}
+}
@@ -0,0 +1,152 @@
+// Summary: Go analyser; parses documents with go/parser to find the enclosing declaration, receiver fields and imports.
+package lsp
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+)
+
+// goAnalyzer uses the Go syntax tree, so comments, strings and closures
+// cannot be mistaken for declarations.
+type goAnalyzer struct{}
+
+// goParse is the syntax tree of one document snapshot. The parser tolerates
+// errors; err is set when the tree is partial, e.g. while typing.
+type goParse struct {
+	fset *token.FileSet
+	file *ast.File
+	err  error
+}
+
+// goAST parses the document once per snapshot.
+func (d *document) goAST() *goParse {
+	d.goOnce.Do(func() {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, d.uri, d.Text(), parser.ParseComments|parser.SkipObjectResolution)
+		d.goParsed = &goParse{fset: fset, file: f, err: err}
+	})
+	return d.goParsed
+}
+
+func (goAnalyzer) analyze(d *document, pos Position) codeContext {
+	gp := d.goAST()
+	if gp.file == nil {
+		return heuristicAnalyzer{}.analyze(d, pos)
+	}
+	cc := codeContext{imports: goImports(gp.file)}
+	off := byteOffset(d, pos)
+	offsetOf := func(p token.Pos) int { return gp.fset.Position(p).Offset }
+	for _, decl := range gp.file.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if !ok || off < offsetOf(fd.Pos()) || off > offsetOf(fd.End()) {
+			continue
+		}
+		cc.function = strings.TrimSpace(d.lines[gp.fset.Position(fd.Pos()).Line-1])
+		cc.newFunc = fd.Body == nil || off <= offsetOf(fd.Body.Lbrace)
+		if fd.Recv != nil && len(fd.Recv.List) > 0 {
+			cc.receiver = receiverTypeName(fd.Recv.List[0].Type)
+			cc.fields = structFields(gp.file, cc.receiver)
+		}
+		return cc
+	}
+	if gp.err != nil && !inComment(gp, off) {
+		// The tree is incomplete around unfinished code; guess from the lines.
+		h := heuristicAnalyzer{}.analyze(d, pos)
+		cc.function, cc.newFunc = h.function, h.newFunc
+	}
+	return cc
+}
+
+// byteOffset converts pos into a byte offset in the document text.
+func byteOffset(d *document, pos Position) int {
+	off := 0
+	for i := 0; i < pos.Line && i < len(d.lines); i++ {
+		off += len(d.lines[i]) + 1
+	}
+	if pos.Line < len(d.lines) {
+		off += max(0, min(pos.Character, len(d.lines[pos.Line])))
+	}
+	return off
+}
+
+func inComment(gp *goParse, off int) bool {
+	for _, cg := range gp.file.Comments {
+		if off >= gp.fset.Position(cg.Pos()).Offset && off <= gp.fset.Position(cg.End()).Offset {
+			return true
+		}
+	}
+	return false
+}
+
+// receiverTypeName returns the type name of a method receiver, without
+// pointer or type parameters.
+func receiverTypeName(expr ast.Expr) string {
+	for {
+		switch e := expr.(type) {
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.IndexExpr:
+			expr = e.X
+		case *ast.IndexListExpr:
+			expr = e.X
+		case *ast.Ident:
+			return e.Name
+		default:
+			return ""
+		}
+	}
+}
+
+// structFields lists the fields of the struct type name declared in f.
+func structFields(f *ast.File, name string) []string {
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok || ts.Name.Name != name {
+				continue
+			}
+			var out []string
+			for _, field := range st.Fields.List {
+				typ := types.ExprString(field.Type)
+				if len(field.Names) == 0 {
+					out = append(out, typ) // embedded
+					continue
+				}
+				names := make([]string, len(field.Names))
+				for i, n := range field.Names {
+					names[i] = n.Name
+				}
+				out = append(out, strings.Join(names, ", ")+" "+typ)
+			}
+			return out
+		}
+	}
+	return nil
+}
+
+func goImports(f *ast.File) []string {
+	if f == nil {
+		return nil
+	}
+	var out []string
+	for _, imp := range f.Imports {
+		p, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		if imp.Name != nil {
+			p = imp.Name.Name + " " + p
+		}
+		out = append(out, p)
+	}
+	return out
+}
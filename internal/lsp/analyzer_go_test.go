@@ -0,0 +1,91 @@
+package lsp
+
+import (
+	"strings"
+	"testing"
+)
+
+const goAnalyzerSrc = `package p
+
+import (
+	"fmt"
+	str "strings"
+)
+
+type Server struct {
+	name  string
+	peers []*Server
+	fmt.Stringer
+}
+
+// not a decl: func fake() {
+func (s *Server) Greet(msg string) string {
+	f := func() string { return "func inside" }
+	return str.ToUpper(msg + f())
+}
+`
+
+func TestGoAnalyzer_MethodContext(t *testing.T) {
+	s := newTestServer()
+	uri := "file:///p/s.go"
+	s.setDocument(uri, goAnalyzerSrc)
+	cc := s.codeContext(uri, Position{Line: 16, Character: 4})
+	if cc.function != "func (s *Server) Greet(msg string) string {" {
+		t.Fatalf("function got %q", cc.function)
+	}
+	if cc.newFunc || cc.receiver != "Server" {
+		t.Fatalf("unexpected newFunc=%t receiver=%q", cc.newFunc, cc.receiver)
+	}
+	if strings.Join(cc.fields, "; ") != "name string; peers []*Server; fmt.Stringer" {
+		t.Fatalf("fields got %q", cc.fields)
+	}
+	if strings.Join(cc.imports, ", ") != "fmt, str strings" {
+		t.Fatalf("imports got %q", cc.imports)
+	}
+}
+
+func TestGoAnalyzer_NewFuncIgnoresComments(t *testing.T) {
+	s := newTestServer()
+	uri := "file:///p/s.go"
+	s.setDocument(uri, goAnalyzerSrc)
+	if !s.isDefiningNewFunction(uri, Position{Line: 14, Character: 30}) {
+		t.Fatalf("cursor in the signature should count as defining a new function")
+	}
+	if s.isDefiningNewFunction(uri, Position{Line: 13, Character: 26}) {
+		t.Fatalf("a comment mentioning func is not a declaration")
+	}
+	if _, _, _, f := s.lineContext(uri, Position{Line: 13, Character: 5}); f != "" {
+		t.Fatalf("comment line has no enclosing function, got %q", f)
+	}
+}
+
+func TestGoAnalyzer_UnfinishedCodeFallsBackToHeuristics(t *testing.T) {
+	s := newTestServer()
+	uri := "file:///p/s.go"
+	s.setDocument(uri, "package p\n\nfunc add(a int")
+	if !s.isDefiningNewFunction(uri, Position{Line: 2, Character: 14}) {
+		t.Fatalf("unfinished signature should count as defining a new function")
+	}
+}
+
+func TestBuildPrompts_IncludesGoScope(t *testing.T) {
+	s := newTestServer()
+	uri := "file:///p/s.go"
+	s.setDocument(uri, goAnalyzerSrc)
+	p := CompletionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Position: Position{Line: 16, Character: 4}}
+	_, user := s.buildPrompts(false, p, "", "", "", "func (s *Server) Greet(msg string) string {")
+	if !strings.Contains(user, "Receiver: Server (fields: name string; peers []*Server; fmt.Stringer)\nImports: fmt, str strings\n") {
+		t.Fatalf("missing scope in prompt:\n%s", user)
+	}
+}
+
+func TestWindowContext_LeavesScopeToPrompt(t *testing.T) {
+	s := newTestServer()
+	setConfig(s, func(c *serverConfig) { c.contextMode = "window"; c.windowLines = 4; c.maxContextTokens = 1000 })
+	uri := "file:///p/s.go"
+	s.setDocument(uri, goAnalyzerSrc)
+	text, _ := s.buildAdditionalContext(false, uri, Position{Line: 16, Character: 4})
+	if strings.Contains(text, "Imports:") || strings.Contains(text, "Fields of") {
+		t.Fatalf("scope belongs in the prompt template only:\n%s", text)
+	}
+}
@@ -0,0 +1,344 @@
+// Summary: Slash commands typed on an in-editor chat trigger line (/model, /context, /clear, /file, /retry), handled before the LLM is called.
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"hexai/internal/llm"
+	"hexai/internal/logging"
+	"hexai/internal/prompts"
+)
+
+// Chat context modes set with /context; the default sends the code around
+// comment questions and related workspace chunks.
+const (
+	chatContextDefault  = ""
+	chatContextNone     = "none"
+	chatContextFunction = "function"
+	chatContextFull     = "full"
+)
+
+// chatCommands lists the slash commands; any other "/..." is a question.
+var chatCommands = map[string]bool{"model": true, "context": true, "clear": true, "file": true, "retry": true}
+
+// chatSession holds the settings changed with slash commands in one document.
+type chatSession struct {
+	model   string // "" uses the configured model
+	context string // one of the chatContext* modes
+}
+
+// chatCommand is a slash command parsed from a chat question.
+type chatCommand struct {
+	name string // without the slash, e.g. "model"
+	args string // rest of the question
+}
+
+// parseChatCommand parses question as a slash command. Trigger punctuation
+// left on the question (e.g. the "?" of "/clear?>") is not part of the name.
+func parseChatCommand(question string) (chatCommand, bool) {
+	if !strings.HasPrefix(question, "/") {
+		return chatCommand{}, false
+	}
+	name, args, _ := strings.Cut(question[1:], " ")
+	name = trimTriggerPunct(name)
+	if !chatCommands[name] {
+		return chatCommand{}, false
+	}
+	return chatCommand{name: name, args: strings.TrimSpace(args)}, true
+}
+
+func trimTriggerPunct(s string) string {
+	return strings.TrimRight(s, "?!:;")
+}
+
+// fileArgs splits the arguments of /file into the path and the question.
+func fileArgs(args string) (path, question string) {
+	path, question, _ = strings.Cut(args, " ")
+	if question = strings.TrimSpace(question); question == "" {
+		return trimTriggerPunct(path), "Explain this file."
+	}
+	return path, question
+}
+
+// historyTurn classifies an earlier question for the chat history: /file
+// questions keep only the question, other slash commands are dropped
+// together with their reply, and /clear ends the history.
+func historyTurn(question string) (text string, keep, stop bool) {
+	cmd, ok := parseChatCommand(question)
+	if !ok {
+		return question, true, false
+	}
+	switch cmd.name {
+	case "clear":
+		return "", false, true
+	case "file":
+		path, q := fileArgs(cmd.args)
+		return q + " (about " + path + ")", true, false
+	}
+	return "", false, false
+}
+
+func (s *Server) chatSession(uri string) chatSession {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.chatSessions[uri]
+}
+
+func (s *Server) updateChatSession(uri string, update func(*chatSession)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.chatSessions == nil {
+		s.chatSessions = make(map[string]chatSession)
+	}
+	sess := s.chatSessions[uri]
+	update(&sess)
+	s.chatSessions[uri] = sess
+}
+
+// chatRequestOpts returns the request options for chat in uri, with the
+// model chosen via /model taking precedence.
+func (s *Server) chatRequestOpts(uri string) []llm.RequestOption {
+	opts := s.llmRequestOpts(uri)
+	if model := s.chatSession(uri).model; model != "" {
+		opts = append(opts, llm.WithModel(model))
+	}
+	return opts
+}
+
+// chatModelFor returns the model answering chat in uri, for logs and replies.
+func (s *Server) chatModelFor(uri string) string {
+	if model := s.chatSession(uri).model; model != "" {
+		return model
+	}
+	return s.currentModelFor(uri)
+}
+
+// runChatCommand executes a slash command other than /file and answers on
+// the command line. /retry instead removes the previous answer and restores
+// the trigger of its question, which is then answered again in place.
+func (s *Server) runChatCommand(uri string, cmd chatCommand, lineIdx, lastIdx int, style chatReplyStyle) {
+	retried := false
+	defer func() {
+		s.endChat(uri)
+		if retried {
+			s.detectAndHandleChat(uri) // the edit may be visible before the reply
+		}
+	}()
+	d := s.getDocument(uri)
+	if d == nil || lineIdx >= len(d.lines) {
+		return
+	}
+	logging.Logf("lsp ", "chat command /%s uri=%s", cmd.name, uri)
+	var edit WorkspaceEdit
+	if cmd.name == "retry" {
+		if e, ok := retryEdit(d, s.settingsFor(uri).language, lineIdx); ok {
+			edit, retried = WorkspaceEdit{Changes: map[string][]TextEdit{uri: {e}}}, true
+		}
+	}
+	if !retried {
+		edit = chatReplyEdit(uri, d, lineIdx, lastIdx, 1, style, s.chatCommandReply(uri, cmd))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.clientApplyEditWait(ctx, "Hexai: chat command", edit); err != nil {
+		logging.Logf("lsp ", "chat command applyEdit error: %v", err)
+		retried = false
+	}
+}
+
+// chatCommandReply applies cmd to the session of uri and returns the text
+// written below the command.
+func (s *Server) chatCommandReply(uri string, cmd chatCommand) string {
+	arg := trimTriggerPunct(cmd.args)
+	switch cmd.name {
+	case "model":
+		if arg != "" {
+			if arg == "default" {
+				arg = ""
+			}
+			s.updateChatSession(uri, func(c *chatSession) { c.model = arg })
+		}
+		client := s.config().llmClient
+		if client == nil {
+			return "The LLM is disabled."
+		}
+		return fmt.Sprintf("Model for this chat: %s:%s", client.Name(), s.chatModelFor(uri))
+	case "context":
+		switch arg {
+		case "":
+		case "default", chatContextNone, chatContextFunction, chatContextFull:
+			if arg == "default" {
+				arg = chatContextDefault
+			}
+			s.updateChatSession(uri, func(c *chatSession) { c.context = arg })
+		default:
+			return fmt.Sprintf("Unknown context %q; use default, none, function or full.", arg)
+		}
+		mode := s.chatSession(uri).context
+		if mode == chatContextDefault {
+			mode = "default"
+		}
+		return "Context for this chat: " + mode
+	case "clear":
+		return "Conversation cleared; earlier questions are no longer sent."
+	case "retry":
+		return "Nothing to retry: there is no answer above."
+	}
+	return "Unknown command /" + cmd.name
+}
+
+// retryEdit returns the edit that removes the answer above the /retry line
+// together with that line and restores the trigger of the answered question.
+func retryEdit(d *document, lang string, retryLine int) (TextEdit, bool) {
+	text := func(i int) string { return chatText(lang, d.lines[i]) }
+	i := retryLine - 1
+	for i >= 0 && text(i) == "" {
+		i--
+	}
+	if i < 0 || !strings.HasPrefix(text(i), ">") {
+		return TextEdit{}, false
+	}
+	for i >= 0 && strings.HasPrefix(text(i), ">") {
+		i--
+	}
+	for i >= 0 && text(i) == "" {
+		i--
+	}
+	if i < 0 {
+		return TextEdit{}, false
+	}
+	if _, keep, _ := historyTurn(stripTrailingTrigger(text(i))); !keep {
+		return TextEdit{}, false // the answer belongs to a command
+	}
+	start := Position{Line: i, Character: len(strings.TrimRight(d.lines[i], " \t"))}
+	end := Position{Line: retryLine, Character: len(d.lines[retryLine])}
+	return TextEdit{Range: Range{Start: start, End: end}, NewText: ">"}, true
+}
+
+// chatContext collects what is sent along with the chat question prompt on
+// lineIdx of d according to the session's context mode: the prompt data,
+// extra system messages (including @-mentions) and the question itself
+// (without "/file <path>").
+func (s *Server) chatContext(d *document, lineIdx int, style chatReplyStyle, prompt string) (prompts.Data, []llm.Message, string, error) {
+	data := prompts.Data{File: d.uri, Language: d.language()}
+	var extra []llm.Message
+	if cmd, ok := parseChatCommand(prompt); ok && cmd.name == "file" {
+		path, question := fileArgs(cmd.args)
+		if path == "" {
+			return data, nil, "", fmt.Errorf("usage: /file <path> [question]")
+		}
+		text, err := s.chatFile(d.uri, path, true)
+		if err != nil {
+			return data, nil, "", err
+		}
+		extra = append(extra, s.fileMessage(path, text))
+		prompt = question
+	}
+	extra = append(extra, s.mentionMessages(d.uri, s.chatMentionText(d, lineIdx, prompt), prompt)...)
+	mode := s.chatSession(d.uri).context
+	if mode == chatContextNone {
+		return data, extra, prompt, nil
+	}
+	if style != plainReplyStyle {
+		data.Function = functionSnippet(d.lines, lineIdx)
+	}
+	switch mode {
+	case chatContextFunction:
+		if td, line := s.chatCodeTarget(d, lineIdx); td != nil && data.Function == "" {
+			extra = append(extra, llm.Message{Role: "system", Content: "Code around the question in " + uriToPath(td.uri) + ":\n" + functionSnippet(td.lines, line)})
+		}
+	case chatContextFull:
+		if td, _ := s.chatCodeTarget(d, lineIdx); td != nil {
+			extra = append(extra, s.fileMessage(uriToPath(td.uri), td.Text()))
+		}
+	}
+	if similar := s.similarChunks(prompt, d.uri); similar != "" {
+		extra = append(extra, llm.Message{Role: "system", Content: "Related code from the workspace, for reference only:\n" + similar})
+	}
+	return data, extra, prompt, nil
+}
+
+// chatMentionText returns the text whose @-mentions are attached to a chat
+// question: the question and, outside chat sessions, the earlier questions
+// of its history, so follow-ups keep the files and symbols mentioned before.
+func (s *Server) chatMentionText(d *document, lineIdx int, prompt string) string {
+	if isChatSession(d.uri) {
+		return prompt
+	}
+	var b strings.Builder
+	for _, m := range s.buildChatHistory(d.uri, lineIdx, prompt) {
+		if m.Role == "user" {
+			b.WriteString(m.Content + "\n")
+		}
+	}
+	return b.String()
+}
+
+// chatCodeTarget returns the document and line a chat question is about:
+// the question's own document, or for chat sessions the document of the
+// last cursor position outside of them.
+func (s *Server) chatCodeTarget(d *document, lineIdx int) (*document, int) {
+	if !isChatSession(d.uri) {
+		return d, lineIdx
+	}
+	s.mu.RLock()
+	uri, line := s.lastCursorURI, s.lastCursorRange.Start.Line
+	s.mu.RUnlock()
+	if uri == "" || isChatSession(uri) || s.excluded(uri) {
+		return nil, 0
+	}
+	td := s.getDocument(uri)
+	if td == nil || len(td.lines) == 0 {
+		return nil, 0
+	}
+	return td, min(line, len(td.lines)-1)
+}
+
+// chatFile returns the contents of path for /file, relative to the
+// workspace root (or the directory of uri without one). Unless anywhere is
+// set, path must stay inside that directory, since it may come from text
+// the user did not type. Open documents are preferred to the file on disk.
+func (s *Server) chatFile(uri, path string, anywhere bool) (string, error) {
+	s.mu.RLock()
+	base := s.root
+	s.mu.RUnlock()
+	if base == "" {
+		base = filepath.Dir(uriToPath(uri))
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(base, path)
+	}
+	path = filepath.Clean(path)
+	if rel, err := filepath.Rel(base, path); !anywhere && (err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))) {
+		return "", fmt.Errorf("%s is outside the workspace", path)
+	}
+	fileURI := pathToURI(path)
+	if s.excluded(fileURI) {
+		return "", errExcluded(fileURI)
+	}
+	if fd := s.getDocument(fileURI); fd != nil {
+		return fd.Text(), nil
+	}
+	if s.config().noDiskIO {
+		return "", fmt.Errorf("cannot read %s: no_disk_io is set and the file is not open", path)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot read %s: %v", path, err)
+	}
+	return string(b), nil
+}
+
+// fileMessage wraps file contents for the prompt, capped at the context
+// token budget.
+func (s *Server) fileMessage(path, text string) llm.Message {
+	if limit := s.config().maxContextTokens * 4; limit > 0 && len(text) > limit {
+		text = text[:limit] + "\n[truncated]"
+	}
+	return llm.Message{Role: "system", Content: "Contents of " + path + ":\n" + text}
+}
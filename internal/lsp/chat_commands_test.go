@@ -0,0 +1,182 @@
+// Summary: Tests for in-editor chat slash commands (/model, /context, /clear, /file, /retry).
+package lsp
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"hexai/internal/llm"
+)
+
+// chatRecordingLLM records the messages and model of every request.
+type chatRecordingLLM struct {
+	mu     sync.Mutex
+	resp   string
+	msgs   [][]llm.Message
+	models []string
+}
+
+func (f *chatRecordingLLM) Chat(_ context.Context, msgs []llm.Message, opts ...llm.RequestOption) (string, error) {
+	o := llm.Options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.msgs = append(f.msgs, msgs)
+	f.models = append(f.models, o.Model)
+	return f.resp, nil
+}
+func (f *chatRecordingLLM) Name() string         { return "fake" }
+func (f *chatRecordingLLM) DefaultModel() string { return "m" }
+
+func (f *chatRecordingLLM) requests() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.msgs)
+}
+
+// chatEditor runs detectAndHandleChat on a server whose client applies all
+// edits, like an editor sending didChange after each edit.
+func chatEditor(t *testing.T, f llm.Client, uri, text string) *Server {
+	t.Helper()
+	pr, pw := io.Pipe()
+	t.Cleanup(func() { pw.Close() })
+	s := newTestServer()
+	s.out = pw
+	setConfig(s, func(c *serverConfig) { c.llmClient = f })
+	s.setDocument(uri, text)
+	go fakeEditorClient(s, pr, func(int) { go s.detectAndHandleChat(uri) })
+	return s
+}
+
+// waitForText waits until the document uri reads want.
+func waitForText(t *testing.T, s *Server, uri, want string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for s.getDocument(uri).Text() != want && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := s.getDocument(uri).Text(); got != want {
+		t.Fatalf("unexpected document:\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestParseChatCommand(t *testing.T) {
+	cmd, ok := parseChatCommand("/model gpt-4.1?")
+	if !ok || cmd.name != "model" || trimTriggerPunct(cmd.args) != "gpt-4.1" {
+		t.Fatalf("unexpected /model parse: %#v %v", cmd, ok)
+	}
+	if cmd, ok := parseChatCommand("/clear?"); !ok || cmd.name != "clear" {
+		t.Fatalf("unexpected /clear parse: %#v %v", cmd, ok)
+	}
+	if _, ok := parseChatCommand("/etc/hosts, what is it for?"); ok {
+		t.Fatalf("paths are questions, not commands")
+	}
+	if path, q := fileArgs("internal/llm/openai.go explain the stream parser?"); path != "internal/llm/openai.go" || q != "explain the stream parser?" {
+		t.Fatalf("unexpected /file args %q %q", path, q)
+	}
+}
+
+func TestChatCommand_ModelAppliesToTheSession(t *testing.T) {
+	f := &chatRecordingLLM{resp: "Fine."}
+	uri := "file:///notes.md"
+	s := chatEditor(t, f, uri, "/model gpt-4.1?>")
+	s.detectAndHandleChat(uri)
+	waitForText(t, s, uri, "/model gpt-4.1?\n\n> Model for this chat: fake:gpt-4.1\n\n")
+	if f.requests() != 0 {
+		t.Fatalf("commands must not reach the LLM")
+	}
+
+	s.setDocument(uri, s.getDocument(uri).Text()+"Hi!>")
+	s.detectAndHandleChat(uri)
+	waitForText(t, s, uri, "/model gpt-4.1?\n\n> Model for this chat: fake:gpt-4.1\n\nHi!\n\n> Fine.\n\n")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.models[0] != "gpt-4.1" {
+		t.Fatalf("expected the session model, got %q", f.models[0])
+	}
+	for _, m := range f.msgs[0] {
+		if strings.Contains(m.Content, "/model") || strings.Contains(m.Content, "Model for this chat") {
+			t.Fatalf("command sent as history: %#v", f.msgs[0])
+		}
+	}
+	if other := s.chatModelFor("file:///other.md"); other != "m" {
+		t.Fatalf("the model must only apply to its document, got %q", other)
+	}
+}
+
+func TestChatCommand_ClearEndsHistory(t *testing.T) {
+	s := newTestServer()
+	uri := "file:///notes.md"
+	s.setDocument(uri, "Old?\n\n> Old answer.\n\n/clear?\n\n> Conversation cleared.\n\nNew?>")
+	if hist := s.buildChatHistory(uri, 8, "New?"); len(hist) != 1 {
+		t.Fatalf("expected no history before /clear, got %#v", hist)
+	}
+	msgs := chatTranscript(s.getDocument(uri).lines[:8], "New?")
+	if len(msgs) != 1 || msgs[0].Content != "New?" {
+		t.Fatalf("expected no session transcript before /clear, got %#v", msgs)
+	}
+}
+
+func TestChatCommand_FileIncludesContents(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "calc.go"), []byte("package calc\n\nfunc Add(a, b int) int { return a + b }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f := &chatRecordingLLM{resp: "It adds."}
+	uri := "file:///notes.md"
+	s := chatEditor(t, f, uri, "/file calc.go what does Add do?>")
+	s.root = root
+	s.detectAndHandleChat(uri)
+	waitForText(t, s, uri, "/file calc.go what does Add do?\n\n> It adds.\n\n")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	msgs := f.msgs[0]
+	var sawFile bool
+	for _, m := range msgs {
+		sawFile = sawFile || (m.Role == "system" && strings.Contains(m.Content, "func Add(a, b int) int"))
+	}
+	if !sawFile || msgs[len(msgs)-1].Content != "what does Add do?" {
+		t.Fatalf("expected the file contents and the bare question, got %#v", msgs)
+	}
+}
+
+func TestChatCommand_RetryRegeneratesInPlace(t *testing.T) {
+	f := &chatRecordingLLM{resp: "New answer."}
+	uri := "file:///a.go"
+	s := chatEditor(t, f, uri, "func f() {\n\t// Why?\n\t// > Old answer.\n\t// /retry?>\n\treturn\n}")
+	s.detectAndHandleChat(uri)
+	waitForText(t, s, uri, "func f() {\n\t// Why?\n\t// > New answer.\n\treturn\n}")
+	if n := f.requests(); n != 1 {
+		t.Fatalf("expected one regenerated answer, got %d requests", n)
+	}
+}
+
+func TestChatCommand_ContextFullSendsTheCursorFile(t *testing.T) {
+	s := newTestServer()
+	s.setDocument("file:///a.go", "package a\n\nfunc A() {}\n")
+	s.noteCursor("file:///a.go", Range{Start: Position{Line: 2}})
+	sess := "file:///c.hexai.md"
+	s.setDocument(sess, "# Hexai chat\n\nWhat does A do?>")
+	if reply := s.chatCommandReply(sess, chatCommand{name: "context", args: "full?"}); reply != "Context for this chat: full" {
+		t.Fatalf("unexpected reply %q", reply)
+	}
+	_, extra, _, err := s.chatContext(s.getDocument(sess), 2, plainReplyStyle, "What does A do?")
+	if err != nil || len(extra) != 1 || !strings.Contains(extra[0].Content, "Contents of /a.go:\npackage a") {
+		t.Fatalf("expected the cursor file, got %#v (%v)", extra, err)
+	}
+	s.chatCommandReply(sess, chatCommand{name: "context", args: "none"})
+	if _, extra, _, _ = s.chatContext(s.getDocument(sess), 2, plainReplyStyle, "What does A do?"); len(extra) != 0 {
+		t.Fatalf("expected no context, got %#v", extra)
+	}
+	if reply := s.chatCommandReply(sess, chatCommand{name: "context", args: "everything"}); !strings.HasPrefix(reply, "Unknown context") {
+		t.Fatalf("unexpected reply %q", reply)
+	}
+}
@@ -0,0 +1,94 @@
+// Summary: Language-aware in-editor chat: questions written as line comments get their replies as comment lines at the same indentation.
+package lsp
+
+import "strings"
+
+// lineCommentMarkers lists the line comment markers per LSP language id.
+// Languages without line comments (e.g. markdown) map to nil, so their
+// chat stays raw "> " lines.
+var lineCommentMarkers = map[string][]string{
+	"go": {"//"}, "rust": {"//"}, "c": {"//"}, "cpp": {"//"}, "java": {"//"},
+	"javascript": {"//"}, "typescript": {"//"}, "javascriptreact": {"//"}, "typescriptreact": {"//"},
+	"csharp": {"//"}, "kotlin": {"//"}, "swift": {"//"}, "scala": {"//"}, "dart": {"//"}, "zig": {"//"},
+	"php":    {"//", "#"},
+	"python": {"#"}, "ruby": {"#"}, "shellscript": {"#"}, "perl": {"#"}, "r": {"#"}, "elixir": {"#"},
+	"yaml": {"#"}, "toml": {"#"}, "makefile": {"#"}, "dockerfile": {"#"}, "nix": {"#"},
+	"lua": {"--"}, "sql": {"--"}, "haskell": {"--"},
+	"markdown": nil, "plaintext": nil,
+}
+
+// defaultCommentMarkers apply to languages not listed in lineCommentMarkers.
+var defaultCommentMarkers = []string{"//", "#", "--"}
+
+// chatComment splits line into indentation, comment marker and text when it
+// is a line comment in lang. Repeated marker characters and "!" belong to
+// the marker, so "///" and "//!" are recognised as well.
+func chatComment(lang, line string) (indent, marker, text string, ok bool) {
+	markers, known := lineCommentMarkers[lang]
+	if !known {
+		markers = defaultCommentMarkers
+	}
+	rest := strings.TrimLeft(line, " \t")
+	indent = line[:len(line)-len(rest)]
+	for _, m := range markers {
+		if !strings.HasPrefix(rest, m) {
+			continue
+		}
+		n := len(m)
+		for n < len(rest) && (rest[n] == m[len(m)-1] || rest[n] == '!') {
+			n++
+		}
+		return indent, rest[:n], strings.TrimSpace(rest[n:]), true
+	}
+	return "", "", "", false
+}
+
+// chatText returns the chat content of line: the comment text for line
+// comments in lang, otherwise the trimmed line.
+func chatText(lang, line string) string {
+	if _, _, text, ok := chatComment(lang, line); ok {
+		return text
+	}
+	return strings.TrimSpace(line)
+}
+
+// chatReplyStyle describes how a reply is laid out below its question.
+type chatReplyStyle struct {
+	prefix string // starts every reply line
+	blank  bool   // blank lines separate the reply from the question and what follows
+}
+
+// plainReplyStyle is used for questions that are not comments.
+var plainReplyStyle = chatReplyStyle{prefix: "> ", blank: true}
+
+// replyStyle returns the layout for replies to question in lang: comment
+// questions get "> " comment lines at their indentation directly below, so
+// the file stays valid source.
+func replyStyle(lang, question string) chatReplyStyle {
+	if indent, marker, _, ok := chatComment(lang, question); ok {
+		return chatReplyStyle{prefix: indent + marker + " > "}
+	}
+	return plainReplyStyle
+}
+
+// render formats reply text as prefixed lines.
+func (st chatReplyStyle) render(text string) string {
+	return st.prefix + strings.ReplaceAll(text, "\n", "\n"+st.prefix)
+}
+
+// opening separates the question line from the first reply line.
+func (st chatReplyStyle) opening() string {
+	if st.blank {
+		return "\n\n"
+	}
+	return "\n"
+}
+
+// closing ends a finished reply; plain replies get a trailing blank line so
+// the cursor lands on a fresh line.
+func (st chatReplyStyle) closing() string {
+	if st.blank {
+		return "\n\n"
+	}
+	return ""
+}
@@ -0,0 +1,81 @@
+// Summary: Tests for comment-prefixed in-editor chat in source files.
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplyStyle_CommentQuestions(t *testing.T) {
+	cases := []struct {
+		lang, line, prefix string
+	}{
+		{"go", "\t// What does this do?>", "\t// > "},
+		{"rust", "    /// Why?>", "    /// > "},
+		{"python", "  # Why?>", "  # > "},
+		{"lua", "-- Why?>", "-- > "},
+		{"", "# Why?>", "# > "},
+		{"go", "What is Go?>", "> "},
+		{"markdown", "# Why?>", "> "},
+	}
+	for _, c := range cases {
+		if got := replyStyle(c.lang, c.line).prefix; got != c.prefix {
+			t.Fatalf("%s %q: want prefix %q got %q", c.lang, c.line, c.prefix, got)
+		}
+	}
+	if got := chatText("go", "\t// > It sums."); got != "> It sums." {
+		t.Fatalf("unexpected chat text %q", got)
+	}
+}
+
+func TestDetectAndHandleChat_CommentQuestionGetsCommentReply(t *testing.T) {
+	s := newTestServer()
+	setConfig(s, func(c *serverConfig) { c.llmClient = fakeLLM{resp: "It sets x.\nNothing else."} })
+	msgs := clientPipe(t, s)
+	uri := "file:///a.go"
+	text := "package a\n\nfunc f() {\n\t// What does this do?>\n\tx := 1\n}"
+	s.setDocument(uri, text)
+	s.detectAndHandleChat(uri)
+	var p ApplyWorkspaceEditParams
+	_ = json.Unmarshal(nextMethod(t, msgs, "workspace/applyEdit")["params"], &p)
+	got := applyTestEdits(text, p.Edit.Changes[uri])
+	want := "package a\n\nfunc f() {\n\t// What does this do?\n\t// > It sets x.\n\t// > Nothing else.\n\tx := 1\n}"
+	if got != want {
+		t.Fatalf("unexpected document:\n%q\nwant\n%q", got, want)
+	}
+
+	// The comment pair is history for the next question below it.
+	s.setDocument(uri, strings.Replace(want, "\tx := 1", "\t// And then?>", 1))
+	hist := s.buildChatHistory(uri, 6, "And then?")
+	if len(hist) != 3 || hist[0].Content != "What does this do" || hist[1].Content != "It sets x.\nNothing else." {
+		t.Fatalf("unexpected history: %#v", hist)
+	}
+}
+
+func TestStreamChatReply_CommentQuestion(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	s := newTestServer()
+	s.out = pw
+	uri := "file:///a.go"
+	s.setDocument(uri, "func f() {\n\t// Why?>\n\treturn\n}")
+	st := &chunkStreamer{chunks: []string{"Because", "\nit is.\n"}, pause: 2 * chatStreamFlushInterval}
+	setConfig(s, func(c *serverConfig) { c.llmClient = st })
+	go fakeEditorClient(s, pr, nil)
+	s.streamChatReply(context.Background(), st, nil, uri, 1, "\t// Why?>", len("\t// Why?"))
+	want := "func f() {\n\t// Why?\n\t// > Because\n\t// > it is.\n\treturn\n}"
+	deadline := time.Now().Add(time.Second)
+	for s.getDocument(uri).Text() != want && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := s.getDocument(uri).Text(); got != want {
+		t.Fatalf("unexpected document after stream:\n%q\nwant\n%q", got, want)
+	}
+	if strings.Contains(s.getDocument(uri).Text(), "\n> ") {
+		t.Fatalf("raw reply lines in a Go file")
+	}
+}
@@ -0,0 +1,351 @@
+// Summary: Chat sessions in dedicated *.hexai.md documents: the whole transcript is the conversation, older turns are summarised to fit the budget, and hexai.newChat/hexai.openChat open sessions via window/showDocument.
+package lsp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"hexai/internal/llm"
+	"hexai/internal/logging"
+	"hexai/internal/prompts"
+)
+
+const (
+	// chatSessionExt marks documents whose whole transcript is the conversation.
+	chatSessionExt = ".hexai.md"
+	// chatSessionTitle starts the header line written by hexai.newChat; it is
+	// not part of the conversation.
+	chatSessionTitle = "# Hexai chat"
+	// chatSummaryCacheSize bounds the remembered transcript summaries.
+	chatSummaryCacheSize = 32
+)
+
+// isChatSession reports whether uri is a chat session document.
+func isChatSession(uri string) bool {
+	return strings.HasSuffix(uri, chatSessionExt)
+}
+
+// sessionHistory returns the conversation of the chat session uri up to the
+// question on lineIdx, summarising older turns when the transcript exceeds
+// the chat history budget.
+func (s *Server) sessionHistory(ctx context.Context, uri string, lineIdx int, prompt string) []llm.Message {
+	d := s.getDocument(uri)
+	if d == nil || lineIdx > len(d.lines) {
+		return []llm.Message{{Role: "user", Content: prompt}}
+	}
+	return s.fitChatHistory(ctx, uri, chatTranscript(d.lines[:lineIdx], prompt))
+}
+
+// chatTranscript turns the lines of a chat session into alternating
+// messages: "> " blocks are assistant replies, everything else is written by
+// the user. Slash commands are applied as in buildChatHistory. prompt, the
+// question being asked, ends the transcript and joins the user text written
+// since the last reply.
+func chatTranscript(lines []string, prompt string) []llm.Message {
+	var msgs []llm.Message
+	var block []string
+	role := "user"
+	skipReply := false // the reply confirms a slash command
+	flush := func() {
+		text := strings.TrimSpace(strings.Join(block, "\n"))
+		block = nil
+		if text == "" {
+			return
+		}
+		if role == "assistant" {
+			if !skipReply {
+				msgs = append(msgs, llm.Message{Role: role, Content: text})
+			}
+			skipReply = false
+			return
+		}
+		text, keep, stop := historyTurn(text)
+		if stop {
+			msgs = nil
+		}
+		if skipReply = !keep; keep {
+			msgs = append(msgs, llm.Message{Role: role, Content: text})
+		}
+	}
+	for i, line := range lines {
+		if i == 0 && strings.HasPrefix(line, chatSessionTitle) {
+			continue
+		}
+		trimmed := strings.TrimLeft(line, " \t")
+		lineRole := "user"
+		if strings.HasPrefix(trimmed, ">") {
+			lineRole = "assistant"
+			line = strings.TrimPrefix(strings.TrimPrefix(trimmed, ">"), " ")
+		} else if strings.TrimSpace(line) == "" {
+			block = append(block, "") // blank lines belong to the current block
+			continue
+		}
+		if lineRole != role {
+			flush()
+			role = lineRole
+		}
+		block = append(block, line)
+	}
+	if role != "user" {
+		flush()
+		role = "user"
+	}
+	block = append(block, prompt)
+	flush()
+	return msgs
+}
+
+// fitChatHistory keeps msgs within the chat history budget. The newest turns
+// are kept verbatim and older ones replaced by a summary, which is cached so
+// that following questions reuse it while the rest still fits.
+func (s *Server) fitChatHistory(ctx context.Context, uri string, msgs []llm.Message) []llm.Message {
+	cfg := s.config()
+	budget := cfg.chatHistoryTokens
+	if messagesTokens(msgs) <= budget || len(msgs) < 2 {
+		return msgs
+	}
+	var prev string
+	prevCut := 0
+	for k := len(msgs) - 1; k > 0; k-- {
+		if sum, ok := s.chatSummary(msgs[:k]); ok {
+			if approxTokens(sum)+messagesTokens(msgs[k:]) <= budget {
+				return withChatSummary(sum, msgs[k:])
+			}
+			prev, prevCut = sum, k
+			break
+		}
+	}
+	// Keep the newest turns in half of the budget, and at least the question.
+	cut, kept := len(msgs)-1, approxTokens(msgs[len(msgs)-1].Content)
+	for cut > 0 && kept+approxTokens(msgs[cut-1].Content) <= budget/2 {
+		cut--
+		kept += approxTokens(msgs[cut].Content)
+	}
+	if cut == 0 {
+		return msgs
+	}
+	if prevCut > cut {
+		prev, prevCut = "", 0
+	}
+	data := prompts.Data{File: uri, Language: s.languageFor(uri), Summary: prev, Transcript: renderTranscript(msgs[prevCut:cut])}
+	req := []llm.Message{
+		{Role: "system", Content: cfg.prompts.Render(prompts.ChatSummarySystem, data)},
+		{Role: "user", Content: cfg.prompts.Render(prompts.ChatSummaryUser, data)},
+	}
+	sum, err := s.commandChat(ctx, uri, req)
+	if err != nil || sum == "" {
+		if err != nil {
+			s.llmError("chat summary", err)
+		}
+		return msgs[cut:]
+	}
+	logging.Logf("lsp ", "chat summary of %d messages: %d chars", cut, len(sum))
+	s.storeChatSummary(msgs[:cut], sum)
+	return withChatSummary(sum, msgs[cut:])
+}
+
+func withChatSummary(summary string, msgs []llm.Message) []llm.Message {
+	out := []llm.Message{{Role: "system", Content: "Summary of the earlier conversation:\n" + summary}}
+	return append(out, msgs...)
+}
+
+func messagesTokens(msgs []llm.Message) int {
+	n := 0
+	for _, m := range msgs {
+		n += approxTokens(m.Content)
+	}
+	return n
+}
+
+// renderTranscript formats msgs as plain text for the summary prompt.
+func renderTranscript(msgs []llm.Message) string {
+	var b strings.Builder
+	for i, m := range msgs {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		who := "Developer"
+		if m.Role == "assistant" {
+			who = "Assistant"
+		}
+		b.WriteString(who + ": " + m.Content)
+	}
+	return b.String()
+}
+
+// transcriptKey identifies a transcript prefix by content.
+func transcriptKey(msgs []llm.Message) string {
+	h := sha256.New()
+	for _, m := range msgs {
+		h.Write([]byte(m.Role))
+		h.Write([]byte{0})
+		h.Write([]byte(m.Content))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (s *Server) chatSummary(msgs []llm.Message) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sum, ok := s.chatSummaries[transcriptKey(msgs)]
+	return sum, ok
+}
+
+func (s *Server) storeChatSummary(msgs []llm.Message, sum string) {
+	key := transcriptKey(msgs)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.chatSummaries == nil {
+		s.chatSummaries = make(map[string]string)
+	}
+	if _, ok := s.chatSummaries[key]; !ok {
+		s.chatSummaryOrder = append(s.chatSummaryOrder, key)
+	}
+	s.chatSummaries[key] = sum
+	if len(s.chatSummaryOrder) > chatSummaryCacheSize {
+		delete(s.chatSummaries, s.chatSummaryOrder[0])
+		s.chatSummaryOrder = s.chatSummaryOrder[1:]
+	}
+}
+
+// cmdNewChat creates a chat session in the chat directory and opens it.
+// Arguments: [title] (optional), used in the header line.
+func (s *Server) cmdNewChat(args []json.RawMessage) (any, error) {
+	dir, err := s.sessionDir()
+	if err != nil {
+		return nil, fmt.Errorf("newChat: %v", err)
+	}
+	var title string
+	if len(args) > 0 {
+		if err := json.Unmarshal(args[0], &title); err != nil {
+			return nil, fmt.Errorf("newChat: argument must be a title")
+		}
+	}
+	now := time.Now()
+	header := chatSessionTitle + " " + now.Format("2006-01-02 15:04")
+	if title = strings.TrimSpace(title); title != "" {
+		header = chatSessionTitle + ": " + title
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("newChat: %v", err)
+	}
+	path, err := createSession(dir, now.Format("20060102-150405"), header+"\n\n")
+	if err != nil {
+		return nil, fmt.Errorf("newChat: %v", err)
+	}
+	logging.Logf("lsp ", "chat session created: %s", path)
+	return s.showSession(path, 2)
+}
+
+// createSession writes content to a new session file named after stamp,
+// adding a counter when a session with that name exists.
+func createSession(dir, stamp, content string) (string, error) {
+	for n := 1; ; n++ {
+		name := stamp
+		if n > 1 {
+			name = fmt.Sprintf("%s-%d", stamp, n)
+		}
+		path := filepath.Join(dir, name+chatSessionExt)
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		_, err = f.WriteString(content)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return path, err
+	}
+}
+
+// cmdOpenChat opens a chat session. Arguments: [name] (optional), a file in
+// the chat directory with or without the .hexai.md extension; without
+// arguments the most recently changed session is opened, or a new one
+// created.
+func (s *Server) cmdOpenChat(args []json.RawMessage) (any, error) {
+	dir, err := s.sessionDir()
+	if err != nil {
+		return nil, fmt.Errorf("openChat: %v", err)
+	}
+	var path string
+	if len(args) > 0 {
+		var name string
+		if err := json.Unmarshal(args[0], &name); err != nil || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("openChat: argument must be a session name")
+		}
+		name = filepath.Base(strings.TrimSpace(name))
+		if !strings.HasSuffix(name, chatSessionExt) {
+			name += chatSessionExt
+		}
+		path = filepath.Join(dir, name)
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("openChat: no session %s", name)
+		}
+	} else if path = latestSession(dir); path == "" {
+		return s.cmdNewChat(nil)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("openChat: %v", err)
+	}
+	return s.showSession(path, strings.Count(string(b), "\n"))
+}
+
+// sessionDir returns the chat directory, or why sessions are unavailable.
+func (s *Server) sessionDir() (string, error) {
+	cfg := s.config()
+	dir, noDisk := cfg.chatDir, cfg.noDiskIO
+	if noDisk {
+		return "", fmt.Errorf("chat sessions are stored on disk, which no_disk_io forbids")
+	}
+	if dir == "" {
+		return "", fmt.Errorf("no chat directory available")
+	}
+	return dir, nil
+}
+
+// latestSession returns the most recently modified session in dir, or "".
+func latestSession(dir string) string {
+	matches, _ := filepath.Glob(filepath.Join(dir, "*"+chatSessionExt))
+	type session struct {
+		path string
+		mod  time.Time
+	}
+	var sessions []session
+	for _, m := range matches {
+		if fi, err := os.Stat(m); err == nil && !fi.IsDir() {
+			sessions = append(sessions, session{m, fi.ModTime()})
+		}
+	}
+	if len(sessions) == 0 {
+		return ""
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].mod.After(sessions[j].mod) })
+	return sessions[0].path
+}
+
+// showSession asks the client to open the session at path with the cursor
+// on line, and returns its URI.
+func (s *Server) showSession(path string, line int) (any, error) {
+	uri := pathToURI(path)
+	pos := Position{Line: line}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	params := ShowDocumentParams{URI: uri, TakeFocus: true, Selection: &Range{Start: pos, End: pos}}
+	if _, err := s.callClient(ctx, "window/showDocument", params); err != nil {
+		return nil, fmt.Errorf("cannot open %s: %v", path, err)
+	}
+	return uri, nil
+}
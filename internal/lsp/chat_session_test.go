@@ -0,0 +1,134 @@
+// Summary: Tests for chat session transcripts, history summarisation and the newChat/openChat commands.
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"hexai/internal/llm"
+)
+
+func TestChatTranscript_WholeSessionIsConversation(t *testing.T) {
+	lines := []string{
+		"# Hexai chat 2026-10-18 10:00",
+		"",
+		"What is a slice?",
+		"",
+		"> A view into an array.",
+		">",
+		">     s := a[1:3]",
+		"",
+		"And a map?",
+		"",
+		"> A hash table.",
+		"",
+		"Show me how to",
+	}
+	got := chatTranscript(lines, "iterate over one?")
+	want := []llm.Message{
+		{Role: "user", Content: "What is a slice?"},
+		{Role: "assistant", Content: "A view into an array.\n\n    s := a[1:3]"},
+		{Role: "user", Content: "And a map?"},
+		{Role: "assistant", Content: "A hash table."},
+		{Role: "user", Content: "Show me how to\niterate over one?"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d messages, got %d: %#v", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("message %d: want %#v got %#v", i, want[i], got[i])
+		}
+	}
+}
+
+// summaryLLM returns a fixed summary and records the requests.
+type summaryLLM struct{ reqs [][]llm.Message }
+
+func (f *summaryLLM) Chat(_ context.Context, msgs []llm.Message, _ ...llm.RequestOption) (string, error) {
+	f.reqs = append(f.reqs, msgs)
+	return "they talked about slices", nil
+}
+func (f *summaryLLM) Name() string         { return "fake" }
+func (f *summaryLLM) DefaultModel() string { return "m" }
+
+func TestFitChatHistory_SummarisesOlderTurnsAndReusesSummary(t *testing.T) {
+	f := &summaryLLM{}
+	s := newTestServer()
+	setConfig(s, func(c *serverConfig) {
+		c.llmClient = f
+		c.chatHistoryTokens = 100
+	})
+	long := strings.Repeat("x", 120) // 30 tokens
+	var msgs []llm.Message
+	for i := 0; i < 4; i++ {
+		msgs = append(msgs, llm.Message{Role: "user", Content: long}, llm.Message{Role: "assistant", Content: long})
+	}
+	msgs = append(msgs, llm.Message{Role: "user", Content: "next?"})
+
+	got := s.fitChatHistory(context.Background(), "file:///c.hexai.md", msgs)
+	if len(f.reqs) != 1 {
+		t.Fatalf("expected one summary request, got %d", len(f.reqs))
+	}
+	if got[0].Role != "system" || !strings.Contains(got[0].Content, "they talked about slices") {
+		t.Fatalf("expected the summary first, got %#v", got[0])
+	}
+	if last := got[len(got)-1]; last.Content != "next?" {
+		t.Fatalf("expected the question last, got %#v", last)
+	}
+	if messagesTokens(got) > s.config().chatHistoryTokens {
+		t.Fatalf("history exceeds the budget: %d tokens", messagesTokens(got))
+	}
+
+	// A short follow-up fits next to the cached summary without a new request.
+	msgs = append(msgs, llm.Message{Role: "assistant", Content: "ok"}, llm.Message{Role: "user", Content: "and then?"})
+	got = s.fitChatHistory(context.Background(), "file:///c.hexai.md", msgs)
+	if len(f.reqs) != 1 {
+		t.Fatalf("expected the cached summary to be reused, got %d requests", len(f.reqs))
+	}
+	if !strings.Contains(got[0].Content, "they talked about slices") || got[len(got)-1].Content != "and then?" {
+		t.Fatalf("unexpected history: %#v", got)
+	}
+}
+
+func TestNewChatAndOpenChat_ShowSessionDocument(t *testing.T) {
+	dir := t.TempDir()
+	s := newTestServer()
+	setConfig(s, func(c *serverConfig) { c.chatDir = dir })
+	msgs := clientPipe(t, s)
+
+	res, err := s.cmdNewChat([]json.RawMessage{json.RawMessage(`"slices"`)})
+	if err != nil {
+		t.Fatalf("newChat: %v", err)
+	}
+	uri, _ := res.(string)
+	if !isChatSession(uri) {
+		t.Fatalf("expected a chat session URI, got %q", uri)
+	}
+	var p ShowDocumentParams
+	_ = json.Unmarshal(nextMethod(t, msgs, "window/showDocument")["params"], &p)
+	if p.URI != uri || !p.TakeFocus {
+		t.Fatalf("unexpected showDocument params: %#v", p)
+	}
+	b, err := os.ReadFile(uriToPath(uri))
+	if err != nil || !strings.HasPrefix(string(b), "# Hexai chat: slices\n") {
+		t.Fatalf("unexpected session file %q: %v", b, err)
+	}
+
+	res, err = s.cmdOpenChat(nil)
+	if err != nil || res != uri {
+		t.Fatalf("openChat: expected the latest session %q, got %v (%v)", uri, res, err)
+	}
+	nextMethod(t, msgs, "window/showDocument")
+	name := strings.TrimSuffix(filepath.Base(uriToPath(uri)), chatSessionExt)
+	if res, err = s.cmdOpenChat([]json.RawMessage{json.RawMessage(`"` + name + `"`)}); err != nil || res != uri {
+		t.Fatalf("openChat by name: got %v (%v)", res, err)
+	}
+	if _, err := s.cmdOpenChat([]json.RawMessage{json.RawMessage(`"missing"`)}); err == nil {
+		t.Fatalf("expected an error for a missing session")
+	}
+}
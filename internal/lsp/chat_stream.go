@@ -0,0 +1,244 @@
+// Summary: Streams in-editor chat replies into the document with throttled, re-anchored workspace edits.
+package lsp
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"hexai/internal/llm"
+	"hexai/internal/logging"
+)
+
+const (
+	// chatStreamFlushInterval throttles workspace/applyEdit calls while streaming.
+	chatStreamFlushInterval = 150 * time.Millisecond
+	// chatStreamTimeout bounds a whole streamed reply (much longer than Chat).
+	chatStreamTimeout = 2 * time.Minute
+	// chatStreamSettleAttempts bounds waiting for our own edits to show up in
+	// the document before the final flush gives up.
+	chatStreamSettleAttempts = 40
+)
+
+// chatStream tracks one streamed chat reply. The document is the source of
+// truth: before every edit the question line and the reply block are located
+// again, so edits made elsewhere in the file while streaming are tolerated.
+type chatStream struct {
+	s        *Server
+	uri      string
+	rawLine  string // question line as typed, including the trailing '>'
+	question string // question line after the trailing '>' was removed
+	trigger  int    // byte index of the '>' in rawLine
+	lineHint int    // last known line index of the question
+	style    chatReplyStyle
+
+	mu      sync.Mutex
+	pending strings.Builder // received text not yet written to the document
+	written string          // reply text already present in the document
+	started bool            // whether the '>' was removed and the block opened
+}
+
+func newChatStream(s *Server, uri string, lineIdx int, rawLine string, trigger int) *chatStream {
+	return &chatStream{
+		s:        s,
+		uri:      uri,
+		rawLine:  rawLine,
+		question: rawLine[:trigger] + rawLine[trigger+1:],
+		trigger:  trigger,
+		lineHint: lineIdx,
+		style:    replyStyle(s.settingsFor(uri).language, rawLine),
+	}
+}
+
+// streamChatReply streams the model reply for msgs into the document below
+// the question line, flushing at most every chatStreamFlushInterval. The
+// stream stops when ctx is cancelled.
+func (s *Server) streamChatReply(ctx context.Context, st llm.Streamer, msgs []llm.Message, uri string, lineIdx int, rawLine string, trigger int) {
+	ctx, cancel := context.WithTimeout(ctx, chatStreamTimeout)
+	defer cancel()
+	cs := newChatStream(s, uri, lineIdx, rawLine, trigger)
+
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		cs.flushLoop(ctx, done)
+	}()
+	logging.Logf("lsp ", "chat llm=streaming model=%s", s.chatModelFor(uri))
+	err := st.ChatStream(ctx, msgs, cs.add, s.chatRequestOpts(uri)...)
+	close(done)
+	<-stopped // never flush concurrently with finish
+	if err != nil {
+		s.llmError("chat stream", err)
+	}
+	cs.finish(ctx)
+}
+
+// add buffers a streamed chunk; leading whitespace of the reply is dropped.
+func (cs *chatStream) add(chunk string) {
+	chunk = strings.ReplaceAll(chunk, "\r\n", "\n")
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.written == "" && cs.pending.Len() == 0 {
+		chunk = strings.TrimLeft(chunk, " \t\r\n")
+	}
+	cs.pending.WriteString(chunk)
+}
+
+func (cs *chatStream) flushLoop(ctx context.Context, done <-chan struct{}) {
+	t := time.NewTicker(chatStreamFlushInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			cs.flush(ctx, false)
+		}
+	}
+}
+
+// finish writes any remaining text and closes the reply block (plain replies
+// with a trailing blank line), retrying until earlier edits are visible.
+func (cs *chatStream) finish(ctx context.Context) {
+	for i := 0; i < chatStreamSettleAttempts; i++ {
+		if cs.flush(ctx, true) {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	logging.Logf("lsp ", "chat stream gave up: reply block changed while streaming uri=%s", cs.uri)
+}
+
+// flush writes buffered text into the document. It reports whether the
+// stream is in a consistent state (nothing left to write, or written now).
+// Trailing newlines are held back until more text arrives so the block never
+// ends with an empty "> " line.
+func (cs *chatStream) flush(ctx context.Context, final bool) bool {
+	cs.mu.Lock()
+	text := cs.pending.String()
+	if !final {
+		text = strings.TrimRight(text, "\n")
+	} else {
+		text = strings.TrimRight(text, " \t\r\n")
+	}
+	started, written := cs.started, cs.written
+	cs.mu.Unlock()
+	if text == "" && (!final || !started) {
+		return true
+	}
+	edits, ok := cs.anchoredEdits(started, written, text, final)
+	if !ok {
+		return false // our previous edit is not reflected yet, or the block was edited
+	}
+	actx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	we := WorkspaceEdit{Changes: map[string][]TextEdit{cs.uri: edits}}
+	if err := cs.s.clientApplyEditWait(actx, "Hexai: stream chat response", we); err != nil {
+		logging.Logf("lsp ", "chat stream applyEdit error: %v", err)
+		return false
+	}
+	cs.mu.Lock()
+	rest := strings.TrimPrefix(cs.pending.String(), text)
+	cs.pending.Reset()
+	cs.pending.WriteString(rest)
+	cs.written = written + text
+	cs.started = true
+	cs.mu.Unlock()
+	return true
+}
+
+// anchoredEdits locates the question and the reply block in the current
+// document and returns the edits that append text to it.
+func (cs *chatStream) anchoredEdits(started bool, written, text string, final bool) ([]TextEdit, bool) {
+	d := cs.s.getDocument(cs.uri)
+	if d == nil {
+		return nil, false
+	}
+	if !started {
+		q := findLineNear(d.lines, cs.rawLine, cs.lineHint)
+		if q < 0 {
+			return nil, false
+		}
+		cs.lineHint = q
+		insPos := Position{Line: q, Character: len(d.lines[q])}
+		insert := cs.style.opening() + cs.style.render(text)
+		if final {
+			insert += cs.style.closing()
+		}
+		return []TextEdit{
+			{Range: Range{Start: Position{Line: q, Character: cs.trigger}, End: Position{Line: q, Character: cs.trigger + 1}}, NewText: ""},
+			{Range: Range{Start: insPos, End: insPos}, NewText: insert},
+		}, true
+	}
+	end, ok := cs.locateBlockEnd(d, written)
+	if !ok {
+		return nil, false
+	}
+	insert := ""
+	if text != "" {
+		insert = strings.ReplaceAll(text, "\n", "\n"+cs.style.prefix)
+	}
+	if final {
+		insert += cs.style.closing()
+	}
+	return []TextEdit{{Range: Range{Start: end, End: end}, NewText: insert}}, true
+}
+
+// locateBlockEnd finds the question line and verifies that the reply block
+// below it matches what was written so far; it returns the end position.
+func (cs *chatStream) locateBlockEnd(d *document, written string) (Position, bool) {
+	q := findLineNear(d.lines, cs.question, cs.lineHint)
+	if q < 0 {
+		return Position{}, false
+	}
+	cs.lineHint = q
+	want := splitLines(cs.style.render(written))
+	first := q + 1
+	if cs.style.blank {
+		first++
+	}
+	if first+len(want) > len(d.lines) || (cs.style.blank && strings.TrimSpace(d.lines[q+1]) != "") {
+		return Position{}, false
+	}
+	for i, w := range want {
+		if d.lines[first+i] != w {
+			return Position{}, false
+		}
+	}
+	last := first + len(want) - 1
+	return Position{Line: last, Character: len(d.lines[last])}, true
+}
+
+// renderChatReply formats reply text as "> " prefixed lines.
+func renderChatReply(text string) string {
+	return plainReplyStyle.render(text)
+}
+
+// findLineNear returns the index of the line equal to want that is closest
+// to hint, or -1 when no line matches.
+func findLineNear(lines []string, want string, hint int) int {
+	best := -1
+	for i, l := range lines {
+		if l != want {
+			continue
+		}
+		if best < 0 || absInt(i-hint) < absInt(best-hint) {
+			best = i
+		}
+	}
+	return best
+}
+
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,117 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"hexai/internal/llm"
+)
+
+// chunkStreamer streams fixed chunks through llm.Streamer, pausing between
+// chunks so that several throttled flushes happen.
+type chunkStreamer struct {
+	countingLLM
+	chunks []string
+	pause  time.Duration
+}
+
+func (f *chunkStreamer) ChatStream(_ context.Context, _ []llm.Message, onDelta func(string), _ ...llm.RequestOption) error {
+	for _, c := range f.chunks {
+		onDelta(c)
+		time.Sleep(f.pause)
+	}
+	return nil
+}
+
+// applyTestEdits applies non-overlapping edits (relative to text) to text.
+func applyTestEdits(text string, edits []TextEdit) string {
+	lines := splitLines(text)
+	offset := func(p Position) int {
+		n := 0
+		for i := 0; i < p.Line; i++ {
+			n += len(lines[i]) + 1
+		}
+		return n + p.Character
+	}
+	sorted := append([]TextEdit{}, edits...)
+	sort.Slice(sorted, func(i, j int) bool { return lessPos(sorted[j].Range.Start, sorted[i].Range.Start) })
+	for _, e := range sorted {
+		start, end := offset(e.Range.Start), offset(e.Range.End)
+		text = text[:start] + e.NewText + text[end:]
+	}
+	return text
+}
+
+// fakeEditorClient reads server requests from r, applies workspace edits to
+// the server's document store and acknowledges them. onEdit runs after each
+// applied edit and may simulate user typing.
+func fakeEditorClient(s *Server, r io.Reader, onEdit func(n int)) {
+	reader := &Server{in: bufio.NewReader(r)}
+	n := 0
+	for {
+		body, err := reader.readMessage()
+		if err != nil {
+			return
+		}
+		var req Request
+		if json.Unmarshal(body, &req) != nil || req.Method != "workspace/applyEdit" {
+			continue
+		}
+		var p ApplyWorkspaceEditParams
+		_ = json.Unmarshal(req.Params, &p)
+		for uri, edits := range p.Edit.Changes {
+			s.setDocument(uri, applyTestEdits(s.getDocument(uri).Text(), edits))
+		}
+		n++
+		if onEdit != nil {
+			onEdit(n)
+		}
+		resp, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": map[string]any{"applied": true}})
+		s.deliverResponse(resp)
+	}
+}
+
+func TestStreamChatReply_AppendsAndReanchors(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	s := newTestServer()
+	s.out = pw
+	uri := "file:///chat.md"
+	s.setDocument(uri, "intro\nWhat is Go?>\ntail")
+	st := &chunkStreamer{chunks: []string{"  A language", "\nby Google", ".\n"}, pause: 2 * chatStreamFlushInterval}
+	setConfig(s, func(c *serverConfig) { c.llmClient = st })
+	edits := 0
+	go fakeEditorClient(s, pr, func(n int) {
+		edits = n
+		if n == 1 { // user types above the question while the reply streams
+			s.setDocument(uri, "new first line\n"+s.getDocument(uri).Text())
+		}
+	})
+	s.streamChatReply(context.Background(), st, nil, uri, 1, "What is Go?>", len("What is Go?"))
+	if st.calls != 0 {
+		t.Fatalf("expected streaming path, Chat was called")
+	}
+	got := s.getDocument(uri).Text()
+	if edits < 3 {
+		t.Fatalf("expected several incremental edits, got %d", edits)
+	}
+	want := "new first line\nintro\nWhat is Go?\n\n> A language\n> by Google.\n\n\ntail"
+	if got != want {
+		t.Fatalf("unexpected document after stream:\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestRenderChatReply_PrefixesEveryLine(t *testing.T) {
+	if got := renderChatReply("a\nb"); got != "> a\n> b" {
+		t.Fatalf("got %q", got)
+	}
+	if !strings.HasPrefix(renderChatReply(""), "> ") {
+		t.Fatalf("expected prefix for empty reply")
+	}
+}
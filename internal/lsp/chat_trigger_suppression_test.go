@@ -4,8 +4,12 @@ import "testing"
 
 // Ensure completion is suppressed when a chat trigger is at EOL (?>,!>,:>,;>)
 func TestCompletionSuppressedOnChatTriggerEOL(t *testing.T) {
-	s := &Server{maxTokens: 32, triggerChars: []string{".", ":", "/", "_"}, compCache: make(map[string]string)}
-	s.llmClient = &countingLLM{}
+	s := &Server{compCache: make(map[string]string)}
+	setConfig(s, func(c *serverConfig) {
+		c.maxTokens = 32
+		c.triggerChars = []string{".", ":", "/", "_"}
+		c.llmClient = &countingLLM{}
+	})
 	tests := []string{"What now?>", "Explain!>", "Refactor:>", "note ;>"}
 	for i, line := range tests {
 		p := CompletionParams{Position: Position{Line: 0, Character: len(line)}, TextDocument: TextDocumentIdentifier{URI: "file://chat-suppr.go"}}
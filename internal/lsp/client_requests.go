@@ -0,0 +1,88 @@
+// Summary: Server-initiated JSON-RPC requests to the client and routing of the client's responses.
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// clientResponse is a JSON-RPC response sent by the client for a request the
+// server initiated (e.g., workspace/applyEdit).
+type clientResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *RespError      `json:"error,omitempty"`
+}
+
+// callClient sends a request to the client and waits for its response or
+// until ctx is done. The raw result is returned on success.
+func (s *Server) callClient(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	id := s.nextReqID()
+	ch := make(chan clientResponse, 1)
+	s.mu.Lock()
+	if s.pending == nil {
+		s.pending = make(map[string]chan clientResponse)
+	}
+	s.pending[string(id)] = ch
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, string(id))
+		s.mu.Unlock()
+	}()
+
+	b, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	s.writeMessage(Request{JSONRPC: "2.0", ID: id, Method: method, Params: b})
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return nil, fmt.Errorf("%s: client error %d: %s", method, resp.Error.Code, resp.Error.Message)
+		}
+		return resp.Result, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// deliverResponse hands a client response to the waiting callClient, if any.
+// Responses nobody waits for (fire-and-forget requests) are dropped.
+func (s *Server) deliverResponse(body []byte) {
+	var resp clientResponse
+	if err := json.Unmarshal(body, &resp); err != nil || len(resp.ID) == 0 {
+		return
+	}
+	s.mu.Lock()
+	ch, ok := s.pending[string(resp.ID)]
+	s.mu.Unlock()
+	if ok {
+		ch <- resp
+	}
+}
+
+// clientApplyEditWait sends workspace/applyEdit and waits for the client to
+// report whether the edit was applied.
+func (s *Server) clientApplyEditWait(ctx context.Context, label string, edit WorkspaceEdit) error {
+	raw, err := s.callClient(ctx, "workspace/applyEdit", ApplyWorkspaceEditParams{Label: label, Edit: edit})
+	if err != nil {
+		return err
+	}
+	var res struct {
+		Applied       bool   `json:"applied"`
+		FailureReason string `json:"failureReason,omitempty"`
+	}
+	if len(raw) > 0 {
+		_ = json.Unmarshal(raw, &res)
+	}
+	if !res.Applied {
+		if res.FailureReason != "" {
+			return errors.New("applyEdit rejected: " + res.FailureReason)
+		}
+		return errors.New("applyEdit rejected")
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+// Summary: "Add documentation comment" code action; finds the enclosing declaration and inserts or replaces its doc comment.
+package lsp
+
+import (
+	"context"
+	"errors"
+	"path"
+	"strings"
+	"time"
+
+	"hexai/internal/llm"
+	"hexai/internal/prompts"
+)
+
+// docDeclMaxLines caps the declaration code sent to the LLM.
+const docDeclMaxLines = 80
+
+// docSpot locates where the doc comment of a declaration goes. When the
+// declaration already has one, lines [start, end] hold it; otherwise end is
+// start-1 and the comment is inserted before line start.
+type docSpot struct {
+	lang     string
+	decl     int
+	start    int
+	end      int
+	indent   string
+	existing string
+}
+
+// docLanguage returns the language for doc comments by file extension, or
+// "" when the language is not supported.
+func docLanguage(uri string) string {
+	switch path.Ext(uri) {
+	case ".go":
+		return "Go"
+	case ".py":
+		return "Python"
+	case ".rs":
+		return "Rust"
+	}
+	return ""
+}
+
+func (s *Server) buildDocCodeAction(p CodeActionParams, d *document) *CodeAction {
+	spot, ok := findDocSpot(d.lines, docLanguage(p.TextDocument.URI), p.Range.Start.Line)
+	if !ok {
+		return nil
+	}
+	title := "Hexai: add documentation comment"
+	if spot.existing != "" {
+		title = "Hexai: update documentation comment"
+	}
+	return s.buildCodeActionWithPayload(title, "refactor", codeActionPayload{
+		Type: "document", URI: p.TextDocument.URI, Range: p.Range,
+	})
+}
+
+// resolveDocAction locates the declaration again in the current document,
+// asks the LLM for a doc comment and returns the edit placing it.
+func (s *Server) resolveDocAction(ctx context.Context, uri string, r Range) (*WorkspaceEdit, error) {
+	cfg := s.config()
+	if cfg.llmClient == nil {
+		return nil, errLLMDisabled
+	}
+	d := s.getDocument(uri)
+	if d == nil {
+		return nil, errors.New("document not open")
+	}
+	spot, ok := findDocSpot(d.lines, docLanguage(uri), r.Start.Line)
+	if !ok {
+		return nil, errors.New("no enclosing declaration")
+	}
+	end := declarationEnd(d.lines, spot.decl, docDeclMaxLines)
+	data := prompts.Data{
+		File: uri, Language: spot.lang, DocComment: spot.existing,
+		Selection: strings.Join(d.lines[spot.decl:end+1], "\n"),
+	}
+	msgs := []llm.Message{
+		{Role: "system", Content: cfg.prompts.Render(prompts.DocSystem, data)},
+		{Role: "user", Content: cfg.prompts.Render(prompts.DocUser, data)},
+	}
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	text, err := cfg.llmClient.Chat(ctx, msgs, s.llmRequestOpts(uri)...)
+	if err != nil {
+		return nil, err
+	}
+	comment := formatDocComment(spot.lang, stripCodeFences(strings.TrimSpace(text)), spot.indent)
+	if comment == "" {
+		return nil, errors.New("empty doc comment")
+	}
+	rng := Range{Start: Position{Line: spot.start}, End: Position{Line: spot.end + 1}}
+	switch {
+	case spot.start >= len(d.lines):
+		// a signature on the last line: the docstring starts a new line
+		last := len(d.lines) - 1
+		rng = Range{Start: Position{Line: last, Character: len(d.lines[last])}}
+		rng.End = rng.Start
+		comment = "\n" + strings.TrimSuffix(comment, "\n")
+	case spot.end+1 >= len(d.lines):
+		rng.End = Position{Line: spot.end, Character: len(d.lines[spot.end])}
+		comment = strings.TrimSuffix(comment, "\n")
+	}
+	return &WorkspaceEdit{Changes: map[string][]TextEdit{uri: {{Range: rng, NewText: comment}}}}, nil
+}
+
+// findDocSpot finds the declaration enclosing line and where its doc
+// comment lives: above it for Go and Rust, as the first body statement for
+// Python.
+func findDocSpot(lines []string, lang string, line int) (docSpot, bool) {
+	if lang == "" {
+		return docSpot{}, false
+	}
+	decl := enclosingDeclaration(lines, line)
+	if decl < 0 {
+		return docSpot{}, false
+	}
+	if lang == "Python" {
+		return pythonDocSpot(lines, decl)
+	}
+	spot := docSpot{lang: lang, decl: decl, indent: leadingIndent(lines[decl])}
+	i := decl - 1
+	for i >= 0 && isDocDirective(lang, strings.TrimSpace(lines[i])) {
+		i--
+	}
+	j := i
+	for j >= 0 && isDocLine(lang, strings.TrimSpace(lines[j])) {
+		j--
+	}
+	spot.start, spot.end = j+1, i
+	if j < i {
+		spot.existing = strings.Join(lines[j+1:i+1], "\n")
+	} else {
+		spot.start, spot.end = i+1, i // insert above directives/attributes
+	}
+	return spot, true
+}
+
+// isDocDirective reports lines that sit between a doc comment and its
+// declaration (Go compiler directives, Rust attributes).
+func isDocDirective(lang, t string) bool {
+	if lang == "Rust" {
+		return strings.HasPrefix(t, "#[")
+	}
+	return strings.HasPrefix(t, "//go:") || strings.HasPrefix(t, "//nolint")
+}
+
+func isDocLine(lang, t string) bool {
+	if lang == "Rust" {
+		return strings.HasPrefix(t, "///")
+	}
+	return strings.HasPrefix(t, "//")
+}
+
+// pythonDocSpot locates the docstring slot after the (possibly multi-line)
+// signature of the def/class at decl. One-line definitions, whose body
+// follows the colon, have no slot.
+func pythonDocSpot(lines []string, decl int) (docSpot, bool) {
+	sigEnd := decl
+	for sigEnd < len(lines)-1 && sigEnd-decl < 20 && !strings.HasSuffix(strings.TrimSpace(lines[sigEnd]), ":") {
+		sigEnd++
+	}
+	if !strings.HasSuffix(strings.TrimSpace(lines[sigEnd]), ":") {
+		return docSpot{}, false
+	}
+	spot := docSpot{lang: "Python", decl: decl, start: sigEnd + 1, end: sigEnd, indent: leadingIndent(lines[decl]) + "    "}
+	k := sigEnd + 1
+	for k < len(lines) && strings.TrimSpace(lines[k]) == "" {
+		k++
+	}
+	if k >= len(lines) || len(leadingIndent(lines[k])) <= len(leadingIndent(lines[decl])) {
+		return spot, true
+	}
+	spot.indent = leadingIndent(lines[k])
+	t := strings.TrimSpace(lines[k])
+	quote := ""
+	for _, q := range []string{`"""`, `'''`} {
+		if strings.HasPrefix(strings.TrimLeft(t, "rRuU"), q) {
+			quote = q
+		}
+	}
+	if quote == "" {
+		return spot, true
+	}
+	end := k
+	if strings.Count(t, quote) < 2 {
+		for end = k + 1; end < len(lines) && !strings.Contains(lines[end], quote); end++ {
+		}
+		end = min(end, len(lines)-1)
+	}
+	spot.start, spot.end = k, end
+	spot.existing = strings.Join(lines[k:end+1], "\n")
+	return spot, true
+}
+
+// formatDocComment normalizes the model output into comment lines for lang,
+// indented with indent, each ending in a newline.
+func formatDocComment(lang, text, indent string) string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return ""
+	}
+	lines := splitLines(text)
+	switch lang {
+	case "Go", "Rust":
+		marker := "//"
+		if lang == "Rust" {
+			marker = "///"
+		}
+		for i, l := range lines {
+			l = strings.TrimSpace(l)
+			if !strings.HasPrefix(l, marker) {
+				l = strings.TrimSpace(marker + " " + strings.TrimLeft(l, "/ "))
+			}
+			lines[i] = l
+		}
+	case "Python":
+		if !strings.HasPrefix(text, `"""`) && !strings.HasPrefix(text, `'''`) {
+			lines = splitLines(`"""` + text + `"""`)
+			if len(lines) > 1 {
+				lines = splitLines(`"""` + text + "\n" + `"""`)
+			}
+		}
+	}
+	var b strings.Builder
+	for _, l := range lines {
+		if strings.TrimSpace(l) != "" {
+			b.WriteString(indent)
+			b.WriteString(strings.TrimRight(l, " \t"))
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
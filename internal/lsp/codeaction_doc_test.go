@@ -0,0 +1,122 @@
+package lsp
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEnclosingDeclaration_FindsFuncAndSkipsClosures(t *testing.T) {
+	lines := []string{
+		"package p",
+		"",
+		"func Outer() {",
+		"\tf := func() {",
+		"\t\treturn",
+		"\t}",
+		"}",
+	}
+	if got := enclosingDeclaration(lines, 4); got != 2 {
+		t.Fatalf("want decl at line 2, got %d", got)
+	}
+	if got := enclosingDeclaration(lines, 0); got != -1 {
+		t.Fatalf("want no decl for package line, got %d", got)
+	}
+}
+
+func TestResolveDoc_InsertsGoCommentAboveDirectives(t *testing.T) {
+	uri := "file:///p/a.go"
+	s := newTestServer()
+	setConfig(s, func(c *serverConfig) { c.llmClient = fakeLLM{resp: "Add returns the sum of a and b."} })
+	s.setDocument(uri, "package p\n\n//go:noinline\nfunc Add(a, b int) int {\n\treturn a + b\n}")
+	p := CodeActionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Range: *rangeOf(4, 1, 4, 1)}
+	ca := s.buildDocCodeAction(p, s.getDocument(uri))
+	if ca == nil || ca.Title != "Hexai: add documentation comment" {
+		t.Fatalf("unexpected action: %+v", ca)
+	}
+	resolved, ok := s.resolveCodeAction(context.Background(), *ca)
+	if !ok || resolved.Edit == nil {
+		t.Fatalf("expected resolved edit")
+	}
+	got := applyTestEdits(s.getDocument(uri).Text(), resolved.Edit.Changes[uri])
+	want := "package p\n\n// Add returns the sum of a and b.\n//go:noinline\nfunc Add(a, b int) int {\n\treturn a + b\n}"
+	if got != want {
+		t.Fatalf("unexpected text:\n%s", got)
+	}
+}
+
+func TestResolveDoc_ReplacesExistingRustDoc(t *testing.T) {
+	uri := "file:///p/lib.rs"
+	s := newTestServer()
+	setConfig(s, func(c *serverConfig) {
+		c.llmClient = fakeLLM{resp: "/// Parses the input.\n/// Returns None on error."}
+	})
+	s.setDocument(uri, "/// Old text.\n#[inline]\npub fn parse(s: &str) -> Option<u32> {\n    s.parse().ok()\n}")
+	p := CodeActionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Range: *rangeOf(3, 0, 3, 0)}
+	ca := s.buildDocCodeAction(p, s.getDocument(uri))
+	if ca == nil || ca.Title != "Hexai: update documentation comment" {
+		t.Fatalf("unexpected action: %+v", ca)
+	}
+	resolved, _ := s.resolveCodeAction(context.Background(), *ca)
+	got := applyTestEdits(s.getDocument(uri).Text(), resolved.Edit.Changes[uri])
+	want := "/// Parses the input.\n/// Returns None on error.\n#[inline]\npub fn parse(s: &str) -> Option<u32> {\n    s.parse().ok()\n}"
+	if got != want {
+		t.Fatalf("unexpected text:\n%s", got)
+	}
+}
+
+func TestResolveDoc_PythonDocstringReplacedInBody(t *testing.T) {
+	uri := "file:///p/m.py"
+	s := newTestServer()
+	setConfig(s, func(c *serverConfig) { c.llmClient = fakeLLM{resp: "Return twice x."} })
+	s.setDocument(uri, "class A:\n    def twice(self, x):\n        '''old'''\n        return 2 * x\n")
+	ca := s.buildDocCodeAction(CodeActionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Range: *rangeOf(3, 8, 3, 8)}, s.getDocument(uri))
+	if ca == nil {
+		t.Fatalf("expected doc action")
+	}
+	resolved, _ := s.resolveCodeAction(context.Background(), *ca)
+	got := applyTestEdits(s.getDocument(uri).Text(), resolved.Edit.Changes[uri])
+	want := "class A:\n    def twice(self, x):\n        \"\"\"Return twice x.\"\"\"\n        return 2 * x\n"
+	if got != want {
+		t.Fatalf("unexpected text:\n%q", got)
+	}
+}
+
+func TestResolveDoc_PythonDefOnFinalLine(t *testing.T) {
+	uri := "file:///p/m.py"
+	s := newTestServer()
+	setConfig(s, func(c *serverConfig) { c.llmClient = fakeLLM{resp: "Do nothing yet."} })
+	s.setDocument(uri, "x = 1\ndef f():")
+	ca := s.buildDocCodeAction(CodeActionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Range: *rangeOf(1, 4, 1, 4)}, s.getDocument(uri))
+	if ca == nil {
+		t.Fatalf("expected doc action")
+	}
+	resolved, _ := s.resolveCodeAction(context.Background(), *ca)
+	if resolved.Edit == nil {
+		t.Fatalf("expected an edit")
+	}
+	got := applyTestEdits(s.getDocument(uri).Text(), resolved.Edit.Changes[uri])
+	if want := "x = 1\ndef f():\n    \"\"\"Do nothing yet.\"\"\""; got != want {
+		t.Fatalf("unexpected text:\n%q", got)
+	}
+
+	// a one-line definition has no docstring slot
+	s.setDocument(uri, "x = 1\ndef f(): return 1")
+	if ca := s.buildDocCodeAction(CodeActionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Range: *rangeOf(1, 4, 1, 4)}, s.getDocument(uri)); ca != nil {
+		t.Fatalf("expected no doc action for a one-line def")
+	}
+}
+
+func TestBuildDocCodeAction_CursorInsideDeclAndLanguageGate(t *testing.T) {
+	uri := "file:///p/a.go"
+	s := newTestServer()
+	s.setDocument(uri, "package p\n\nfunc F() {\n\tprintln()\n}")
+	p := CodeActionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Range: *rangeOf(3, 1, 3, 1)}
+	if ca := s.buildDocCodeAction(p, s.getDocument(uri)); ca == nil {
+		t.Fatalf("expected doc action for cursor inside function")
+	}
+	p.TextDocument.URI = "file:///p/notes.md"
+	s.setDocument(p.TextDocument.URI, "# title\n\nfunc F() {\n}")
+	if ca := s.buildDocCodeAction(p, s.getDocument(p.TextDocument.URI)); ca != nil {
+		t.Fatalf("expected no doc action for unsupported language")
+	}
+}
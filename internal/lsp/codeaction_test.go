@@ -20,7 +20,7 @@ func (f fakeLLM) DefaultModel() string { return "fake-model" }
 
 func TestBuildRewriteCodeAction_LazyAndResolves(t *testing.T) {
 	s := newTestServer()
-	s.llmClient = fakeLLM{resp: "REWRITTEN"}
+	setConfig(s, func(c *serverConfig) { c.llmClient = fakeLLM{resp: "REWRITTEN"} })
 	p := CodeActionParams{TextDocument: TextDocumentIdentifier{URI: "file:///t.go"}, Range: Range{Start: Position{Line: 1, Character: 2}, End: Position{Line: 3, Character: 4}}}
 	sel := ";rewrite;\nold code"
 	ca := s.buildRewriteCodeAction(p, sel)
@@ -35,7 +35,7 @@ func TestBuildRewriteCodeAction_LazyAndResolves(t *testing.T) {
 		t.Fatalf("expected data payload for lazy resolve")
 	}
 	// Resolve now
-	resolved, ok := s.resolveCodeAction(*ca)
+	resolved, ok := s.resolveCodeAction(context.Background(), *ca)
 	if !ok || resolved.Edit == nil {
 		t.Fatalf("expected resolve to produce edit")
 	}
@@ -53,7 +53,7 @@ func TestBuildRewriteCodeAction_LazyAndResolves(t *testing.T) {
 
 func TestBuildRewriteCodeAction_NoInstruction(t *testing.T) {
 	s := newTestServer()
-	s.llmClient = fakeLLM{resp: "IGNORED"}
+	setConfig(s, func(c *serverConfig) { c.llmClient = fakeLLM{resp: "IGNORED"} })
 	p := CodeActionParams{TextDocument: TextDocumentIdentifier{URI: "file:///t.go"}, Range: Range{}}
 	sel := "no instruction here"
 	if ca := s.buildRewriteCodeAction(p, sel); ca != nil {
@@ -63,7 +63,7 @@ func TestBuildRewriteCodeAction_NoInstruction(t *testing.T) {
 
 func TestBuildDiagnosticsCodeAction_LazyAndResolves(t *testing.T) {
 	s := newTestServer()
-	s.llmClient = fakeLLM{resp: "FIXED"}
+	setConfig(s, func(c *serverConfig) { c.llmClient = fakeLLM{resp: "FIXED"} })
 	p := CodeActionParams{TextDocument: TextDocumentIdentifier{URI: "file:///t.go"}, Range: Range{Start: Position{Line: 10}, End: Position{Line: 12, Character: 5}}}
 	ctx := CodeActionContext{Diagnostics: []Diagnostic{
 		{Range: Range{Start: Position{Line: 11}, End: Position{Line: 11, Character: 10}}, Message: "inside"},
@@ -82,7 +82,7 @@ func TestBuildDiagnosticsCodeAction_LazyAndResolves(t *testing.T) {
 	if len(ca.Data) == 0 {
 		t.Fatalf("expected data payload for lazy diagnostics action")
 	}
-	resolved, ok := s.resolveCodeAction(*ca)
+	resolved, ok := s.resolveCodeAction(context.Background(), *ca)
 	if !ok || resolved.Edit == nil {
 		t.Fatalf("expected resolve to produce edit")
 	}
@@ -90,7 +90,7 @@ func TestBuildDiagnosticsCodeAction_LazyAndResolves(t *testing.T) {
 
 func TestBuildDiagnosticsCodeAction_NoDiagnostics(t *testing.T) {
 	s := newTestServer()
-	s.llmClient = fakeLLM{resp: "FIXED"}
+	setConfig(s, func(c *serverConfig) { c.llmClient = fakeLLM{resp: "FIXED"} })
 	p := CodeActionParams{TextDocument: TextDocumentIdentifier{URI: "file:///t.go"}, Range: Range{}}
 	// empty context
 	p.Context = json.RawMessage(nil)
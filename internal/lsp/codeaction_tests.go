@@ -0,0 +1,257 @@
+// Summary: "Generate unit tests" code action; derives the sibling test file per language and builds a documentChanges edit.
+package lsp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"hexai/internal/llm"
+	"hexai/internal/logging"
+	"hexai/internal/prompts"
+)
+
+// testTarget describes where generated tests for a source file go.
+type testTarget struct {
+	lang string // language name used in prompts
+	uri  string // test file URI; equals the source URI for Rust
+}
+
+// testTargetFor maps a source URI to its test file by language convention:
+//   - Go:     foo.go  -> foo_test.go
+//   - Python: foo.py  -> test_foo.py
+//   - Rust:   foo.rs  -> a #[cfg(test)] module appended to foo.rs
+//
+// Files that already are tests are not supported.
+func testTargetFor(uri string) (testTarget, bool) {
+	dir, name := path.Split(uri)
+	switch {
+	case strings.HasSuffix(name, "_test.go"):
+		return testTarget{}, false
+	case strings.HasSuffix(name, ".go"):
+		return testTarget{lang: "Go", uri: dir + strings.TrimSuffix(name, ".go") + "_test.go"}, true
+	case strings.HasSuffix(name, ".py"):
+		if strings.HasPrefix(name, "test_") || strings.HasSuffix(name, "_test.py") {
+			return testTarget{}, false
+		}
+		return testTarget{lang: "Python", uri: dir + "test_" + name}, true
+	case strings.HasSuffix(name, ".rs"):
+		return testTarget{lang: "Rust", uri: uri}, true
+	}
+	return testTarget{}, false
+}
+
+func (s *Server) buildTestsCodeAction(p CodeActionParams, sel string) *CodeAction {
+	if _, ok := testTargetFor(p.TextDocument.URI); !ok {
+		return nil
+	}
+	return s.buildCodeActionWithPayload("Hexai: generate unit tests", "refactor", codeActionPayload{
+		Type: "tests", URI: p.TextDocument.URI, Range: p.Range, Selection: sel,
+	})
+}
+
+// resolveTestsAction asks the LLM for tests of sel and returns an edit that
+// creates the test file or appends to it.
+func (s *Server) resolveTestsAction(ctx context.Context, uri, sel string) (*WorkspaceEdit, error) {
+	cfg := s.config()
+	if cfg.llmClient == nil {
+		return nil, errLLMDisabled
+	}
+	target, ok := testTargetFor(uri)
+	if !ok {
+		return nil, fmt.Errorf("no test file convention for %s", uri)
+	}
+	existing, version, found, err := s.testFileContent(target.uri)
+	if err != nil {
+		return nil, err
+	}
+	if !found && !s.canCreateFiles() {
+		return nil, fmt.Errorf("the editor cannot create files; create %s and run the action again", uriToPath(target.uri))
+	}
+	helpers := testFileHelpers(target.lang, existing)
+	if s.excluded(target.uri) {
+		helpers = "" // an ignored test file is still appended to, but never sent
+	}
+	data := prompts.Data{
+		File: uri, Language: target.lang, TestFile: target.uri, Selection: sel, Append: found,
+		Package: s.packageClause(uri, target.lang), Helpers: helpers,
+		Context: s.similarChunks(sel, uri),
+	}
+	msgs := []llm.Message{
+		{Role: "system", Content: cfg.prompts.Render(prompts.TestsSystem, data)},
+		{Role: "user", Content: cfg.prompts.Render(prompts.TestsUser, data)},
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	text, err := cfg.llmClient.Chat(ctx, msgs, s.llmRequestOpts(uri)...)
+	if err != nil {
+		return nil, err
+	}
+	code := strings.TrimSpace(stripCodeFences(strings.TrimSpace(text)))
+	if code == "" {
+		return nil, errors.New("empty test code")
+	}
+	if !found && data.Package != "" && !strings.HasPrefix(code, data.Package) {
+		code = data.Package + "\n\n" + code
+	}
+	return s.testsEdit(target.uri, code, existing, version, found), nil
+}
+
+// canCreateFiles reports whether the client accepts CreateFile operations
+// in WorkspaceEdit.DocumentChanges.
+func (s *Server) canCreateFiles() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.editCreateFiles
+}
+
+// testsEdit creates the test file with code, or appends code after the
+// existing content, separated by a blank line. Appends fall back to
+// WorkspaceEdit.Changes for clients without documentChanges support.
+func (s *Server) testsEdit(uri, code, existing string, version *int, found bool) *WorkspaceEdit {
+	if !found {
+		return &WorkspaceEdit{DocumentChanges: []any{
+			CreateFile{Kind: "create", URI: uri, Options: &CreateFileOptions{IgnoreIfExists: true}},
+			TextDocumentEdit{
+				TextDocument: OptionalVersionedTextDocumentIdentifier{URI: uri},
+				Edits:        []TextEdit{{NewText: code + "\n"}},
+			},
+		}}
+	}
+	lines := splitLines(existing)
+	last := len(lines) - 1
+	insert := "\n" + code + "\n"
+	if lines[last] != "" {
+		insert = "\n" + insert
+	}
+	end := Position{Line: last, Character: len(lines[last])}
+	edits := []TextEdit{{Range: Range{Start: end, End: end}, NewText: insert}}
+	s.mu.RLock()
+	docChanges := s.editDocumentChanges
+	s.mu.RUnlock()
+	if !docChanges {
+		return &WorkspaceEdit{Changes: map[string][]TextEdit{uri: edits}}
+	}
+	return &WorkspaceEdit{DocumentChanges: []any{TextDocumentEdit{
+		TextDocument: OptionalVersionedTextDocumentIdentifier{URI: uri, Version: version},
+		Edits:        edits,
+	}}}
+}
+
+// testFileContent returns the test file text from the open-document store or,
+// unless disk I/O is disabled, from disk. version is set for open documents.
+// A test file that exists but cannot be read is an error, since creating it
+// again would duplicate its header.
+func (s *Server) testFileContent(uri string) (text string, version *int, found bool, err error) {
+	if d := s.getDocument(uri); d != nil {
+		v := d.version
+		return d.Text(), &v, true, nil
+	}
+	path := uriToPath(uri)
+	if s.config().noDiskIO {
+		if _, err := os.Stat(path); err == nil {
+			return "", nil, false, fmt.Errorf("%s exists but no_disk_io keeps it from being read; open it and run the action again", path)
+		}
+		return "", nil, false, nil
+	}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil, false, nil
+	}
+	if err != nil {
+		logging.Logf("lsp ", "generate tests: cannot read %s: %v", uri, err)
+		return "", nil, false, fmt.Errorf("cannot read %s: %v", path, err)
+	}
+	return string(b), nil, true, nil
+}
+
+// packageClause returns the Go package clause of the source document.
+func (s *Server) packageClause(uri, lang string) string {
+	d := s.getDocument(uri)
+	if d == nil || lang != "Go" {
+		return ""
+	}
+	for _, l := range d.lines {
+		if t := strings.TrimSpace(l); strings.HasPrefix(t, "package ") {
+			return t
+		}
+	}
+	return ""
+}
+
+// testFileHelpers summarizes an existing test file for the prompt: its
+// header (everything before the first declaration) and the signatures of
+// helpers that are not tests themselves.
+func testFileHelpers(lang, text string) string {
+	if strings.TrimSpace(text) == "" {
+		return ""
+	}
+	lines := splitLines(text)
+	var header, helpers []string
+	inHeader := true
+	for i, l := range lines {
+		if !isDeclLine(lang, l) {
+			if inHeader {
+				header = append(header, l)
+			}
+			continue
+		}
+		inHeader = false
+		if !isTestDecl(lang, l, lines, i) {
+			helpers = append(helpers, strings.TrimRight(l, " {"))
+		}
+	}
+	out := strings.TrimSpace(strings.Join(header, "\n"))
+	if len(helpers) > 0 {
+		out += "\n\n" + strings.Join(helpers, "\n")
+	}
+	return out
+}
+
+func isDeclLine(lang, l string) bool {
+	t := strings.TrimSpace(l)
+	switch lang {
+	case "Go":
+		return strings.HasPrefix(l, "func ") || strings.HasPrefix(l, "type ")
+	case "Python":
+		return strings.HasPrefix(t, "def ") || strings.HasPrefix(t, "class ")
+	case "Rust":
+		return strings.HasPrefix(t, "fn ") || strings.HasPrefix(t, "struct ")
+	}
+	return false
+}
+
+func isTestDecl(lang, l string, lines []string, i int) bool {
+	t := strings.TrimSpace(l)
+	switch lang {
+	case "Go":
+		for _, p := range []string{"func Test", "func Benchmark", "func Example", "func Fuzz"} {
+			if strings.HasPrefix(t, p) {
+				return true
+			}
+		}
+	case "Python":
+		return strings.HasPrefix(t, "def test") || strings.HasPrefix(t, "class Test")
+	case "Rust":
+		return i > 0 && strings.TrimSpace(lines[i-1]) == "#[test]"
+	}
+	return false
+}
+
+// pathToURI converts a filesystem path to a file:// URI.
+func pathToURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// uriToPath converts a file:// URI to a filesystem path.
+func uriToPath(uri string) string {
+	if u, err := url.Parse(uri); err == nil && u.Scheme == "file" {
+		return u.Path
+	}
+	return strings.TrimPrefix(uri, "file://")
+}
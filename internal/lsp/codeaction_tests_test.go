@@ -0,0 +1,117 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTestTargetFor_LanguageConventions(t *testing.T) {
+	cases := []struct {
+		uri, want string
+		ok        bool
+	}{
+		{"file:///p/foo.go", "file:///p/foo_test.go", true},
+		{"file:///p/foo_test.go", "", false},
+		{"file:///p/foo.py", "file:///p/test_foo.py", true},
+		{"file:///p/test_foo.py", "", false},
+		{"file:///p/lib.rs", "file:///p/lib.rs", true},
+		{"file:///p/notes.md", "", false},
+	}
+	for _, tc := range cases {
+		got, ok := testTargetFor(tc.uri)
+		if ok != tc.ok || got.uri != tc.want {
+			t.Fatalf("%s: got (%q,%t) want (%q,%t)", tc.uri, got.uri, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestResolveTests_CreatesMissingGoTestFile(t *testing.T) {
+	dir := t.TempDir()
+	uri := "file://" + filepath.Join(dir, "calc.go")
+	s := newTestServer()
+	s.editDocumentChanges, s.editCreateFiles = true, true
+	setConfig(s, func(c *serverConfig) { c.llmClient = fakeLLM{resp: "```go\nfunc TestAdd(t *testing.T) {}\n```"} })
+	s.setDocument(uri, "package calc\n\nfunc Add(a, b int) int { return a + b }")
+	ca, ok := s.resolveCodeAction(context.Background(), *s.buildTestsCodeAction(CodeActionParams{TextDocument: TextDocumentIdentifier{URI: uri}}, "func Add(a, b int) int { return a + b }"))
+	if !ok || ca.Edit == nil || len(ca.Edit.DocumentChanges) != 2 {
+		t.Fatalf("expected create+edit document changes, got %+v", ca.Edit)
+	}
+	create, ok := ca.Edit.DocumentChanges[0].(CreateFile)
+	if !ok || create.Kind != "create" || !strings.HasSuffix(create.URI, "/calc_test.go") {
+		t.Fatalf("unexpected first change: %+v", ca.Edit.DocumentChanges[0])
+	}
+	edit := ca.Edit.DocumentChanges[1].(TextDocumentEdit)
+	if got := edit.Edits[0].NewText; got != "package calc\n\nfunc TestAdd(t *testing.T) {}\n" {
+		t.Fatalf("unexpected new file content: %q", got)
+	}
+	s.editCreateFiles = false
+	if _, err := s.resolveTestsAction(context.Background(), uri, "func Add() {}"); err == nil || !strings.Contains(err.Error(), "cannot create files") {
+		t.Fatalf("expected a refusal for clients that cannot create files, got %v", err)
+	}
+}
+
+func TestResolveTests_AppendsToExistingFileFromDisk(t *testing.T) {
+	dir := t.TempDir()
+	uri := "file://" + filepath.Join(dir, "calc.go")
+	existing := "package calc\n\nimport \"testing\"\n\nfunc newCalc() int { return 0 }\n\nfunc TestOld(t *testing.T) {}\n"
+	if err := os.WriteFile(filepath.Join(dir, "calc_test.go"), []byte(existing), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer()
+	s.editDocumentChanges = true
+	setConfig(s, func(c *serverConfig) { c.llmClient = fakeLLM{resp: "func TestAdd(t *testing.T) {}"} })
+	edit, err := s.resolveTestsAction(context.Background(), uri, "func Add() {}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edit.DocumentChanges) != 1 {
+		t.Fatalf("expected a single text edit, got %+v", edit.DocumentChanges)
+	}
+	te := edit.DocumentChanges[0].(TextDocumentEdit)
+	if got := applyTestEdits(existing, te.Edits); got != existing+"\nfunc TestAdd(t *testing.T) {}\n" {
+		t.Fatalf("unexpected appended file:\n%s", got)
+	}
+	s.editDocumentChanges = false
+	if edit, err = s.resolveTestsAction(context.Background(), uri, "func Add() {}"); err != nil || len(edit.DocumentChanges) != 0 {
+		t.Fatalf("expected plain changes without documentChanges support, got %+v %v", edit, err)
+	}
+	if got := applyTestEdits(existing, edit.Changes[te.TextDocument.URI]); got != existing+"\nfunc TestAdd(t *testing.T) {}\n" {
+		t.Fatalf("unexpected appended file:\n%s", got)
+	}
+	// with no_disk_io an existing test file that is not open is refused
+	// rather than created again with a second package clause
+	setConfig(s, func(c *serverConfig) { c.noDiskIO = true })
+	if _, err := s.resolveTestsAction(context.Background(), uri, "func Add() {}"); err == nil || !strings.Contains(err.Error(), "open it") {
+		t.Fatalf("expected a request to open the test file, got %v", err)
+	}
+}
+
+func TestResolveTests_IgnoredTestFileIsNotSent(t *testing.T) {
+	s, root := newIgnoringServer(t, "*_test.go\n")
+	existing := "package calc\n\nfunc secretHelper() int { return 42 }\n"
+	if err := os.WriteFile(filepath.Join(root, "calc_test.go"), []byte(existing), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fake := &chatRecordingLLM{resp: "func TestAdd(t *testing.T) {}"}
+	setConfig(s, func(c *serverConfig) { c.llmClient = fake })
+	if _, err := s.resolveTestsAction(context.Background(), "file://"+filepath.Join(root, "calc.go"), "func Add() {}"); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range fake.msgs[0] {
+		if strings.Contains(m.Content, "secretHelper") {
+			t.Fatalf("the ignored test file was sent: %q", m.Content)
+		}
+	}
+}
+
+func TestTestFileHelpers_Go(t *testing.T) {
+	src := "package calc\n\nimport \"testing\"\n\nfunc newCalc() *Calc {\n\treturn nil\n}\n\nfunc TestX(t *testing.T) {}\n"
+	got := testFileHelpers("Go", src)
+	want := "package calc\n\nimport \"testing\"\n\nfunc newCalc() *Calc"
+	if got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+}
@@ -0,0 +1,82 @@
+// Summary: Code lenses above functions ("Hexai: explain | test | document") that run the matching hexai.* command.
+package lsp
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// codeLensData is stored in CodeLens.Data until the lens is resolved.
+type codeLensData struct {
+	URI     string `json:"uri"`
+	Command string `json:"command"`
+}
+
+// codeLensTitles are shown side by side above each function, so only the
+// first carries the "Hexai:" prefix.
+var codeLensTitles = map[string]string{
+	cmdExplain:  "Hexai: explain",
+	cmdTests:    "test",
+	cmdDocument: "document",
+}
+
+func (s *Server) handleCodeLens(req Request) {
+	var p CodeLensParams
+	lenses := []CodeLens{}
+	if err := json.Unmarshal(req.Params, &p); err == nil && s.config().llmClient != nil && !s.excluded(p.TextDocument.URI) {
+		if d := s.getDocument(p.TextDocument.URI); d != nil {
+			lenses = s.codeLenses(d)
+		}
+	}
+	s.reply(req.ID, lenses, nil)
+}
+
+// codeLenses returns unresolved lenses on the first line of every function.
+// The test and document lenses are left out for files those actions do
+// not support.
+func (s *Server) codeLenses(d *document) []CodeLens {
+	cmds := []string{cmdExplain}
+	if _, ok := testTargetFor(d.uri); ok {
+		cmds = append(cmds, cmdTests)
+	}
+	if docLanguage(d.uri) != "" {
+		cmds = append(cmds, cmdDocument)
+	}
+	lenses := []CodeLens{}
+	for i, line := range d.lines {
+		if !isFuncDeclStart(strings.TrimSpace(line)) {
+			continue
+		}
+		r := Range{Start: Position{Line: i}, End: Position{Line: i, Character: len(line)}}
+		for _, cmd := range cmds {
+			data, _ := json.Marshal(codeLensData{URI: d.uri, Command: cmd})
+			lenses = append(lenses, CodeLens{Range: r, Data: data})
+		}
+	}
+	return lenses
+}
+
+// handleCodeLensResolve attaches the command, with the function's current
+// range as argument, to a lens from handleCodeLens.
+func (s *Server) handleCodeLensResolve(req Request) {
+	var lens CodeLens
+	if err := json.Unmarshal(req.Params, &lens); err != nil {
+		s.reply(req.ID, nil, &RespError{Code: -32602, Message: "invalid codeLens"})
+		return
+	}
+	s.reply(req.ID, s.resolveCodeLens(lens), nil)
+}
+
+func (s *Server) resolveCodeLens(lens CodeLens) CodeLens {
+	var data codeLensData
+	if err := json.Unmarshal(lens.Data, &data); err != nil || codeLensTitles[data.Command] == "" {
+		return lens
+	}
+	r := lens.Range
+	if d := s.getDocument(data.URI); d != nil && r.Start.Line < len(d.lines) {
+		end := declarationEnd(d.lines, r.Start.Line, docDeclMaxLines)
+		r = Range{Start: Position{Line: r.Start.Line}, End: Position{Line: end, Character: len(d.lines[end])}}
+	}
+	lens.Command = &Command{Title: codeLensTitles[data.Command], Command: data.Command, Arguments: []any{data.URI, r}}
+	return lens
+}
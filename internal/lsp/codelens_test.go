@@ -0,0 +1,55 @@
+package lsp
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestCodeLenses_PerFunctionAndLanguage(t *testing.T) {
+	s := newTestServer()
+	s.setDocument("file:///p/a.go", "package p\n\ntype T struct{}\n\nfunc (T) A() {\n\tf := func() {}\n\t_ = f\n}\n\nfunc B() {}")
+	lenses := s.codeLenses(s.getDocument("file:///p/a.go"))
+	if len(lenses) != 6 || lenses[0].Range.Start.Line != 4 || lenses[3].Range.Start.Line != 9 {
+		t.Fatalf("want 3 lenses on lines 4 and 9, got %+v", lenses)
+	}
+	s.setDocument("file:///p/x.js", "function a() {}\nfn b() {}")
+	if got := s.codeLenses(s.getDocument("file:///p/x.js")); len(got) != 1 {
+		t.Fatalf("want only the explain lens for unsupported languages, got %+v", got)
+	}
+}
+
+func TestResolveCodeLens_CommandWithFunctionRange(t *testing.T) {
+	s := newTestServer()
+	uri := "file:///p/a.go"
+	s.setDocument(uri, "package p\n\nfunc A() {\n\treturn\n}\n")
+	lenses := s.codeLenses(s.getDocument(uri))
+	lens := s.resolveCodeLens(lenses[1])
+	if lens.Command == nil || lens.Command.Command != cmdTests || lens.Command.Title != "test" {
+		t.Fatalf("unexpected command: %+v", lens.Command)
+	}
+	if r := lens.Command.Arguments[1].(Range); r != *rangeOf(2, 0, 4, 1) {
+		t.Fatalf("want function range, got %+v", r)
+	}
+}
+
+func TestCmdDocument_AppliesEditFromLensArguments(t *testing.T) {
+	var buf bytes.Buffer
+	s := newTestServer()
+	s.out = &buf
+	setConfig(s, func(c *serverConfig) { c.llmClient = fakeLLM{resp: "A does nothing."} })
+	uri := "file:///p/a.go"
+	s.setDocument(uri, "package p\n\nfunc A() {\n}\n")
+	lens := s.resolveCodeLens(s.codeLenses(s.getDocument(uri))[2])
+	b, _ := json.Marshal(lens.Command)
+	s.handleExecuteCommand(Request{ID: json.RawMessage(`1`), Params: b})
+	msgs := readAllMessages(t, &buf)
+	if len(msgs) != 2 || string(msgs[0]["method"]) != `"workspace/applyEdit"` {
+		t.Fatalf("expected applyEdit then reply, got %v", msgs)
+	}
+	var p ApplyWorkspaceEditParams
+	_ = json.Unmarshal(msgs[0]["params"], &p)
+	if got := applyTestEdits(s.getDocument(uri).Text(), p.Edit.Changes[uri]); got != "package p\n\n// A does nothing.\nfunc A() {\n}\n" {
+		t.Fatalf("unexpected text: %q", got)
+	}
+}
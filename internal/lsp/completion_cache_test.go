@@ -14,9 +14,9 @@ func TestCompletionCache_IgnoresWhitespaceBeforeCursor(t *testing.T) {
 	logger := log.New(&buf, "", 0)
 	s := NewServer(bytes.NewBuffer(nil), &buf, logger, ServerOptions{})
 	logging.Bind(logger)
-	s.triggerChars = []string{" ", "."}
+	setConfig(s, func(c *serverConfig) { c.triggerChars = []string{" ", "."} })
 	fake := &countingLLM{}
-	s.llmClient = fake
+	setConfig(s, func(c *serverConfig) { c.llmClient = fake })
 
 	// First request with trailing spaces before cursor
 	line := "foo   "
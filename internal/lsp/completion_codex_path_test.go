@@ -39,9 +39,13 @@ func (f *fakeCodeLLM) Name() string         { return "fake" }
 func (f *fakeCodeLLM) DefaultModel() string { return "m" }
 
 func TestTryLLMCompletion_PrefersCodeCompleterOverChat(t *testing.T) {
-	s := &Server{maxTokens: 32, triggerChars: []string{"."}, compCache: make(map[string]string)}
+	s := &Server{compCache: make(map[string]string)}
+	setConfig(s, func(c *serverConfig) {
+		c.maxTokens = 32
+		c.triggerChars = []string{"."}
+	})
 	fake := &fakeCodeLLM{result: "DoThing()"}
-	s.llmClient = fake
+	setConfig(s, func(c *serverConfig) { c.llmClient = fake })
 	line := "obj."
 	p := CompletionParams{Position: Position{Line: 0, Character: len(line)}, TextDocument: TextDocumentIdentifier{URI: "file://x.go"}}
 	items, ok := s.tryLLMCompletion(p, "", line, "", "", "", false, "")
@@ -57,9 +61,13 @@ func TestTryLLMCompletion_PrefersCodeCompleterOverChat(t *testing.T) {
 }
 
 func TestTryLLMCompletion_FallsBackToChatOnCodeCompleterError(t *testing.T) {
-	s := &Server{maxTokens: 32, triggerChars: []string{"."}, compCache: make(map[string]string)}
+	s := &Server{compCache: make(map[string]string)}
+	setConfig(s, func(c *serverConfig) {
+		c.maxTokens = 32
+		c.triggerChars = []string{"."}
+	})
 	fake := &fakeCodeLLM{result: "DoThing()", codeErr: errors.New("boom")}
-	s.llmClient = fake
+	setConfig(s, func(c *serverConfig) { c.llmClient = fake })
 	line := "obj."
 	p := CompletionParams{Position: Position{Line: 0, Character: len(line)}, TextDocument: TextDocumentIdentifier{URI: "file://y.go"}}
 	items, ok := s.tryLLMCompletion(p, "", line, "", "", "", false, "")
@@ -0,0 +1,63 @@
+// Summary: Hedged completion requests; races the primary LLM against an optional secondary after a delay.
+package lsp
+
+import (
+	"context"
+	"hexai/internal/llm"
+	"hexai/internal/logging"
+)
+
+// completionChat sends completion messages for uri to the primary client and,
+// when a hedge client is configured, fires the same request at it after
+// hedgeDelay. Only the primary request carries the model for uri.
+func (s *Server) completionChat(ctx context.Context, uri string, messages []llm.Message, opts []llm.RequestOption) (string, error) {
+	cfg := s.config()
+	if cfg.llmClient == nil {
+		return "", errLLMDisabled
+	}
+	popts := s.withModel(uri, opts)
+	primary := func(c context.Context) (string, error) { return cfg.llmClient.Chat(c, messages, popts...) }
+	var secondary func(context.Context) (string, error)
+	if cfg.hedgeClient != nil {
+		secondary = func(c context.Context) (string, error) { return cfg.hedgeClient.Chat(c, messages, opts...) }
+	}
+	text, outcome, err := llm.Hedge(ctx, cfg.hedgeDelay, primary, secondary)
+	s.recordHedge(outcome, err)
+	return text, err
+}
+
+// completionCode runs provider-native code completion, hedged against the
+// secondary client when it also implements llm.CodeCompleter.
+func (s *Server) completionCode(ctx context.Context, cc llm.CodeCompleter, prompt, suffix, lang string, temp float64) ([]string, error) {
+	cfg := s.config()
+	primary := func(c context.Context) ([]string, error) { return cc.CodeCompletion(c, prompt, suffix, 1, lang, temp) }
+	var secondary func(context.Context) ([]string, error)
+	if hc, ok := cfg.hedgeClient.(llm.CodeCompleter); ok {
+		secondary = func(c context.Context) ([]string, error) { return hc.CodeCompletion(c, prompt, suffix, 1, lang, temp) }
+	}
+	out, outcome, err := llm.Hedge(ctx, cfg.hedgeDelay, primary, secondary)
+	s.recordHedge(outcome, err)
+	return out, err
+}
+
+// recordHedge updates the hedge win/loss counters for a finished request.
+// Requests where every fired leg failed count as fired without a winner.
+func (s *Server) recordHedge(outcome llm.HedgeOutcome, err error) {
+	if outcome == llm.HedgeNotFired {
+		return
+	}
+	winner := "none"
+	s.mu.Lock()
+	s.hedgeFired++
+	if err == nil {
+		if outcome == llm.HedgeSecondaryWon {
+			s.hedgeSecondaryWins++
+			winner = "secondary"
+		} else {
+			s.hedgePrimaryWins++
+			winner = "primary"
+		}
+	}
+	s.mu.Unlock()
+	logging.Logf("lsp ", "completion hedge fired winner=%s", winner)
+}
@@ -0,0 +1,51 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"hexai/internal/llm"
+)
+
+// slowLLM answers after a delay unless its context is cancelled first.
+type slowLLM struct {
+	delay time.Duration
+	resp  string
+}
+
+func (f slowLLM) Chat(ctx context.Context, _ []llm.Message, _ ...llm.RequestOption) (string, error) {
+	select {
+	case <-time.After(f.delay):
+		return f.resp, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+func (f slowLLM) Name() string         { return "slow" }
+func (f slowLLM) DefaultModel() string { return "m" }
+
+func TestCompletion_HedgeSecondaryWinsAndCounts(t *testing.T) {
+	s := &Server{compCache: make(map[string]string)}
+	setConfig(s, func(c *serverConfig) {
+		c.maxTokens = 32
+		c.triggerChars = []string{"."}
+		c.llmClient = slowLLM{delay: 5 * time.Second, resp: "fromPrimary()"}
+		c.hedgeClient = slowLLM{delay: 0, resp: "fromSecondary()"}
+		c.hedgeDelay = 10 * time.Millisecond
+	})
+	line := "obj."
+	p := CompletionParams{Position: Position{Line: 0, Character: len(line)}, TextDocument: TextDocumentIdentifier{URI: "file://hedge.go"}}
+	p.Context = json.RawMessage([]byte(`{"triggerKind":1}`))
+	items, ok := s.tryLLMCompletion(p, "", line, "", "", "", false, "")
+	if !ok || len(items) != 1 {
+		t.Fatalf("expected one item; ok=%v len=%d", ok, len(items))
+	}
+	if got := items[0].TextEdit.NewText; got != "fromSecondary()" {
+		t.Fatalf("expected secondary answer, got %q", got)
+	}
+	if s.hedgeFired != 1 || s.hedgeSecondaryWins != 1 || s.hedgePrimaryWins != 0 {
+		t.Fatalf("unexpected hedge counters fired=%d primary=%d secondary=%d", s.hedgeFired, s.hedgePrimaryWins, s.hedgeSecondaryWins)
+	}
+}
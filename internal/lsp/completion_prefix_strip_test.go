@@ -39,8 +39,12 @@ func TestStripDuplicateAssignmentPrefix_AssignAndWalrus(t *testing.T) {
 }
 
 func TestTryLLMCompletion_ManualInvokeAfterWhitespace_Allows(t *testing.T) {
-	s := &Server{maxTokens: 32, triggerChars: []string{".", ":", "/", "_"}, compCache: make(map[string]string)}
-	s.llmClient = fakeLLM{resp: "() *CustData"}
+	s := &Server{compCache: make(map[string]string)}
+	setConfig(s, func(c *serverConfig) {
+		c.maxTokens = 32
+		c.triggerChars = []string{".", ":", "/", "_"}
+		c.llmClient = fakeLLM{resp: "() *CustData"}
+	})
 	line := "func fib(i int) " // cursor after space
 	p := CompletionParams{Position: Position{Line: 0, Character: len(line)}, TextDocument: TextDocumentIdentifier{URI: "file://x.go"}}
 	// Simulate manual user invocation (TriggerKind=1)
@@ -55,8 +59,12 @@ func TestTryLLMCompletion_ManualInvokeAfterWhitespace_Allows(t *testing.T) {
 }
 
 func TestTryLLMCompletion_InlineSemicolonPromptAlwaysTriggers(t *testing.T) {
-	s := &Server{maxTokens: 32, triggerChars: []string{".", ":", "/", "_"}, compCache: make(map[string]string)}
-	s.llmClient = fakeLLM{resp: "replacement"}
+	s := &Server{compCache: make(map[string]string)}
+	setConfig(s, func(c *serverConfig) {
+		c.maxTokens = 32
+		c.triggerChars = []string{".", ":", "/", "_"}
+		c.llmClient = fakeLLM{resp: "replacement"}
+	})
 	line := "prefix ;do something; suffix"
 	// No trigger char immediately before cursor; place cursor at end
 	p := CompletionParams{Position: Position{Line: 0, Character: len(line)}, TextDocument: TextDocumentIdentifier{URI: "file://inline.go"}}
@@ -67,9 +75,13 @@ func TestTryLLMCompletion_InlineSemicolonPromptAlwaysTriggers(t *testing.T) {
 }
 
 func TestTryLLMCompletion_DoubleSemicolonEmpty_DoesNotAutoTrigger(t *testing.T) {
-	s := &Server{maxTokens: 32, triggerChars: []string{".", ":", "/", "_"}, compCache: make(map[string]string)}
+	s := &Server{compCache: make(map[string]string)}
+	setConfig(s, func(c *serverConfig) {
+		c.maxTokens = 32
+		c.triggerChars = []string{".", ":", "/", "_"}
+	})
 	fake := &countingLLM{}
-	s.llmClient = fake
+	setConfig(s, func(c *serverConfig) { c.llmClient = fake })
 	line := ";;   " // empty content after ';;' should not force-trigger
 	p := CompletionParams{Position: Position{Line: 0, Character: len(line)}, TextDocument: TextDocumentIdentifier{URI: "file://empty-inline.go"}}
 	items, ok := s.tryLLMCompletion(p, "", line, "", "", "", false, "")
@@ -100,9 +112,13 @@ func TestHasDoubleSemicolonTrigger_Variants(t *testing.T) {
 }
 
 func TestBareDoubleSemicolonPreventsAutoTriggerEvenWithOtherTriggers(t *testing.T) {
-	s := &Server{maxTokens: 32, triggerChars: []string{".", ":", "/", "_"}, compCache: make(map[string]string)}
+	s := &Server{compCache: make(map[string]string)}
+	setConfig(s, func(c *serverConfig) {
+		c.maxTokens = 32
+		c.triggerChars = []string{".", ":", "/", "_"}
+	})
 	fake := &countingLLM{}
-	s.llmClient = fake
+	setConfig(s, func(c *serverConfig) { c.llmClient = fake })
 	// Place a '.' earlier but also include bare ';;' at end; should not auto-trigger
 	line := "obj. call ;;"
 	p := CompletionParams{Position: Position{Line: 0, Character: len(line)}, TextDocument: TextDocumentIdentifier{URI: "file://bare-ds.go"}}
@@ -119,9 +135,13 @@ func TestBareDoubleSemicolonPreventsAutoTriggerEvenWithOtherTriggers(t *testing.
 }
 
 func TestBareDoubleSemicolonOnNextLine_PreventsAutoTrigger(t *testing.T) {
-	s := &Server{maxTokens: 32, triggerChars: []string{".", ":", "/", "_"}, compCache: make(map[string]string)}
+	s := &Server{compCache: make(map[string]string)}
+	setConfig(s, func(c *serverConfig) {
+		c.maxTokens = 32
+		c.triggerChars = []string{".", ":", "/", "_"}
+	})
 	fake := &countingLLM{}
-	s.llmClient = fake
+	setConfig(s, func(c *serverConfig) { c.llmClient = fake })
 	current := "expression := flag.String(\"expression\", \"\", \"Expression to evaluate\")"
 	below := ";;"
 	p := CompletionParams{Position: Position{Line: 0, Character: len(current)}, TextDocument: TextDocumentIdentifier{URI: "file://nextline.go"}}
@@ -138,9 +158,13 @@ func TestBareDoubleSemicolonOnNextLine_PreventsAutoTrigger(t *testing.T) {
 }
 
 func TestBareDoubleSemicolonPreventsManualInvoke(t *testing.T) {
-	s := &Server{maxTokens: 32, triggerChars: []string{".", ":", "/", "_"}, compCache: make(map[string]string)}
+	s := &Server{compCache: make(map[string]string)}
+	setConfig(s, func(c *serverConfig) {
+		c.maxTokens = 32
+		c.triggerChars = []string{".", ":", "/", "_"}
+	})
 	fake := &countingLLM{}
-	s.llmClient = fake
+	setConfig(s, func(c *serverConfig) { c.llmClient = fake })
 	line := ";;"
 	p := CompletionParams{Position: Position{Line: 0, Character: len(line)}, TextDocument: TextDocumentIdentifier{URI: "file://bare-ds-manual.go"}}
 	// Simulate manual invoke
@@ -0,0 +1,148 @@
+// Summary: Hot configuration reload on workspace/didChangeConfiguration and config file changes, with dynamic completion re-registration.
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"hexai/internal/logging"
+)
+
+const (
+	configPollInterval = 2 * time.Second
+	completionRegID    = "hexai.completion"
+)
+
+func (s *Server) handleDidChangeConfiguration(req Request) {
+	var p DidChangeConfigurationParams
+	if err := json.Unmarshal(req.Params, &p); err != nil {
+		return
+	}
+	s.mu.Lock()
+	s.editorSettings = p.Settings
+	s.mu.Unlock()
+	if s.reloadConfig == nil {
+		return
+	}
+	if err := s.reload("settings"); err != nil {
+		s.showMessage(messageWarning, fmt.Sprintf("Hexai: settings not applied: %v", err))
+	}
+}
+
+// reload rebuilds the options from the configuration and applies them in
+// one step; open documents are kept. Caches are cleared since their entries
+// were produced with the old settings, and the completion registration is
+// renewed when the trigger characters changed.
+func (s *Server) reload(reason string) error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	s.mu.RLock()
+	root, settings := s.root, s.editorSettings
+	s.mu.RUnlock()
+	before := s.advertisedTriggerChars()
+	opts, err := s.reloadConfig(root, settings)
+	if err != nil {
+		logging.Logf("lsp ", "config reload reason=%s error: %v", reason, err)
+		return err
+	}
+	s.applyOptions(opts)
+	s.setEmbedder(opts.Embedder, root, reason != "initialize")
+	s.clearCaches()
+	logging.Logf("lsp ", "config reload reason=%s applied", reason)
+	if opts.Notice != "" {
+		s.showMessage(messageWarning, opts.Notice)
+	}
+	if reason != "initialize" { // reported once the client is initialized
+		s.reportConfigProblems(false)
+	}
+	s.mu.RLock()
+	registered, lexical := s.completionRegistered, s.lexical != nil
+	s.mu.RUnlock()
+	if after := s.advertisedTriggerChars(); registered && !slices.Equal(before, after) {
+		go s.reregisterCompletion(after)
+	}
+	if !lexical && root != "" && s.usesContextMode("retrieval") {
+		s.startLexicalIndex(root)
+	}
+	return nil
+}
+
+// watchConfig polls the config files and reloads when one of them is
+// created, changed or removed. It runs for the lifetime of the server.
+func (s *Server) watchConfig(interval time.Duration) {
+	stamp := s.configStamp()
+	for range time.Tick(interval) {
+		stamp = s.checkConfigFiles(stamp)
+	}
+}
+
+// checkConfigFiles reloads when the config files no longer match stamp and
+// returns the stamp to compare against next time.
+func (s *Server) checkConfigFiles(stamp string) string {
+	now := s.configStamp()
+	if now == stamp {
+		return stamp
+	}
+	if err := s.reload("config file changed"); err != nil {
+		s.showMessage(messageWarning, fmt.Sprintf("Hexai: config not reloaded: %v", err))
+	}
+	// The reload may watch other files, e.g. a project config that appeared
+	return s.configStamp()
+}
+
+// configStamp summarizes path, size and modification time of each config
+// file; missing files contribute only their path.
+func (s *Server) configStamp() string {
+	files := s.config().configFiles
+	var b strings.Builder
+	for _, path := range files {
+		b.WriteString(path)
+		if fi, err := os.Stat(path); err == nil {
+			fmt.Fprintf(&b, ":%d:%d", fi.Size(), fi.ModTime().UnixNano())
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// registerCompletion registers textDocument/completion with the client for
+// all documents with the given trigger characters.
+func (s *Server) registerCompletion(chars []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := s.callClient(ctx, "client/registerCapability", RegistrationParams{Registrations: []Registration{{
+		ID: completionRegID, Method: "textDocument/completion",
+		RegisterOptions: CompletionRegistrationOptions{TriggerCharacters: chars},
+	}}})
+	if err != nil {
+		logging.Logf("lsp ", "completion registration error: %v", err)
+		return
+	}
+	s.mu.Lock()
+	s.completionRegistered = true
+	s.mu.Unlock()
+	logging.Logf("lsp ", "completion registered trigger_characters=%q", chars)
+}
+
+// reregisterCompletion replaces the completion registration, since the
+// client cannot update the trigger characters of an existing one.
+func (s *Server) reregisterCompletion(chars []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := s.callClient(ctx, "client/unregisterCapability", UnregistrationParams{Unregisterations: []Unregistration{{
+		ID: completionRegID, Method: "textDocument/completion",
+	}}})
+	if err != nil {
+		logging.Logf("lsp ", "completion unregistration error: %v", err)
+		return
+	}
+	s.mu.Lock()
+	s.completionRegistered = false
+	s.mu.Unlock()
+	s.registerCompletion(chars)
+}
@@ -0,0 +1,172 @@
+// Summary: Tests for configuration hot reload via didChangeConfiguration, config file polling and completion re-registration.
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"hexai/internal/llm"
+)
+
+func TestDidChangeConfiguration_ReloadsWithEditorSettings(t *testing.T) {
+	s := newTestServer()
+	s.setDocument("file:///a.go", "package a\n")
+	s.compCache = map[string]string{"k": "v"}
+	var got json.RawMessage
+	s.reloadConfig = func(_ string, settings json.RawMessage) (ServerOptions, error) {
+		got = settings
+		return ServerOptions{MaxTokens: 42, ContextMode: "minimal"}, nil
+	}
+	s.handleDidChangeConfiguration(Request{Params: json.RawMessage(`{"settings":{"hexai":{"max_tokens":42}}}`)})
+	if string(got) != `{"hexai":{"max_tokens":42}}` {
+		t.Fatalf("settings not passed to reload: %s", got)
+	}
+	if s.config().maxTokens != 42 || s.config().contextMode != "minimal" || len(s.compCache) != 0 {
+		t.Fatalf("reload not applied: maxTokens=%d mode=%s cache=%d", s.config().maxTokens, s.config().contextMode, len(s.compCache))
+	}
+	if s.getDocument("file:///a.go") == nil {
+		t.Fatalf("open documents must survive a reload")
+	}
+}
+
+func TestCheckConfigFiles_ReloadsOnChange(t *testing.T) {
+	s := newTestServer()
+	path := filepath.Join(t.TempDir(), "config.json")
+	reloads := 0
+	s.reloadConfig = func(string, json.RawMessage) (ServerOptions, error) {
+		reloads++
+		return ServerOptions{ConfigFiles: []string{path}}, nil
+	}
+	setConfig(s, func(c *serverConfig) { c.configFiles = []string{path} })
+	stamp := s.checkConfigFiles(s.configStamp())
+	if reloads != 0 {
+		t.Fatalf("unchanged files must not reload")
+	}
+	if err := os.WriteFile(path, []byte(`{"max_tokens": 9}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stamp = s.checkConfigFiles(stamp)
+	if reloads != 1 {
+		t.Fatalf("expected a reload after the file was created, got %d", reloads)
+	}
+	if s.checkConfigFiles(stamp); reloads != 1 {
+		t.Fatalf("expected no further reload, got %d", reloads)
+	}
+}
+
+func TestReload_ReregistersCompletionWhenTriggerCharsChange(t *testing.T) {
+	s := newTestServer()
+	msgs := clientPipe(t, s)
+	chars := []string{"."}
+	s.reloadConfig = func(string, json.RawMessage) (ServerOptions, error) {
+		return ServerOptions{TriggerCharacters: chars}, nil
+	}
+	if err := s.reload("test"); err != nil {
+		t.Fatal(err)
+	}
+	s.registerCompletion(s.advertisedTriggerChars())
+	nextMethod(t, msgs, "client/registerCapability")
+	chars = []string{".", "#"}
+	if err := s.reload("test"); err != nil {
+		t.Fatal(err)
+	}
+	nextMethod(t, msgs, "client/unregisterCapability")
+	var p RegistrationParams
+	_ = json.Unmarshal(nextMethod(t, msgs, "client/registerCapability")["params"], &p)
+	b, _ := json.Marshal(p.Registrations[0].RegisterOptions)
+	if string(b) != `{"documentSelector":null,"triggerCharacters":[".","#"]}` {
+		t.Fatalf("unexpected register options: %s", b)
+	}
+}
+
+// TestReload_WhileRequestsRun reloads settings that disable and re-enable
+// the LLM while requests are handled; run with -race.
+func TestReload_WhileRequestsRun(t *testing.T) {
+	enabled := ServerOptions{Client: fakeLLM{resp: "x"}, HoverEnabled: true, ReviewLanguages: []string{"go"}}
+	s := NewServer(strings.NewReader(""), io.Discard, log.New(io.Discard, "", 0), enabled)
+	uri := "file:///a.go"
+	s.setDocument(uri, "package a\n\nfunc A() {\n\tx.y // tidy this\n}\n")
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if i%2 == 0 {
+				s.applyOptions(ServerOptions{MaxTokens: 10})
+			} else {
+				s.applyOptions(enabled)
+			}
+		}
+	}()
+	doc := TextDocumentIdentifier{URI: uri}
+	pos := Position{Line: 3, Character: 3}
+	lens, _ := json.Marshal(CodeLensParams{TextDocument: doc})
+	hover, _ := json.Marshal(HoverParams{TextDocument: doc, Position: pos})
+	comp, _ := json.Marshal(CompletionParams{TextDocument: doc, Position: pos})
+	sel := CodeActionParams{TextDocument: doc, Range: Range{Start: Position{Line: 2}, End: Position{Line: 4, Character: 1}}}
+	action, _ := json.Marshal(sel)
+	id := json.RawMessage("1")
+	for i := 0; i < 100; i++ {
+		s.handleCodeLens(Request{ID: id, Params: lens})
+		s.handleHover(Request{ID: id, Params: hover})
+		s.handleCompletion(Request{ID: id, Params: comp})
+		s.handleCodeAction(Request{ID: id, Params: action})
+		if ca := s.buildRewriteCodeAction(sel, "func A() {\n\tx.y // tidy this\n}"); ca != nil {
+			s.resolveCodeAction(context.Background(), *ca)
+		}
+		s.detectAndHandleChat(uri)
+	}
+	close(stop)
+	<-done
+}
+
+// queryCountingEmbedder is a wordEmbedder that counts its calls.
+type queryCountingEmbedder struct {
+	wordEmbedder
+	calls int
+}
+
+func (e *queryCountingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	e.calls++
+	return e.wordEmbedder.Embed(ctx, texts)
+}
+
+func TestReload_AppliesEmbedder(t *testing.T) {
+	s := newTestServer()
+	s.root = t.TempDir()
+	var next llm.Embedder
+	s.reloadConfig = func(string, json.RawMessage) (ServerOptions, error) {
+		return ServerOptions{Embedder: next, NoDiskIO: true, RetrievalTopK: 1}, nil
+	}
+	next = wordEmbedder{}
+	if err := s.reload("initialize"); err != nil || s.vectorIndex() != nil {
+		t.Fatalf("the index is started by initialize itself, got %v", err)
+	}
+	if err := s.reload("command"); err != nil || s.vectorIndex() == nil {
+		t.Fatalf("an embedding provider enabled by a reload must start the index, got %v", err)
+	}
+	ix := s.vectorIndex()
+	_ = ix.UpdateFile(context.Background(), "/w/db.go", "func openDatabase() { sql pool }")
+	counting := &queryCountingEmbedder{}
+	next = counting
+	if err := s.reload("command"); err != nil || s.vectorIndex() != ix {
+		t.Fatalf("the same embedding model must keep the index, got %v", err)
+	}
+	if got := s.similarChunks("sql pool", "file:///w/main.go"); !strings.Contains(got, "openDatabase") || counting.calls != 1 {
+		t.Fatalf("queries must use the reloaded embedder: calls=%d got %q", counting.calls, got)
+	}
+	next = nil
+	if err := s.reload("command"); err != nil || s.vectorIndex() != nil {
+		t.Fatalf("removing the embedding provider must drop the index, got %v", err)
+	}
+}
@@ -12,24 +12,43 @@ import (
 // - window: include a window of lines around the cursor
 // - file-on-new-func: include full file only when defining a new function
 // - always-full: always include the full file
+// - cross-file: window plus ranked snippets of other open, sibling and imported files
+// - retrieval: window plus the best BM25 matches from the lexical workspace index
+// The mode may be overridden per language (see settingsFor). Except in
+// minimal mode, similar chunks from the embeddings index are appended when
+// completion_retrieval is set, since each costs a query embedding.
 func (s *Server) buildAdditionalContext(newFunc bool, uri string, pos Position) (string, bool) {
-	mode := s.contextMode
+	mode := s.settingsFor(uri).contextMode
+	var text string
 	switch mode {
 	case "minimal":
 		return "", false
 	case "window":
-		return s.windowContext(uri, pos), true
+		text = s.windowContext(uri, pos)
 	case "file-on-new-func":
 		if newFunc {
-			return s.fullFileContext(uri), true
+			text = s.fullFileContext(uri)
 		}
-		return "", false
 	case "always-full":
-		return s.fullFileContext(uri), true
+		text = s.fullFileContext(uri)
+	case "cross-file":
+		text = s.crossFileContext(uri, pos)
+	case "retrieval":
+		text = s.retrievalContext(uri, pos)
 	default:
 		// fallback to minimal if unknown
 		return "", false
 	}
+	similar := ""
+	if s.config().completionRetrieval {
+		similar = s.similarChunks(s.cursorRegion(uri, pos), uri)
+	}
+	if similar != "" {
+		text = strings.TrimRight(text+"\n\n"+similar, "\n")
+	} else if text == "" && mode == "file-on-new-func" {
+		return "", false
+	}
+	return text, true
 }
 
 func (s *Server) windowContext(uri string, pos Position) string {
@@ -38,8 +57,12 @@ func (s *Server) windowContext(uri string, pos Position) string {
 		logging.Logf("lsp ", "context: window requested but document not open; skipping uri=%s", uri)
 		return ""
 	}
+	if s.excluded(uri) {
+		return ""
+	}
+	cfg := s.config()
 	n := len(d.lines)
-	half := s.windowLines / 2
+	half := cfg.windowLines / 2
 	start := pos.Line - half
 	if start < 0 {
 		start = 0
@@ -49,7 +72,7 @@ func (s *Server) windowContext(uri string, pos Position) string {
 		end = n
 	}
 	text := strings.Join(d.lines[start:end], "\n")
-	return truncateToApproxTokens(text, s.maxContextTokens)
+	return truncateToApproxTokens(text, cfg.maxContextTokens)
 }
 
 func (s *Server) fullFileContext(uri string) string {
@@ -58,7 +81,10 @@ func (s *Server) fullFileContext(uri string) string {
 		logging.Logf("lsp ", "context: full-file requested but document not open; skipping uri=%s", uri)
 		return ""
 	}
-	return truncateToApproxTokens(d.text, s.maxContextTokens)
+	if s.excluded(uri) {
+		return ""
+	}
+	return truncateToApproxTokens(d.Text(), s.config().maxContextTokens)
 }
 
 // truncateToApproxTokens naively truncates the input to fit approx N tokens.
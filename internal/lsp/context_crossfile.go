@@ -0,0 +1,310 @@
+// Summary: Cross-file context; ranks snippets of open documents, sibling files and imported local Go packages by identifier overlap.
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"hexai/internal/logging"
+)
+
+const (
+	crossFileRegionLines = 12 // lines above and below the cursor used as the query
+	crossFileMaxFiles    = 60 // files read from disk per request
+	crossFileChunkLines  = 60 // longest snippet taken from another file
+	crossFileMaxFileSize = 256 << 10
+	crossFileCacheSize   = 512 // chunked files kept between requests
+)
+
+// snippet is a top-level block of another file, scored for one request.
+type snippet struct {
+	path  string
+	start int
+	text  string
+	score int
+}
+
+// crossFileSource is another file offered to a request. stamp identifies
+// its content: the document for open files (replaced on every change), the
+// modification time and size for files on disk. load returns its lines, or
+// nil when it cannot be read.
+type crossFileSource struct {
+	stamp any
+	load  func() []string
+}
+
+type fileStamp struct {
+	mtime time.Time
+	size  int64
+}
+
+// crossFileEntry caches the chunks of one file and the identifiers of each
+// chunk while the file's stamp is unchanged.
+type crossFileEntry struct {
+	stamp  any
+	chunks []snippet
+	ids    []map[string]bool
+}
+
+// crossFileContext returns the window around the cursor followed by the
+// snippets of other files that share the most identifiers with it. The
+// window gets half of max_context_tokens, the snippets the rest.
+func (s *Server) crossFileContext(uri string, pos Position) string {
+	d := s.getDocument(uri)
+	if d == nil || len(d.lines) == 0 {
+		logging.Logf("lsp ", "context: cross-file requested but document not open; skipping uri=%s", uri)
+		return ""
+	}
+	maxTokens := s.config().maxContextTokens
+	window := truncateToApproxTokens(s.windowContext(uri, pos), maxTokens/2)
+	line := clampLine(d, pos.Line)
+	from, to := max(0, line-crossFileRegionLines), min(len(d.lines), line+crossFileRegionLines+1)
+	query := identifiers(strings.Join(d.lines[from:to], "\n"))
+	var ranked []snippet
+	for path, src := range s.crossFileSources(d) {
+		e := s.crossFileChunks(path, src)
+		for i, sn := range e.chunks {
+			if sn.score = overlapScore(e.ids[i], query); sn.score > 0 {
+				ranked = append(ranked, sn)
+			}
+		}
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		if ranked[i].path != ranked[j].path {
+			return ranked[i].path < ranked[j].path
+		}
+		return ranked[i].start < ranked[j].start
+	})
+	budget := (maxTokens - approxTokens(window)) * 4
+	var b strings.Builder
+	b.WriteString(window)
+	for _, sn := range ranked {
+		part := "\n\n// From " + sn.path + "\n" + sn.text
+		if len(part) > budget {
+			continue
+		}
+		budget -= len(part)
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+func approxTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// crossFileSources returns the other files relevant to d, keyed by
+// path (or URI for open documents without a file path): all other open
+// documents and, when disk IO is allowed, sibling files with the same
+// extension and the files of imported packages of the same Go module.
+// Open documents take precedence over their content on disk.
+func (s *Server) crossFileSources(d *document) map[string]crossFileSource {
+	out := make(map[string]crossFileSource)
+	s.mu.RLock()
+	others := make(map[string]*document)
+	for uri, od := range s.docs {
+		if uri != d.uri {
+			others[uriToPath(uri)] = od
+		}
+	}
+	s.mu.RUnlock()
+	for path, od := range others {
+		if !s.skipSource(path) {
+			out[path] = crossFileSource{stamp: od, load: func() []string { return od.lines }}
+		}
+	}
+	if s.config().noDiskIO || !strings.HasPrefix(d.uri, "file://") {
+		return out
+	}
+	self := uriToPath(d.uri)
+	ext := filepath.Ext(self)
+	dirs := []string{filepath.Dir(self)}
+	if ext == ".go" {
+		dirs = append(dirs, localImportDirs(d, filepath.Dir(self))...)
+	}
+	read := 0
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			p := filepath.Join(dir, e.Name())
+			if _, open := out[p]; e.IsDir() || filepath.Ext(p) != ext || p == self || open || read >= crossFileMaxFiles {
+				continue
+			}
+			if ext == ".go" && strings.HasSuffix(p, "_test.go") && !strings.HasSuffix(self, "_test.go") {
+				continue
+			}
+			if s.skipSource(p) {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil || info.Size() > crossFileMaxFileSize {
+				continue
+			}
+			out[p] = crossFileSource{stamp: fileStamp{info.ModTime(), info.Size()}, load: func() []string {
+				if b, err := os.ReadFile(p); err == nil {
+					return splitLines(string(b))
+				}
+				return nil
+			}}
+			read++
+		}
+	}
+	return out
+}
+
+// crossFileChunks returns the chunks of path and their identifiers, from
+// the cache while src's stamp matches and otherwise loaded and chunked
+// again. The cache is cleared when it reaches crossFileCacheSize files.
+func (s *Server) crossFileChunks(path string, src crossFileSource) crossFileEntry {
+	s.mu.RLock()
+	e, ok := s.crossFileCache[path]
+	s.mu.RUnlock()
+	if ok && e.stamp == src.stamp {
+		return e
+	}
+	lines := src.load()
+	if lines == nil {
+		return crossFileEntry{}
+	}
+	e = crossFileEntry{stamp: src.stamp, chunks: chunkFile(path, lines)}
+	for _, sn := range e.chunks {
+		e.ids = append(e.ids, identifiers(sn.text))
+	}
+	s.mu.Lock()
+	if s.crossFileCache == nil || len(s.crossFileCache) >= crossFileCacheSize {
+		s.crossFileCache = make(map[string]crossFileEntry)
+	}
+	s.crossFileCache[path] = e
+	s.mu.Unlock()
+	return e
+}
+
+// skipSource reports whether another file must be left out of the context
+// because an ignore rule matches it.
+func (s *Server) skipSource(path string) bool {
+	reason, ok := s.excludedPath(path)
+	if ok {
+		logging.Logf("lsp ", "ignore: leaving %s out of the context rule=%q", path, reason)
+	}
+	return ok
+}
+
+// localImportDirs maps the imports of a Go document that belong to the
+// enclosing module (found via go.mod above dir) to their directories.
+func localImportDirs(d *document, dir string) []string {
+	root, module := findGoModule(dir)
+	if module == "" {
+		return nil
+	}
+	var out []string
+	for _, imp := range goImports(d.goAST().file) {
+		if i := strings.LastIndexByte(imp, ' '); i >= 0 {
+			imp = imp[i+1:] // drop the import name
+		}
+		if rel, ok := strings.CutPrefix(imp, module+"/"); ok {
+			out = append(out, filepath.Join(root, filepath.FromSlash(rel)))
+		}
+	}
+	return out
+}
+
+// findGoModule walks up from dir to the nearest go.mod and returns its
+// directory and module path.
+func findGoModule(dir string) (root, module string) {
+	for {
+		if b, err := os.ReadFile(filepath.Join(dir, "go.mod")); err == nil {
+			for _, line := range splitLines(string(b)) {
+				if m, ok := strings.CutPrefix(strings.TrimSpace(line), "module "); ok {
+					return dir, strings.Trim(strings.TrimSpace(m), `"`)
+				}
+			}
+			return dir, ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ""
+		}
+		dir = parent
+	}
+}
+
+// chunkFile splits a file into top-level blocks: each starts at an
+// unindented line after a blank line, or at a declaration, and includes
+// the comment lines directly above it. Blocks are capped at
+// crossFileChunkLines.
+func chunkFile(path string, lines []string) []snippet {
+	var out []snippet
+	start := 0
+	flush := func(end int) {
+		for start < end && strings.TrimSpace(lines[start]) == "" {
+			start++
+		}
+		if text := strings.TrimRight(strings.Join(lines[start:end], "\n"), "\n\t "); text != "" {
+			out = append(out, snippet{path: path, start: start, text: text})
+		}
+		start = end
+	}
+	for i := 1; i < len(lines); i++ {
+		t := lines[i]
+		topLevel := t != "" && leadingIndent(t) == "" && !strings.HasPrefix(t, "}") && !strings.HasPrefix(t, ")")
+		if topLevel && (isDeclStart(t) || strings.TrimSpace(lines[i-1]) == "") {
+			j := i
+			for j > start && isCommentLine(lines[j-1]) {
+				j--
+			}
+			if j > start {
+				flush(j)
+			}
+		}
+		if i-start >= crossFileChunkLines {
+			flush(i)
+		}
+	}
+	flush(len(lines))
+	return out
+}
+
+func isCommentLine(line string) bool {
+	t := strings.TrimSpace(line)
+	return strings.HasPrefix(t, "//") || strings.HasPrefix(t, "#") || strings.HasPrefix(t, "--")
+}
+
+// identifiers returns the distinct identifiers of at least three characters
+// in text, without common keywords.
+func identifiers(text string) map[string]bool {
+	out := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(text, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' }) {
+		if len(w) >= 3 && !unicode.IsDigit(rune(w[0])) && !commonKeywords[w] {
+			out[w] = true
+		}
+	}
+	return out
+}
+
+var commonKeywords = map[string]bool{
+	"func": true, "return": true, "var": true, "const": true, "type": true, "struct": true, "interface": true,
+	"package": true, "import": true, "for": true, "range": true, "nil": true, "true": true, "false": true,
+	"def": true, "class": true, "self": true, "None": true, "True": true, "False": true, "pub": true,
+	"let": true, "mut": true, "impl": true, "else": true, "error": true, "string": true, "int": true,
+}
+
+// overlapScore counts the query identifiers among ids.
+func overlapScore(ids, query map[string]bool) int {
+	n := 0
+	for id := range ids {
+		if query[id] {
+			n++
+		}
+	}
+	return n
+}
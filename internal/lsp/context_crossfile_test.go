@@ -0,0 +1,101 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChunkFile_TopLevelBlocksWithComments(t *testing.T) {
+	lines := splitLines("package p\n\n// Helper does x.\nfunc Helper() {\n\tx()\n}\n\nvar v = 1\nfunc Other() {}")
+	chunks := chunkFile("p.go", lines)
+	var got []string
+	for _, c := range chunks {
+		got = append(got, c.text)
+	}
+	want := []string{"package p", "// Helper does x.\nfunc Helper() {\n\tx()\n}", "var v = 1", "func Other() {}"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("chunks got %q", got)
+	}
+}
+
+func TestCrossFileContext_RanksOpenDocumentsAndSiblings(t *testing.T) {
+	dir := t.TempDir()
+	write := func(rel, content string) {
+		p := filepath.Join(dir, rel)
+		_ = os.MkdirAll(filepath.Dir(p), 0o755)
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("go.mod", "module example.com/m\n\ngo 1.21\n")
+	write("util/util.go", "package util\n\nfunc ParseRecord(s string) Record { return Record{} }\n")
+	write("a/sibling.go", "package a\n\nfunc formatRecord(r util.Record) string { return \"\" }\n\nfunc unrelated() {}\n")
+	write("a/sibling_test.go", "package a\n\nfunc TestFormatRecord() { formatRecord(util.Record{}) }\n")
+	s := newTestServer()
+	setConfig(s, func(c *serverConfig) {
+		c.maxContextTokens = 2000
+		c.windowLines = 10
+	})
+	uri := "file://" + filepath.Join(dir, "a", "main.go")
+	s.setDocument(uri, "package a\n\nimport \"example.com/m/util\"\n\nfunc run() {\n\tr := util.ParseRecord(line)\n\t_ = formatRecord(r)\n}\n")
+	s.setDocument("file:///other/notes.go", "package other\n\nfunc lineCount() {}\n\nfunc runRecordCheck(line string) {}\n")
+	got := s.crossFileContext(uri, Position{Line: 5, Character: 2})
+	for _, want := range []string{"func ParseRecord(", "func formatRecord(", "func runRecordCheck("} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("missing %q in context:\n%s", want, got)
+		}
+	}
+	for _, unwanted := range []string{"unrelated", "TestFormatRecord", "lineCount"} {
+		if strings.Contains(got, unwanted) {
+			t.Fatalf("unexpected %q in context:\n%s", unwanted, got)
+		}
+	}
+	setConfig(s, func(c *serverConfig) { c.noDiskIO = true })
+	if got := s.crossFileContext(uri, Position{Line: 5}); strings.Contains(got, "ParseRecord(s string)") {
+		t.Fatalf("disk files must not be read with no_disk_io:\n%s", got)
+	}
+}
+
+func TestCrossFileContext_RespectsTokenBudget(t *testing.T) {
+	s := newTestServer()
+	setConfig(s, func(c *serverConfig) {
+		c.maxContextTokens = 40
+		c.windowLines = 2
+		c.noDiskIO = true
+	})
+	s.setDocument("file:///a.go", "package a\nfunc f() { sharedName() }")
+	s.setDocument("file:///b.go", "package a\n\nfunc sharedName() {\n"+strings.Repeat("\t// padding padding padding\n", 20)+"}")
+	if got := s.crossFileContext("file:///a.go", Position{Line: 1}); len(got) > 40*4 {
+		t.Fatalf("context exceeds budget: %d chars", len(got))
+	}
+}
+
+func TestCrossFileContext_ReusesChunksUntilFileChanges(t *testing.T) {
+	dir := t.TempDir()
+	sibling := filepath.Join(dir, "b.go")
+	if err := os.WriteFile(sibling, []byte("package a\n\nfunc loadRecord() {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer()
+	setConfig(s, func(c *serverConfig) { c.maxContextTokens = 2000; c.windowLines = 10 })
+	uri := "file://" + filepath.Join(dir, "a.go")
+	s.setDocument(uri, "package a\n\nfunc run() { loadRecord(); saveRecord() }\n")
+	if got := s.crossFileContext(uri, Position{Line: 2}); !strings.Contains(got, "func loadRecord()") {
+		t.Fatalf("missing sibling snippet:\n%s", got)
+	}
+	// same size and modification time: the cached chunks are used
+	info, _ := os.Stat(sibling)
+	_ = os.WriteFile(sibling, []byte("package a\n\nfunc saveRecord() {}\n"), 0o644)
+	_ = os.Chtimes(sibling, info.ModTime(), info.ModTime())
+	if got := s.crossFileContext(uri, Position{Line: 2}); !strings.Contains(got, "func loadRecord()") {
+		t.Fatalf("expected cached chunks:\n%s", got)
+	}
+	later := info.ModTime().Add(time.Second)
+	_ = os.Chtimes(sibling, later, later)
+	if got := s.crossFileContext(uri, Position{Line: 2}); !strings.Contains(got, "func saveRecord()") {
+		t.Fatalf("expected chunks of the changed file:\n%s", got)
+	}
+}
@@ -0,0 +1,106 @@
+// Summary: Retrieval context mode; BM25 search of a lexical workspace index built at initialize and refreshed from document events.
+package lsp
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"hexai/internal/logging"
+	"hexai/internal/retrieval"
+)
+
+// retrievalMaxResults caps the snippets considered per request; the token
+// budget usually admits fewer.
+const retrievalMaxResults = 20
+
+// startLexicalIndex creates the lexical index and builds it from root in
+// the background. Without a root, or with no_disk_io, only open documents
+// are indexed.
+func (s *Server) startLexicalIndex(root string) {
+	ix := retrieval.NewLexicalIndex()
+	s.mu.Lock()
+	s.lexical = ix
+	s.mu.Unlock()
+	if root == "" || s.config().noDiskIO {
+		return
+	}
+	go func() {
+		start := time.Now()
+		if err := ix.Build(root, s.skipExcluded); err != nil {
+			logging.Logf("lsp ", "lexical index build error: %v", err)
+		}
+		logging.Logf("lsp ", "lexical index built root=%s chunks=%d duration=%s", root, ix.Len(), time.Since(start))
+	}()
+}
+
+// markLexicalStale records that the open document uri changed. Stale
+// documents are re-indexed on the next search rather than on every
+// keystroke.
+func (s *Server) markLexicalStale(uri string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lexical == nil {
+		return
+	}
+	if s.lexicalStale == nil {
+		s.lexicalStale = make(map[string]bool)
+	}
+	s.lexicalStale[uri] = true
+}
+
+// lexicalIndex returns the index after re-indexing stale open documents,
+// or nil when the retrieval mode was not enabled at initialize.
+func (s *Server) lexicalIndex() *retrieval.LexicalIndex {
+	s.mu.Lock()
+	ix, stale := s.lexical, s.lexicalStale
+	s.lexicalStale = nil
+	s.mu.Unlock()
+	for uri := range stale {
+		if _, ok := s.excludedPath(uriToPath(uri)); ok {
+			ix.RemoveFile(uriToPath(uri))
+		} else if d := s.getDocument(uri); d != nil {
+			ix.UpdateFile(uriToPath(uri), d.Text())
+		}
+	}
+	return ix
+}
+
+// retrievalContext returns the window around the cursor followed by the
+// best BM25 matches for the identifiers around it. The window gets half of
+// max_context_tokens, the snippets the rest.
+func (s *Server) retrievalContext(uri string, pos Position) string {
+	d := s.getDocument(uri)
+	if d == nil || len(d.lines) == 0 {
+		logging.Logf("lsp ", "context: retrieval requested but document not open; skipping uri=%s", uri)
+		return ""
+	}
+	maxTokens := s.config().maxContextTokens
+	window := truncateToApproxTokens(s.windowContext(uri, pos), maxTokens/2)
+	ix := s.lexicalIndex()
+	if ix == nil {
+		logging.Logf("lsp ", "context: retrieval index not built; using window only")
+		return window
+	}
+	line := clampLine(d, pos.Line)
+	from, to := max(0, line-crossFileRegionLines), min(len(d.lines), line+crossFileRegionLines+1)
+	var query []string
+	for id := range identifiers(strings.Join(d.lines[from:to], "\n")) {
+		query = append(query, id)
+	}
+	budget := (maxTokens - approxTokens(window)) * 4
+	var b strings.Builder
+	b.WriteString(window)
+	for _, r := range ix.Search(strings.Join(query, " "), retrievalMaxResults, uriToPath(uri)) {
+		if s.skipSource(r.Path) {
+			continue
+		}
+		part := fmt.Sprintf("\n\n// From %s:%d-%d\n%s", r.Path, r.Start+1, r.End+1, r.Text)
+		if len(part) > budget {
+			continue
+		}
+		budget -= len(part)
+		b.WriteString(part)
+	}
+	return b.String()
+}
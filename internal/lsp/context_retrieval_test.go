@@ -0,0 +1,46 @@
+package lsp
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetrievalContext_RanksWorkspaceSnippets(t *testing.T) {
+	root := t.TempDir()
+	_ = os.WriteFile(filepath.Join(root, "db.go"), []byte("package w\n\nfunc openDatabase(dsn string) *Pool {\n\treturn newPool(dsn)\n}\n"), 0o644)
+	_ = os.WriteFile(filepath.Join(root, "http.go"), []byte("package w\n\nfunc serveHTTP() {}\n"), 0o644)
+	s := newTestServer()
+	setConfig(s, func(c *serverConfig) {
+		c.contextMode = "retrieval"
+		c.maxContextTokens = 1000
+	})
+	s.startLexicalIndex(root)
+	for deadline := time.Now().Add(2 * time.Second); s.lexical.Len() < 2 && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+	}
+	uri := "file://" + filepath.Join(root, "main.go")
+	s.handleDidOpen(Request{Params: json.RawMessage(`{"textDocument":{"uri":"` + uri + `","version":1,"text":"package w\n\nfunc main() {\n\tp := openDatabase(cfg)\n}"}}`)})
+	text, ok := s.buildAdditionalContext(false, uri, Position{Line: 3})
+	if !ok || !strings.Contains(text, "// From "+filepath.Join(root, "db.go")+":1-") || strings.Contains(text, "http.go") {
+		t.Fatalf("expected the db.go snippet only, got %q", text)
+	}
+	if strings.Count(text, "openDatabase(cfg)") != 1 {
+		t.Fatalf("the current file must not be retrieved again: %q", text)
+	}
+}
+
+func TestRetrievalContext_RefreshesChangedDocuments(t *testing.T) {
+	s := newTestServer()
+	setConfig(s, func(c *serverConfig) { c.contextMode = "retrieval" })
+	s.startLexicalIndex("")
+	s.handleDidOpen(Request{Params: json.RawMessage(`{"textDocument":{"uri":"file:///w/a.go","version":1,"text":"func alpha() {}"}}`)})
+	s.handleDidChange(Request{Params: json.RawMessage(`{"textDocument":{"uri":"file:///w/a.go","version":2},"contentChanges":[{"text":"func beta() {}"}]}`)})
+	ix := s.lexicalIndex()
+	if len(ix.Search("alpha", 5)) != 0 || len(ix.Search("beta", 5)) != 1 {
+		t.Fatalf("index not refreshed from didChange")
+	}
+}
@@ -9,8 +9,10 @@ import (
 
 func TestWindowContext_Bounds(t *testing.T) {
 	s := newTestServer()
-	s.windowLines = 4 // half=2
-	s.maxContextTokens = 9999
+	setConfig(s, func(c *serverConfig) {
+		c.windowLines = 4 // half=2
+		c.maxContextTokens = 9999
+	})
 	lines := make([]string, 10)
 	for i := 0; i < 10; i++ {
 		lines[i] = "L" + strconv.Itoa(i)
@@ -28,7 +30,7 @@ func TestWindowContext_Bounds(t *testing.T) {
 
 func TestBuildAdditionalContext_Minimal(t *testing.T) {
 	s := newTestServer()
-	s.contextMode = "minimal"
+	setConfig(s, func(c *serverConfig) { c.contextMode = "minimal" })
 	if ctx, ok := s.buildAdditionalContext(false, "file:///x.go", Position{}); ok || ctx != "" {
 		t.Fatalf("expected no context in minimal mode; got ok=%v ctx=%q", ok, ctx)
 	}
@@ -36,8 +38,10 @@ func TestBuildAdditionalContext_Minimal(t *testing.T) {
 
 func TestBuildAdditionalContext_FileOnNewFunc(t *testing.T) {
 	s := newTestServer()
-	s.contextMode = "file-on-new-func"
-	s.maxContextTokens = 9999
+	setConfig(s, func(c *serverConfig) {
+		c.contextMode = "file-on-new-func"
+		c.maxContextTokens = 9999
+	})
 	uri := "file:///x.go"
 	body := "package x\n\nfunc a(){}\n"
 	s.setDocument(uri, body)
@@ -51,8 +55,10 @@ func TestBuildAdditionalContext_FileOnNewFunc(t *testing.T) {
 
 func TestBuildAdditionalContext_AlwaysFull(t *testing.T) {
 	s := newTestServer()
-	s.contextMode = "always-full"
-	s.maxContextTokens = 9999
+	setConfig(s, func(c *serverConfig) {
+		c.contextMode = "always-full"
+		c.maxContextTokens = 9999
+	})
 	uri := "file:///x.go"
 	body := "line1\nline2\n"
 	s.setDocument(uri, body)
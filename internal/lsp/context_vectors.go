@@ -0,0 +1,131 @@
+// Summary: Workspace embeddings index for the LSP; built at initialize, updated on save, and queried for similar chunks.
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"hexai/internal/llm"
+	"hexai/internal/logging"
+	"hexai/internal/retrieval"
+)
+
+// similarQueryLines is the number of lines above and below the cursor used
+// as the similarity query for completions.
+const similarQueryLines = 10
+
+// startVectorIndex loads the stored index of root and refreshes it in the
+// background. With no_disk_io the workspace is not read; only saved
+// documents are indexed.
+func (s *Server) startVectorIndex(root string) {
+	store := ""
+	if s.indexDir != "" {
+		store = retrieval.StorePath(s.indexDir, root)
+	}
+	s.mu.Lock()
+	ix := retrieval.NewVectorIndex(s.embedder, store)
+	s.vectors = ix
+	s.mu.Unlock()
+	if s.config().noDiskIO {
+		return
+	}
+	go func() {
+		start := time.Now()
+		if err := ix.Build(context.Background(), root, s.skipExcluded); err != nil {
+			logging.Logf("lsp ", "index build error: %v", err)
+		}
+		if err := ix.Save(); err != nil {
+			logging.Logf("lsp ", "index save error: %v", err)
+		}
+		logging.Logf("lsp ", "index built root=%s chunks=%d duration=%s", root, ix.Len(), time.Since(start))
+	}()
+}
+
+// setEmbedder applies the embedder of reloaded options, built from the
+// user, project and editor config. A running index keeps its vectors when
+// the embedding model is unchanged and is rebuilt for root otherwise. With
+// start false (the reload at initialize) an index that is not running yet
+// is left to handleInitialize.
+func (s *Server) setEmbedder(e llm.Embedder, root string, start bool) {
+	s.mu.Lock()
+	old, ix := s.embedder, s.vectors
+	s.embedder = e
+	keep := ix != nil && e != nil && old != nil && e.EmbeddingModel() == old.EmbeddingModel()
+	if !keep {
+		s.vectors = nil
+	}
+	s.mu.Unlock()
+	if keep {
+		ix.SetEmbedder(e)
+	} else if e != nil && root != "" && (ix != nil || start) {
+		logging.Logf("lsp ", "index rebuilt for embedding model=%s", e.EmbeddingModel())
+		s.startVectorIndex(root)
+	}
+}
+
+// updateVectorIndex re-embeds the changed chunks of a saved document.
+func (s *Server) updateVectorIndex(d *document) {
+	ix := s.vectorIndex()
+	if ix == nil {
+		return
+	}
+	if path := uriToPath(d.uri); s.skipExcluded(path) {
+		ix.RemoveFile(path)
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+		if err := ix.UpdateFile(ctx, uriToPath(d.uri), d.Text()); err != nil {
+			logging.Logf("lsp ", "index update error uri=%s: %v", d.uri, err)
+			return
+		}
+		if err := ix.Save(); err != nil {
+			logging.Logf("lsp ", "index save error: %v", err)
+		}
+	}()
+}
+
+func (s *Server) vectorIndex() *retrieval.VectorIndex {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.vectors
+}
+
+// similarChunks returns the retrieval_top_k indexed chunks most similar to
+// query, excluding the document uri itself, formatted for a prompt. It
+// returns "" when the index is disabled or the lookup fails.
+func (s *Server) similarChunks(query, uri string) string {
+	ix, topK := s.vectorIndex(), s.config().retrievalTopK
+	if ix == nil || topK <= 0 || strings.TrimSpace(query) == "" {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	results, err := ix.Search(ctx, query, topK, uriToPath(uri))
+	if err != nil {
+		logging.Logf("lsp ", "index search error: %v", err)
+		return ""
+	}
+	var b strings.Builder
+	for _, r := range results {
+		if s.skipSource(r.Path) {
+			continue // stored before the ignore rule existed
+		}
+		fmt.Fprintf(&b, "// From %s:%d-%d\n%s\n\n", r.Path, r.Start+1, r.End+1, r.Text)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// cursorRegion returns the lines around pos used as a similarity query.
+func (s *Server) cursorRegion(uri string, pos Position) string {
+	d := s.getDocument(uri)
+	if d == nil || len(d.lines) == 0 || s.vectorIndex() == nil {
+		return ""
+	}
+	line := clampLine(d, pos.Line)
+	from, to := max(0, line-similarQueryLines), min(len(d.lines), line+similarQueryLines+1)
+	return strings.Join(d.lines[from:to], "\n")
+}
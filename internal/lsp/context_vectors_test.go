@@ -0,0 +1,63 @@
+package lsp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"hexai/internal/retrieval"
+)
+
+// wordEmbedder embeds texts as counts of a fixed vocabulary.
+type wordEmbedder struct{}
+
+var embedVocab = []string{"database", "pool", "sql", "http", "handler", "request"}
+
+func (wordEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, t := range texts {
+		v := make([]float32, len(embedVocab)+1)
+		v[len(embedVocab)] = 0.1
+		for j, w := range embedVocab {
+			v[j] = float32(strings.Count(strings.ToLower(t), w))
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+func (wordEmbedder) EmbeddingModel() string { return "test:words" }
+
+func TestSimilarChunks_AddedToCompletionContext(t *testing.T) {
+	s := newTestServer()
+	setConfig(s, func(c *serverConfig) {
+		c.contextMode = "minimal"
+		c.retrievalTopK = 1
+	})
+	s.vectors = retrieval.NewVectorIndex(wordEmbedder{}, "")
+	ctx := context.Background()
+	_ = s.vectors.UpdateFile(ctx, "/w/db.go", "func openDatabase() { sql pool }")
+	_ = s.vectors.UpdateFile(ctx, "/w/http.go", "func serve() { http handler request }")
+	uri := "file:///w/main.go"
+	s.setDocument(uri, "package main\n\nfunc main() {\n\tdb := openDatabase() // sql pool\n}")
+	if text, ok := s.buildAdditionalContext(false, uri, Position{Line: 3}); ok || text != "" {
+		t.Fatalf("minimal mode must not add context, got %q", text)
+	}
+	setConfig(s, func(c *serverConfig) { c.contextMode = "window" })
+	if text, _ := s.buildAdditionalContext(false, uri, Position{Line: 3}); strings.Contains(text, "// From") {
+		t.Fatalf("completions must opt in to similar chunks, got %q", text)
+	}
+	setConfig(s, func(c *serverConfig) { c.completionRetrieval = true })
+	text, ok := s.buildAdditionalContext(false, uri, Position{Line: 3})
+	if !ok || !strings.Contains(text, "// From /w/db.go:1-1") || strings.Contains(text, "http.go") {
+		t.Fatalf("expected the most similar chunk only, got %q", text)
+	}
+}
+
+func TestSimilarChunks_DisabledWithoutIndex(t *testing.T) {
+	s := newTestServer()
+	setConfig(s, func(c *serverConfig) { c.retrievalTopK = 4 })
+	if got := s.similarChunks("anything", "file:///w/a.go"); got != "" {
+		t.Fatalf("expected no context without an index, got %q", got)
+	}
+}
@@ -2,20 +2,130 @@
 package lsp
 
 import (
+	"path"
 	"strings"
+	"sync"
 	"time"
 )
 
+// document is an immutable snapshot of an open text document. Edits build a
+// new snapshot that shares unchanged line strings with the previous one, so
+// readers never need to hold Server.mu while using a document.
 type document struct {
-	uri   string
-	text  string
-	lines []string
+	uri        string
+	version    int
+	lines      []string
+	languageID string // from didOpen; may be empty
+
+	textOnce sync.Once
+	text     string // joined lines; built on first use
+
+	goOnce   sync.Once
+	goParsed *goParse // Go syntax tree; built on first use for .go files
+}
+
+func newDocument(uri string, version int, lines []string) *document {
+	return &document{uri: uri, version: version, lines: lines}
+}
+
+// Text returns the full document text with '\n' line endings.
+func (d *document) Text() string {
+	d.textOnce.Do(func() { d.text = strings.Join(d.lines, "\n") })
+	return d.text
 }
 
 func (s *Server) setDocument(uri, text string) {
+	s.putDocument(newDocument(uri, 0, splitLines(text)))
+}
+
+func (s *Server) putDocument(d *document) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.docs[uri] = &document{uri: uri, text: text, lines: splitLines(text)}
+	s.docs[d.uri] = d
+}
+
+// applyChange returns a new snapshot with one content change applied. A
+// change without a range replaces the whole text (full sync). Range
+// characters are interpreted in the negotiated position encoding.
+func (d *document) applyChange(c TextDocumentContentChangeEvent, version int, encoding string) *document {
+	if c.Range == nil {
+		return d.next(version, splitLines(c.Text))
+	}
+	start, end := d.clampPosition(c.Range.Start), d.clampPosition(c.Range.End)
+	if lessPos(end, start) {
+		start, end = end, start
+	}
+	sb := byteOffsetInLine(d.lines[start.Line], start.Character, encoding)
+	eb := byteOffsetInLine(d.lines[end.Line], end.Character, encoding)
+	if start.Line == end.Line && eb < sb {
+		eb = sb
+	}
+	mid := splitLines(d.lines[start.Line][:sb] + c.Text + d.lines[end.Line][eb:])
+	lines := make([]string, 0, len(d.lines)-(end.Line-start.Line+1)+len(mid))
+	lines = append(lines, d.lines[:start.Line]...)
+	lines = append(lines, mid...)
+	lines = append(lines, d.lines[end.Line+1:]...)
+	return d.next(version, lines)
+}
+
+// next returns a snapshot with new content that keeps d's identity.
+func (d *document) next(version int, lines []string) *document {
+	nd := newDocument(d.uri, version, lines)
+	nd.languageID = d.languageID
+	return nd
+}
+
+// languageIDsByExt maps file extensions to LSP language ids for documents
+// opened without one.
+var languageIDsByExt = map[string]string{
+	".go": "go", ".py": "python", ".rs": "rust", ".js": "javascript", ".ts": "typescript",
+	".c": "c", ".h": "c", ".cpp": "cpp", ".java": "java", ".rb": "ruby", ".sh": "shellscript",
+	".lua": "lua", ".zig": "zig", ".md": "markdown",
+}
+
+// language returns the LSP language id of the document, falling back to
+// the file extension.
+func (d *document) language() string {
+	if d.languageID != "" {
+		return d.languageID
+	}
+	return languageIDsByExt[path.Ext(d.uri)]
+}
+
+// clampPosition limits the line to the document; characters beyond the end
+// of a line are clamped later by byteOffsetInLine.
+func (d *document) clampPosition(p Position) Position {
+	if p.Line < 0 {
+		return Position{}
+	}
+	if p.Line >= len(d.lines) {
+		last := len(d.lines) - 1
+		return Position{Line: last, Character: len(d.lines[last])}
+	}
+	if p.Character < 0 {
+		p.Character = 0
+	}
+	return p
+}
+
+// byteOffsetInLine converts an LSP character offset into a byte offset in
+// line. With "utf-8" the offset already counts bytes; otherwise it counts
+// UTF-16 code units (the LSP default).
+func byteOffsetInLine(line string, char int, encoding string) int {
+	if encoding == positionEncodingUTF8 {
+		return min(char, len(line))
+	}
+	units := 0
+	for i, r := range line {
+		if units >= char {
+			return i
+		}
+		units++
+		if r >= 0x10000 {
+			units++ // surrogate pair
+		}
+	}
+	return len(line)
 }
 
 func (s *Server) deleteDocument(uri string) {
@@ -42,18 +152,16 @@ func splitLines(sx string) []string {
 	return strings.Split(sx, "\n")
 }
 
+// funcKeywords mark lines that start a function or type declaration across
+// common languages.
+var funcKeywords = []string{"func ", "def ", "class ", "fn ", "procedure ", "sub "}
+
 func (s *Server) lineContext(uri string, pos Position) (above, current, below, funcCtx string) {
 	d := s.getDocument(uri)
 	if d == nil || len(d.lines) == 0 {
 		return "", "", "", ""
 	}
-	idx := pos.Line
-	if idx < 0 {
-		idx = 0
-	}
-	if idx >= len(d.lines) {
-		idx = len(d.lines) - 1
-	}
+	idx := clampLine(d, pos.Line)
 	current = d.lines[idx]
 	if idx-1 >= 0 {
 		above = d.lines[idx-1]
@@ -61,41 +169,46 @@ func (s *Server) lineContext(uri string, pos Position) (above, current, below, f
 	if idx+1 < len(d.lines) {
 		below = d.lines[idx+1]
 	}
-	for i := idx; i >= 0; i-- {
-		line := strings.TrimSpace(d.lines[i])
-		if hasAny(line, []string{"func ", "def ", "class ", "fn ", "procedure ", "sub "}) {
-			funcCtx = line
-			break
-		}
-	}
+	funcCtx = analyzerFor(uri).analyze(d, Position{Line: idx, Character: pos.Character}).function
 	return
 }
 
 // isDefiningNewFunction returns true when the cursor appears to be within
 // a function declaration/signature and before the opening '{' of the body.
-// Heuristic: find nearest preceding line containing "func "; ensure no '{'
-// appears before the cursor across those lines.
 func (s *Server) isDefiningNewFunction(uri string, pos Position) bool {
-	d := s.getDocument(uri)
-	if d == nil || len(d.lines) == 0 {
-		return false
+	return s.codeContext(uri, pos).newFunc
+}
+
+// clampLine limits line to the line indices of d.
+func clampLine(d *document, line int) int {
+	return max(0, min(line, len(d.lines)-1))
+}
+
+// heuristicFunction returns the nearest line at or above idx that starts a
+// function or type declaration in any of the common languages.
+func heuristicFunction(lines []string, idx int) string {
+	for i := idx; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if hasAny(line, funcKeywords) {
+			return line
+		}
 	}
+	return ""
+}
+
+// heuristicNewFunc finds the nearest preceding line containing "func " and
+// reports whether no '{' appears between it and the cursor.
+func heuristicNewFunc(lines []string, pos Position) bool {
 	idx := pos.Line
-	if idx < 0 {
-		idx = 0
-	}
-	if idx >= len(d.lines) {
-		idx = len(d.lines) - 1
-	}
 	// Find signature start
 	sigStart := -1
 	for i := idx; i >= 0; i-- {
-		if strings.Contains(d.lines[i], "func ") {
+		if strings.Contains(lines[i], "func ") {
 			sigStart = i
 			break
 		}
 		// stop if we hit a closing brace which likely ends a previous block
-		if strings.Contains(d.lines[i], "}") {
+		if strings.Contains(lines[i], "}") {
 			break
 		}
 	}
@@ -104,7 +217,7 @@ func (s *Server) isDefiningNewFunction(uri string, pos Position) bool {
 	}
 	// Scan for '{' from sigStart up to cursor position; if found before or at cursor, we're in body
 	for i := sigStart; i <= idx; i++ {
-		line := d.lines[i]
+		line := lines[i]
 		brace := strings.Index(line, "{")
 		if brace >= 0 {
 			if i < idx {
@@ -119,6 +232,101 @@ func (s *Server) isDefiningNewFunction(uri string, pos Position) bool {
 	return true
 }
 
+// declModifiers may precede a declaration keyword (e.g., "pub fn").
+var declModifiers = []string{"pub(crate) ", "pub ", "export ", "async ", "default ", "public ", "private ", "protected ", "static "}
+
+// declKeywords start declarations that can carry a doc comment.
+var declKeywords = []string{"func ", "def ", "class ", "fn ", "type ", "struct ", "enum ", "trait ", "interface ", "impl "}
+
+// isDeclStart reports whether the trimmed line starts a function or type
+// declaration. Unlike the funcKeywords check in lineContext it requires the
+// keyword at the start of the line, so closures like "x := func()" do not count.
+func isDeclStart(trimmed string) bool {
+	return declKeyword(trimmed) != ""
+}
+
+// isFuncDeclStart reports whether the trimmed line starts a function or
+// method declaration.
+func isFuncDeclStart(trimmed string) bool {
+	switch declKeyword(trimmed) {
+	case "func ", "def ", "fn ":
+		return true
+	}
+	return false
+}
+
+// declKeyword returns the declaration keyword the trimmed line starts with,
+// after any modifiers, or "".
+func declKeyword(trimmed string) string {
+	for changed := true; changed; {
+		changed = false
+		for _, m := range declModifiers {
+			if strings.HasPrefix(trimmed, m) {
+				trimmed, changed = strings.TrimPrefix(trimmed, m), true
+			}
+		}
+	}
+	for _, k := range declKeywords {
+		if strings.HasPrefix(trimmed, k) {
+			return k
+		}
+	}
+	return ""
+}
+
+// enclosingDeclaration returns the line index of the declaration enclosing
+// line, or -1. A declaration encloses line unless a line in between is
+// indented no deeper than the declaration (other than closing parentheses of
+// a multi-line signature), which ends its body.
+func enclosingDeclaration(lines []string, line int) int {
+	if line < 0 || line >= len(lines) {
+		return -1
+	}
+	for i := line; i >= 0; i-- {
+		t := strings.TrimSpace(lines[i])
+		if !isDeclStart(t) {
+			continue
+		}
+		indent := len(leadingIndent(lines[i]))
+		for j := i + 1; j < line; j++ {
+			t := strings.TrimSpace(lines[j])
+			if t == "" || strings.HasPrefix(t, ")") {
+				continue
+			}
+			if len(leadingIndent(lines[j])) <= indent {
+				return -1
+			}
+		}
+		return i
+	}
+	return -1
+}
+
+// declarationEnd returns the last line of the declaration starting at start:
+// the line before the next line indented no deeper than it, or the closing
+// brace line itself. The result is capped at maxLines lines.
+func declarationEnd(lines []string, start, maxLines int) int {
+	indent := len(leadingIndent(lines[start]))
+	for j := start + 1; j < len(lines) && j-start < maxLines; j++ {
+		t := strings.TrimSpace(lines[j])
+		if t == "" || strings.HasPrefix(t, ")") || len(leadingIndent(lines[j])) > indent {
+			continue
+		}
+		if strings.HasPrefix(t, "}") {
+			return j
+		}
+		return trimTrailingBlank(lines, start, j-1)
+	}
+	return trimTrailingBlank(lines, start, min(len(lines)-1, start+maxLines-1))
+}
+
+func trimTrailingBlank(lines []string, start, end int) int {
+	for end > start && strings.TrimSpace(lines[end]) == "" {
+		end--
+	}
+	return end
+}
+
 func hasAny(s string, needles []string) bool {
 	for _, n := range needles {
 		if strings.Contains(s, n) {
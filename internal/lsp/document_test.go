@@ -2,6 +2,7 @@
 package lsp
 
 import (
+	"encoding/json"
 	"io"
 	"log"
 	"strings"
@@ -15,6 +16,13 @@ func newTestServer() *Server {
 	}
 }
 
+// setConfig changes the settings of a test server, as a reload would.
+func setConfig(s *Server, set func(c *serverConfig)) {
+	c := *s.config()
+	set(&c)
+	s.cfg.Store(&c)
+}
+
 func TestSplitLines(t *testing.T) {
 	in := "a\r\nb\nc"
 	got := splitLines(in)
@@ -75,3 +83,76 @@ func TestFirstLine(t *testing.T) {
 		t.Fatalf("firstLine got %q want %q", got, "first line")
 	}
 }
+
+func rangeOf(sl, sc, el, ec int) *Range {
+	return &Range{Start: Position{Line: sl, Character: sc}, End: Position{Line: el, Character: ec}}
+}
+
+func TestApplyChange_Incremental(t *testing.T) {
+	base := newDocument("file:///a.go", 1, splitLines("package a\n\nfunc A() {}\n"))
+	cases := []struct {
+		name string
+		c    TextDocumentContentChangeEvent
+		want string
+	}{
+		{"insert", TextDocumentContentChangeEvent{Range: rangeOf(2, 10, 2, 10), Text: " return "}, "package a\n\nfunc A() { return }\n"},
+		{"delete across lines", TextDocumentContentChangeEvent{Range: rangeOf(0, 9, 2, 0), Text: ""}, "package afunc A() {}\n"},
+		{"insert newline", TextDocumentContentChangeEvent{Range: rangeOf(2, 10, 2, 10), Text: "\n\tx()\n"}, "package a\n\nfunc A() {\n\tx()\n}\n"},
+		{"past end clamps", TextDocumentContentChangeEvent{Range: rangeOf(9, 0, 9, 0), Text: "// end"}, "package a\n\nfunc A() {}\n// end"},
+		{"full", TextDocumentContentChangeEvent{Text: "x\ny"}, "x\ny"},
+	}
+	for _, tc := range cases {
+		got := base.applyChange(tc.c, 2, positionEncodingUTF16)
+		if got.Text() != tc.want {
+			t.Fatalf("%s: got %q want %q", tc.name, got.Text(), tc.want)
+		}
+		if got.version != 2 {
+			t.Fatalf("%s: version got %d want 2", tc.name, got.version)
+		}
+	}
+	if base.Text() != "package a\n\nfunc A() {}\n" {
+		t.Fatalf("base snapshot must not change, got %q", base.Text())
+	}
+}
+
+func TestApplyChange_PositionEncodings(t *testing.T) {
+	// "é" is 2 bytes / 1 UTF-16 unit; "😀" is 4 bytes / 2 UTF-16 units.
+	d := newDocument("file:///a.txt", 0, []string{"é😀x"})
+	got := d.applyChange(TextDocumentContentChangeEvent{Range: rangeOf(0, 3, 0, 4), Text: "y"}, 1, positionEncodingUTF16)
+	if got.Text() != "é😀y" {
+		t.Fatalf("utf-16: got %q", got.Text())
+	}
+	got = d.applyChange(TextDocumentContentChangeEvent{Range: rangeOf(0, 6, 0, 7), Text: "y"}, 1, positionEncodingUTF8)
+	if got.Text() != "é😀y" {
+		t.Fatalf("utf-8: got %q", got.Text())
+	}
+}
+
+func TestHandleDidChange_AppliesIncrementalChangesInOrder(t *testing.T) {
+	s := newTestServer()
+	uri := "file:///a.go"
+	open, _ := json.Marshal(DidOpenTextDocumentParams{TextDocument: TextDocumentItem{URI: uri, Version: 1, Text: "ab\ncd"}})
+	s.handleDidOpen(Request{Params: open})
+	change, _ := json.Marshal(DidChangeTextDocumentParams{
+		TextDocument: VersionedTextDocumentIdentifier{URI: uri, Version: 3},
+		ContentChanges: []TextDocumentContentChangeEvent{
+			{Range: rangeOf(0, 2, 0, 2), Text: "X"},
+			{Range: rangeOf(1, 0, 1, 1), Text: ""},
+		},
+	})
+	s.handleDidChange(Request{Params: change})
+	d := s.getDocument(uri)
+	if d.Text() != "abX\nd" || d.version != 3 {
+		t.Fatalf("got text=%q version=%d", d.Text(), d.version)
+	}
+}
+
+func TestNegotiatePositionEncoding(t *testing.T) {
+	if got := negotiatePositionEncoding(ClientCapabilities{}); got != positionEncodingUTF16 {
+		t.Fatalf("default: got %q", got)
+	}
+	c := ClientCapabilities{General: &GeneralClientCapabilities{PositionEncodings: []string{"utf-16", "utf-8"}}}
+	if got := negotiatePositionEncoding(c); got != positionEncodingUTF8 {
+		t.Fatalf("offered utf-8: got %q", got)
+	}
+}
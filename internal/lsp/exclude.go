@@ -0,0 +1,76 @@
+// Summary: Keeps files matched by .hexaiignore, .gitignore or the user ignore file out of every LLM request.
+package lsp
+
+import (
+	"fmt"
+	"strings"
+
+	"hexai/internal/ignore"
+	"hexai/internal/logging"
+)
+
+// loadIgnore (re)reads the ignore rules for the workspace root (may be
+// empty) and the user ignore file.
+func (s *Server) loadIgnore(root string) {
+	var extra []string
+	if s.ignoreFile != "" {
+		extra = append(extra, s.ignoreFile)
+	}
+	m := ignore.Load(root, extra...)
+	s.mu.Lock()
+	s.ignore = m
+	s.mu.Unlock()
+	logging.Logf("lsp ", "ignore rules=%d root=%s", m.Len(), root)
+}
+
+// excludedPath reports whether the file at path must not be sent to a
+// provider and the rule that excludes it.
+func (s *Server) excludedPath(path string) (string, bool) {
+	s.mu.RLock()
+	m := s.ignore
+	s.mu.RUnlock()
+	return m.Match(path)
+}
+
+// excluded reports whether the document uri must not be sent to a
+// provider. Refusals are logged with the matching rule; with
+// ignore_show_message the user is told once per document.
+func (s *Server) excluded(uri string) bool {
+	if uri == "" {
+		return false
+	}
+	reason, ok := s.excludedPath(uriToPath(uri))
+	if !ok {
+		return false
+	}
+	logging.Logf("lsp ", "ignore: refusing to send uri=%s rule=%q", uri, reason)
+	show := s.config().ignoreShowMessage
+	s.mu.Lock()
+	notify := show && !s.ignoreNotified[uri]
+	if notify {
+		if s.ignoreNotified == nil {
+			s.ignoreNotified = make(map[string]bool)
+		}
+		s.ignoreNotified[uri] = true
+	}
+	s.mu.Unlock()
+	if notify {
+		s.showMessage(messageInfo, fmt.Sprintf("Hexai: %s is ignored (%s); nothing from it is sent to the LLM", uri, reason))
+	}
+	return true
+}
+
+// skipExcluded is the skip function for workspace index builds; each
+// excluded file or directory is logged once.
+func (s *Server) skipExcluded(path string) bool {
+	reason, ok := s.excludedPath(path)
+	if ok {
+		logging.Logf("lsp ", "ignore: not indexing %s rule=%q", path, reason)
+	}
+	return ok
+}
+
+// errExcluded is returned by commands acting on an excluded document.
+func errExcluded(uri string) error {
+	return fmt.Errorf("%s is excluded by an ignore file", strings.TrimPrefix(uri, "file://"))
+}
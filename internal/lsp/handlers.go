@@ -5,10 +5,23 @@ package lsp
 import (
 	"encoding/json"
 	"fmt"
+	"runtime/debug"
 	"strings"
+
+	"hexai/internal/logging"
 )
 
+// handle dispatches req. A panicking handler is logged and answered with an
+// internal error instead of taking the server down.
 func (s *Server) handle(req Request) {
+	defer func() {
+		if r := recover(); r != nil {
+			logging.Logf("lsp ", "panic handling %s: %v\n%s", req.Method, r, debug.Stack())
+			if len(req.ID) != 0 {
+				s.reply(req.ID, nil, &RespError{Code: -32603, Message: fmt.Sprintf("internal error handling %s: %v", req.Method, r)})
+			}
+		}
+	}()
 	if h, ok := s.handlers[req.Method]; ok {
 		h(req)
 		return
@@ -188,9 +201,9 @@ func (s *Server) reply(id json.RawMessage, result any, err *RespError) {
 func (s *Server) busyCompletionItem() CompletionItem {
 	prov := ""
 	model := ""
-	if s.llmClient != nil {
-		prov = s.llmClient.Name()
-		model = s.llmClient.DefaultModel()
+	if client := s.config().llmClient; client != nil {
+		prov = client.Name()
+		model = s.currentModel()
 	}
 	label := "Hexai: LLM busy"
 	if prov != "" && model != "" {
@@ -232,14 +245,13 @@ func (s *Server) completionCacheKey(p CompletionParams, above, current, below, f
 		right = current[idx:]
 	}
 	prov := ""
-	model := ""
-	if s.llmClient != nil {
-		prov = s.llmClient.Name()
-		model = s.llmClient.DefaultModel()
+	model := s.currentModelFor(p.TextDocument.URI)
+	if client := s.config().llmClient; client != nil {
+		prov = client.Name()
 	}
 	temp := ""
-	if s.codingTemperature != nil {
-		temp = fmt.Sprintf("%.3f", *s.codingTemperature)
+	if t := s.settingsFor(p.TextDocument.URI).temperature; t != nil {
+		temp = fmt.Sprintf("%.3f", *t)
 	}
 	extra := ""
 	if hasExtra {
@@ -318,6 +330,7 @@ func (s *Server) compCacheTouchLocked(key string) {
 // CompletionContext if provided and also falls back to inspecting the character
 // immediately to the left of the cursor.
 func (s *Server) isTriggerEvent(p CompletionParams, current string) bool {
+	triggerChars := s.settingsFor(p.TextDocument.URI).triggerChars
 	// 1) Inspect LSP completion context if present
 	if p.Context != nil {
 		var ctx struct {
@@ -341,7 +354,7 @@ func (s *Server) isTriggerEvent(p CompletionParams, current string) bool {
 		// TriggerKind 2 is TriggerCharacter per LSP spec
 		if ctx.TriggerKind == 2 {
 			if ctx.TriggerCharacter != "" {
-				for _, c := range s.triggerChars {
+				for _, c := range triggerChars {
 					if c == ctx.TriggerCharacter {
 						return true
 					}
@@ -363,7 +376,7 @@ func (s *Server) isTriggerEvent(p CompletionParams, current string) bool {
 		return false
 	}
 	ch := string(current[idx-1])
-	for _, c := range s.triggerChars {
+	for _, c := range triggerChars {
 		if c == ch {
 			return true
 		}
@@ -376,8 +389,8 @@ func (s *Server) makeCompletionItems(cleaned string, inParams bool, current stri
 	rm := s.collectPromptRemovalEdits(p.TextDocument.URI)
 	label := labelForCompletion(cleaned, filter)
 	detail := "Hexai LLM completion"
-	if s.llmClient != nil {
-		detail = "Hexai " + s.llmClient.Name() + ":" + s.llmClient.DefaultModel()
+	if client := s.config().llmClient; client != nil {
+		detail = "Hexai " + client.Name() + ":" + s.currentModelFor(p.TextDocument.URI)
 	}
 	return []CompletionItem{{
 		Label:               label,
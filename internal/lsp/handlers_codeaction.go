@@ -4,9 +4,8 @@ package lsp
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"hexai/internal/llm"
-	"hexai/internal/logging"
+	"hexai/internal/prompts"
 	"strings"
 	"time"
 )
@@ -19,26 +18,29 @@ func (s *Server) handleCodeAction(req Request) {
 		}
 		return
 	}
+	s.noteCursor(p.TextDocument.URI, p.Range)
 	d := s.getDocument(p.TextDocument.URI)
-	if d == nil || len(d.lines) == 0 || s.llmClient == nil {
+	if d == nil || len(d.lines) == 0 || s.config().llmClient == nil || s.excluded(p.TextDocument.URI) {
 		if len(req.ID) != 0 {
 			s.reply(req.ID, []CodeAction{}, nil)
 		}
 		return
 	}
-	sel := extractRangeText(d, p.Range)
-	if strings.TrimSpace(sel) == "" {
-		if len(req.ID) != 0 {
-			s.reply(req.ID, []CodeAction{}, nil)
+	actions := append(make([]CodeAction, 0, 4), s.buildReviewFixActions(p, d)...)
+	// Selection-based actions need a non-empty selection; the doc comment
+	// action also works on a bare cursor inside a declaration.
+	if sel := extractRangeText(d, p.Range); strings.TrimSpace(sel) != "" {
+		if a := s.buildRewriteCodeAction(p, sel); a != nil {
+			actions = append(actions, *a)
+		}
+		if a := s.buildDiagnosticsCodeAction(p, sel); a != nil {
+			actions = append(actions, *a)
+		}
+		if a := s.buildTestsCodeAction(p, sel); a != nil {
+			actions = append(actions, *a)
 		}
-		return
-	}
-
-	actions := make([]CodeAction, 0, 2)
-	if a := s.buildRewriteCodeAction(p, sel); a != nil {
-		actions = append(actions, *a)
 	}
-	if a := s.buildDiagnosticsCodeAction(p, sel); a != nil {
+	if a := s.buildDocCodeAction(p, d); a != nil {
 		actions = append(actions, *a)
 	}
 	if len(req.ID) != 0 {
@@ -46,18 +48,28 @@ func (s *Server) handleCodeAction(req Request) {
 	}
 }
 
+// codeActionPayload is stored in CodeAction.Data and carries everything
+// needed to resolve the action later.
+type codeActionPayload struct {
+	Type        string       `json:"type"`
+	URI         string       `json:"uri"`
+	Range       Range        `json:"range"`
+	Instruction string       `json:"instruction,omitempty"`
+	Selection   string       `json:"selection"`
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+}
+
+// buildCodeActionWithPayload returns an unresolved code action carrying payload.
+func (s *Server) buildCodeActionWithPayload(title, kind string, payload codeActionPayload) *CodeAction {
+	raw, _ := json.Marshal(payload)
+	return &CodeAction{Title: title, Kind: kind, Data: raw}
+}
+
 func (s *Server) buildRewriteCodeAction(p CodeActionParams, sel string) *CodeAction {
 	if instr, cleaned := instructionFromSelection(sel); strings.TrimSpace(instr) != "" {
-		payload := struct {
-			Type        string `json:"type"`
-			URI         string `json:"uri"`
-			Range       Range  `json:"range"`
-			Instruction string `json:"instruction"`
-			Selection   string `json:"selection"`
-		}{Type: "rewrite", URI: p.TextDocument.URI, Range: p.Range, Instruction: instr, Selection: cleaned}
-		raw, _ := json.Marshal(payload)
-		ca := CodeAction{Title: "Hexai: rewrite selection", Kind: "refactor.rewrite", Data: raw}
-		return &ca
+		return s.buildCodeActionWithPayload("Hexai: rewrite selection", "refactor.rewrite", codeActionPayload{
+			Type: "rewrite", URI: p.TextDocument.URI, Range: p.Range, Instruction: instr, Selection: cleaned,
+		})
 	}
 	return nil
 }
@@ -67,76 +79,95 @@ func (s *Server) buildDiagnosticsCodeAction(p CodeActionParams, sel string) *Cod
 	if len(diags) == 0 {
 		return nil
 	}
-	payload := struct {
-		Type        string       `json:"type"`
-		URI         string       `json:"uri"`
-		Range       Range        `json:"range"`
-		Selection   string       `json:"selection"`
-		Diagnostics []Diagnostic `json:"diagnostics"`
-	}{Type: "diagnostics", URI: p.TextDocument.URI, Range: p.Range, Selection: sel, Diagnostics: diags}
-	raw, _ := json.Marshal(payload)
-	ca := CodeAction{Title: "Hexai: resolve diagnostics", Kind: "quickfix", Data: raw}
-	return &ca
+	return s.buildCodeActionWithPayload("Hexai: resolve diagnostics", "quickfix", codeActionPayload{
+		Type: "diagnostics", URI: p.TextDocument.URI, Range: p.Range, Selection: sel, Diagnostics: diags,
+	})
 }
 
-func (s *Server) resolveCodeAction(ca CodeAction) (CodeAction, bool) {
-	if s.llmClient == nil || len(ca.Data) == 0 {
+// resolveCodeAction computes the edit of a Hexai code action, showing
+// work-done progress while the LLM runs.
+func (s *Server) resolveCodeAction(ctx context.Context, ca CodeAction) (CodeAction, bool) {
+	cfg := s.config()
+	if cfg.llmClient == nil || len(ca.Data) == 0 {
 		return ca, false
 	}
-	var payload struct {
-		Type        string       `json:"type"`
-		URI         string       `json:"uri"`
-		Range       Range        `json:"range"`
-		Instruction string       `json:"instruction,omitempty"`
-		Selection   string       `json:"selection"`
-		Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
-	}
-	if err := json.Unmarshal(ca.Data, &payload); err != nil {
+	var payload codeActionPayload
+	if err := json.Unmarshal(ca.Data, &payload); err != nil || s.excluded(payload.URI) {
 		return ca, false
 	}
+	ctx, end := s.beginProgress(ctx, ca.Title)
+	defer end()
 	switch payload.Type {
 	case "rewrite":
-		sys := "You are a precise code refactoring engine. Rewrite the given code strictly according to the instruction. Return only the updated code with no prose or backticks. Preserve formatting where reasonable."
-		user := fmt.Sprintf("Instruction: %s\n\nSelected code to transform:\n%s", payload.Instruction, payload.Selection)
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		messages := []llm.Message{{Role: "system", Content: sys}, {Role: "user", Content: user}}
-		opts := s.llmRequestOpts()
-		if text, err := s.llmClient.Chat(ctx, messages, opts...); err == nil {
-			if out := stripCodeFences(strings.TrimSpace(text)); out != "" {
-				edit := WorkspaceEdit{Changes: map[string][]TextEdit{payload.URI: {{Range: payload.Range, NewText: out}}}}
-				ca.Edit = &edit
-				return ca, true
-			}
-		} else {
-			logging.Logf("lsp ", "codeAction rewrite llm error: %v", err)
-		}
+		return s.resolveRewriteAction(ctx, cfg, ca, payload)
 	case "diagnostics":
-		sys := "You are a precise code fixer. Resolve the given diagnostics by editing only the selected code. Return only the corrected code with no prose or backticks. Keep behavior and style, and avoid unrelated changes."
-		var b strings.Builder
-		b.WriteString("Diagnostics to resolve (selection only):\n")
-		for i, dgn := range payload.Diagnostics {
-			if dgn.Source != "" {
-				fmt.Fprintf(&b, "%d. [%s] %s\n", i+1, dgn.Source, dgn.Message)
-			} else {
-				fmt.Fprintf(&b, "%d. %s\n", i+1, dgn.Message)
-			}
+		return s.resolveDiagnosticsAction(ctx, cfg, ca, payload)
+	case "document":
+		edit, err := s.resolveDocAction(ctx, payload.URI, payload.Range)
+		if err != nil {
+			s.llmError("codeAction document", err)
+			return ca, false
+		}
+		ca.Edit = edit
+		return ca, true
+	case "tests":
+		edit, err := s.resolveTestsAction(ctx, payload.URI, payload.Selection)
+		if err != nil {
+			s.llmError("codeAction tests", err)
+			return ca, false
 		}
-		b.WriteString("\nSelected code:\n")
-		b.WriteString(payload.Selection)
-		ctx, cancel := context.WithTimeout(context.Background(), 12*time.Second)
-		defer cancel()
-		messages := []llm.Message{{Role: "system", Content: sys}, {Role: "user", Content: b.String()}}
-		opts := s.llmRequestOpts()
-		if text, err := s.llmClient.Chat(ctx, messages, opts...); err == nil {
-			if out := stripCodeFences(strings.TrimSpace(text)); out != "" {
-				edit := WorkspaceEdit{Changes: map[string][]TextEdit{payload.URI: {{Range: payload.Range, NewText: out}}}}
-				ca.Edit = &edit
-				return ca, true
-			}
-		} else {
-			logging.Logf("lsp ", "codeAction diagnostics llm error: %v", err)
+		ca.Edit = edit
+		return ca, true
+	}
+	return ca, false
+}
+
+func (s *Server) resolveRewriteAction(ctx context.Context, cfg *serverConfig, ca CodeAction, payload codeActionPayload) (CodeAction, bool) {
+	data := prompts.Data{
+		File: payload.URI, Language: s.languageFor(payload.URI), Instruction: payload.Instruction,
+		Selection: payload.Selection, Context: s.similarChunks(payload.Selection, payload.URI),
+	}
+	sys := cfg.prompts.Render(prompts.RewriteSystem, data)
+	user := cfg.prompts.Render(prompts.RewriteUser, data)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	messages := append([]llm.Message{{Role: "system", Content: sys}}, s.mentionMessages(payload.URI, payload.Instruction, "")...)
+	messages = append(messages, llm.Message{Role: "user", Content: user})
+	opts := s.llmRequestOpts(payload.URI)
+	if text, err := cfg.llmClient.Chat(ctx, messages, opts...); err == nil {
+		if out := stripCodeFences(strings.TrimSpace(text)); out != "" {
+			edit := WorkspaceEdit{Changes: map[string][]TextEdit{payload.URI: {{Range: payload.Range, NewText: out}}}}
+			ca.Edit = &edit
+			return ca, true
+		}
+	} else {
+		s.llmError("codeAction rewrite", err)
+	}
+	return ca, false
+}
+
+func (s *Server) resolveDiagnosticsAction(ctx context.Context, cfg *serverConfig, ca CodeAction, payload codeActionPayload) (CodeAction, bool) {
+	data := prompts.Data{
+		File: payload.URI, Language: s.languageFor(payload.URI), Selection: payload.Selection,
+		Context: s.similarChunks(payload.Selection, payload.URI),
+	}
+	for _, dgn := range payload.Diagnostics {
+		data.Diagnostics = append(data.Diagnostics, prompts.Diagnostic{Source: dgn.Source, Message: dgn.Message})
+	}
+	sys := cfg.prompts.Render(prompts.DiagnosticsSystem, data)
+	user := cfg.prompts.Render(prompts.DiagnosticsUser, data)
+	ctx, cancel := context.WithTimeout(ctx, 12*time.Second)
+	defer cancel()
+	messages := []llm.Message{{Role: "system", Content: sys}, {Role: "user", Content: user}}
+	opts := s.llmRequestOpts(payload.URI)
+	if text, err := cfg.llmClient.Chat(ctx, messages, opts...); err == nil {
+		if out := stripCodeFences(strings.TrimSpace(text)); out != "" {
+			edit := WorkspaceEdit{Changes: map[string][]TextEdit{payload.URI: {{Range: payload.Range, NewText: out}}}}
+			ca.Edit = &edit
+			return ca, true
 		}
+	} else {
+		s.llmError("codeAction diagnostics", err)
 	}
 	return ca, false
 }
@@ -149,7 +180,7 @@ func (s *Server) handleCodeActionResolve(req Request) {
 		}
 		return
 	}
-	if resolved, ok := s.resolveCodeAction(ca); ok {
+	if resolved, ok := s.resolveCodeAction(context.Background(), ca); ok {
 		s.reply(req.ID, resolved, nil)
 		return
 	}
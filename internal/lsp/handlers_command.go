@@ -0,0 +1,359 @@
+// Summary: workspace/executeCommand handler and the hexai.* commands (explain, tests, document, model switch, stats, cache, reload, chat sessions).
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"hexai/internal/llm"
+	"hexai/internal/logging"
+	"hexai/internal/prompts"
+)
+
+// Command identifiers advertised via executeCommandProvider.
+const (
+	cmdExplain      = "hexai.explain"
+	cmdTests        = "hexai.generateTests"
+	cmdDocument     = "hexai.document"
+	cmdSwitchModel  = "hexai.switchModel"
+	cmdShowStats    = "hexai.showStats"
+	cmdClearCache   = "hexai.clearCache"
+	cmdReloadConfig = "hexai.reloadConfig"
+	cmdNewChat      = "hexai.newChat"
+	cmdOpenChat     = "hexai.openChat"
+)
+
+// LSP MessageType values for window/showMessage.
+const (
+	messageError   = 1
+	messageWarning = 2
+	messageInfo    = 3
+	messageLog     = 4
+)
+
+// commands maps command names to their implementations. Each returns the
+// result sent back to the client; user-facing output goes via showMessage.
+func (s *Server) commands() map[string]func(args []json.RawMessage) (any, error) {
+	return map[string]func(args []json.RawMessage) (any, error){
+		cmdExplain:      s.cmdExplain,
+		cmdTests:        s.cmdTests,
+		cmdDocument:     s.cmdDocument,
+		cmdSwitchModel:  s.cmdSwitchModel,
+		cmdShowStats:    s.cmdShowStats,
+		cmdClearCache:   s.cmdClearCache,
+		cmdReloadConfig: s.cmdReloadConfig,
+		cmdNewChat:      s.cmdNewChat,
+		cmdOpenChat:     s.cmdOpenChat,
+	}
+}
+
+// commandNames returns the advertised command names in sorted order.
+func (s *Server) commandNames() []string {
+	names := make([]string, 0, len(s.commands()))
+	for name := range s.commands() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *Server) handleExecuteCommand(req Request) {
+	var p ExecuteCommandParams
+	if err := json.Unmarshal(req.Params, &p); err != nil {
+		s.reply(req.ID, nil, &RespError{Code: -32602, Message: "invalid executeCommand params"})
+		return
+	}
+	cmd, ok := s.commands()[p.Command]
+	if !ok {
+		s.reply(req.ID, nil, &RespError{Code: -32602, Message: "unknown command: " + p.Command})
+		return
+	}
+	logging.Logf("lsp ", "executeCommand %s args=%d", p.Command, len(p.Arguments))
+	res, err := cmd(p.Arguments)
+	if err != nil {
+		logging.Logf("lsp ", "command %s failed: %v", p.Command, err)
+		s.showMessage(messageError, "Hexai: "+err.Error())
+		s.reply(req.ID, nil, &RespError{Code: -32603, Message: err.Error()})
+		return
+	}
+	s.reply(req.ID, res, nil)
+}
+
+// showMessage sends a window/showMessage notification to the client.
+func (s *Server) showMessage(typ int, msg string) {
+	b, _ := json.Marshal(ShowMessageParams{Type: typ, Message: msg})
+	s.writeMessage(Request{JSONRPC: "2.0", Method: "window/showMessage", Params: b})
+}
+
+// noteCursor remembers the most recent cursor position or selection so that
+// commands invoked without arguments (e.g., from a Helix key binding) know
+// what to act on. Non-empty ranges are also kept as the target of @selection.
+func (s *Server) noteCursor(uri string, r Range) {
+	s.mu.Lock()
+	s.lastCursorURI, s.lastCursorRange = uri, r
+	if r.Start != r.End {
+		s.lastSelectionURI, s.lastSelection = uri, r
+	}
+	s.mu.Unlock()
+}
+
+// errLLMDisabled is returned by commands when no LLM client is configured.
+var errLLMDisabled = errors.New("LLM is disabled")
+
+// cmdExplain explains code and shows the answer via window/showMessage.
+// Arguments: [uri, range] or [uri, position]; without arguments the last
+// known cursor position or selection is used. An empty range explains the
+// identifier or expression under the cursor.
+func (s *Server) cmdExplain(args []json.RawMessage) (any, error) {
+	cfg := s.config()
+	if cfg.llmClient == nil {
+		return nil, errLLMDisabled
+	}
+	uri, r, err := s.locationArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	d := s.getDocument(uri)
+	if d == nil || r.Start.Line >= len(d.lines) {
+		return nil, fmt.Errorf("explain: document not open: %s", uri)
+	}
+	code := extractRangeText(d, r)
+	if strings.TrimSpace(code) == "" {
+		code, _ = expressionAt(d.lines[r.Start.Line], r.Start)
+	}
+	if strings.TrimSpace(code) == "" {
+		return nil, fmt.Errorf("explain: nothing under the cursor")
+	}
+	data := prompts.Data{File: uri, Language: d.language(), Selection: code, Function: functionSnippet(d.lines, r.Start.Line)}
+	msgs := []llm.Message{
+		{Role: "system", Content: cfg.prompts.Render(prompts.ExplainSystem, data)},
+		{Role: "user", Content: cfg.prompts.Render(prompts.ExplainUser, data)},
+	}
+	ctx, end := s.beginProgress(context.Background(), "Hexai: explain")
+	defer end()
+	text, err := s.commandChat(ctx, uri, msgs)
+	if err != nil {
+		return nil, fmt.Errorf("explain: %v", err)
+	}
+	s.showMessage(messageInfo, text)
+	return text, nil
+}
+
+// cmdTests generates unit tests for code and applies them to the test file
+// via workspace/applyEdit. Arguments as for hexai.explain; an empty range
+// uses the enclosing function.
+func (s *Server) cmdTests(args []json.RawMessage) (any, error) {
+	if s.config().llmClient == nil {
+		return nil, errLLMDisabled
+	}
+	uri, r, err := s.locationArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	d := s.getDocument(uri)
+	if d == nil || r.Start.Line >= len(d.lines) {
+		return nil, fmt.Errorf("tests: document not open: %s", uri)
+	}
+	code := extractRangeText(d, r)
+	if strings.TrimSpace(code) == "" {
+		decl := enclosingDeclaration(d.lines, r.Start.Line)
+		if decl < 0 {
+			return nil, fmt.Errorf("tests: no function at the cursor")
+		}
+		code = strings.Join(d.lines[decl:declarationEnd(d.lines, decl, docDeclMaxLines)+1], "\n")
+	}
+	ctx, end := s.beginProgress(context.Background(), "Hexai: generate unit tests")
+	defer end()
+	edit, err := s.resolveTestsAction(ctx, uri, code)
+	if err != nil {
+		return nil, fmt.Errorf("tests: %v", err)
+	}
+	s.clientApplyEdit("Hexai: generate unit tests", *edit)
+	return nil, nil
+}
+
+// cmdDocument adds or updates the doc comment of the declaration enclosing
+// the given range via workspace/applyEdit. Arguments as for hexai.explain.
+func (s *Server) cmdDocument(args []json.RawMessage) (any, error) {
+	if s.config().llmClient == nil {
+		return nil, errLLMDisabled
+	}
+	uri, r, err := s.locationArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	ctx, end := s.beginProgress(context.Background(), "Hexai: documentation comment")
+	defer end()
+	edit, err := s.resolveDocAction(ctx, uri, r)
+	if err != nil {
+		return nil, fmt.Errorf("document: %v", err)
+	}
+	s.clientApplyEdit("Hexai: documentation comment", *edit)
+	return nil, nil
+}
+
+// commandChat sends msgs about the document uri to the primary client with
+// request stats.
+func (s *Server) commandChat(ctx context.Context, uri string, msgs []llm.Message) (string, error) {
+	client := s.config().llmClient
+	if client == nil {
+		return "", errLLMDisabled
+	}
+	sent := 0
+	for _, m := range msgs {
+		sent += len(m.Content)
+	}
+	s.incSentCounters(sent)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	logging.Logf("lsp ", "command llm=requesting model=%s", s.currentModelFor(uri))
+	text, err := client.Chat(ctx, msgs, s.llmRequestOpts(uri)...)
+	if err != nil {
+		return "", err
+	}
+	s.incRecvCounters(len(text))
+	s.logLLMStats()
+	return strings.TrimSpace(stripCodeFences(text)), nil
+}
+
+// locationArgs decodes [uri, range|position] or falls back to the last
+// cursor position seen by the server.
+func (s *Server) locationArgs(args []json.RawMessage) (string, Range, error) {
+	if len(args) == 0 {
+		s.mu.RLock()
+		uri, r := s.lastCursorURI, s.lastCursorRange
+		s.mu.RUnlock()
+		if uri == "" {
+			return "", Range{}, fmt.Errorf("no cursor position known yet; pass [uri, range]")
+		}
+		if s.excluded(uri) {
+			return "", Range{}, errExcluded(uri)
+		}
+		return uri, s.clampRange(uri, r), nil
+	}
+	var uri string
+	if err := json.Unmarshal(args[0], &uri); err != nil || uri == "" {
+		return "", Range{}, fmt.Errorf("first argument must be a document URI")
+	}
+	if s.excluded(uri) {
+		return "", Range{}, errExcluded(uri)
+	}
+	if len(args) < 2 {
+		return "", Range{}, fmt.Errorf("missing range or position argument")
+	}
+	var loc struct {
+		Range
+		Line      *int `json:"line"`
+		Character int  `json:"character"`
+	}
+	if err := json.Unmarshal(args[1], &loc); err != nil {
+		return "", Range{}, fmt.Errorf("second argument must be a range or position: %v", err)
+	}
+	if loc.Line != nil {
+		pos := Position{Line: *loc.Line, Character: loc.Character}
+		return uri, s.clampRange(uri, Range{Start: pos, End: pos}), nil
+	}
+	return uri, s.clampRange(uri, loc.Range), nil
+}
+
+// clampRange limits r to the document uri when it is open.
+func (s *Server) clampRange(uri string, r Range) Range {
+	if d := s.getDocument(uri); d != nil && len(d.lines) > 0 {
+		r.Start, r.End = d.clampPosition(r.Start), d.clampPosition(r.End)
+	}
+	return r
+}
+
+// cmdSwitchModel switches the model of the primary provider for all
+// requests. Arguments: [model]; an empty model or "default" restores the
+// configured model, and no argument shows the current one.
+func (s *Server) cmdSwitchModel(args []json.RawMessage) (any, error) {
+	client := s.config().llmClient
+	if client == nil {
+		return nil, errLLMDisabled
+	}
+	if len(args) == 0 {
+		s.showMessage(messageInfo, fmt.Sprintf("Hexai: model %s:%s", client.Name(), s.currentModel()))
+		return s.currentModel(), nil
+	}
+	var model string
+	if err := json.Unmarshal(args[0], &model); err != nil {
+		return nil, fmt.Errorf("switchModel: argument must be a model name")
+	}
+	model = strings.TrimSpace(model)
+	if model == "default" {
+		model = ""
+	}
+	s.mu.Lock()
+	s.modelOverride = model
+	s.mu.Unlock()
+	s.clearCaches() // cached answers belong to the previous model
+	logging.Logf("lsp ", "model switched to %s", s.currentModel())
+	s.showMessage(messageInfo, fmt.Sprintf("Hexai: switched to %s:%s", client.Name(), s.currentModel()))
+	return s.currentModel(), nil
+}
+
+// cmdShowStats shows LLM traffic counters. No arguments.
+func (s *Server) cmdShowStats(_ []json.RawMessage) (any, error) {
+	st := s.llmStats()
+	model := "disabled"
+	if client := s.config().llmClient; client != nil {
+		model = client.Name() + ":" + s.currentModel()
+	}
+	msg := fmt.Sprintf("Hexai %s: %d requests, avg sent %d B, avg received %d B, %.2f req/min, uptime %s",
+		model, st.reqs, st.avgSent, st.avgRecv, st.rpm, time.Since(s.startTime).Round(time.Second))
+	if st.hedged {
+		msg += fmt.Sprintf("; hedge fired %d (primary %d, secondary %d)", st.hedgeFired, st.primaryWins, st.secondaryWins)
+	}
+	s.showMessage(messageInfo, msg)
+	return msg, nil
+}
+
+// cmdClearCache drops cached completions and hover explanations. No arguments.
+func (s *Server) cmdClearCache(_ []json.RawMessage) (any, error) {
+	n := s.clearCaches()
+	s.showMessage(messageInfo, fmt.Sprintf("Hexai: cleared %d cached entries", n))
+	return n, nil
+}
+
+// clearCaches empties the completion, hover, review and chat summary caches
+// and returns the number of dropped entries.
+func (s *Server) clearCaches() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.compCache) + len(s.hoverCache) + len(s.reviewCache) + len(s.chatSummaries)
+	s.compCache = make(map[string]string)
+	s.compCacheOrder = nil
+	s.hoverCache = make(map[string]string)
+	s.hoverCacheOrder = nil
+	s.reviewCache = nil
+	s.reviewCacheOrder = nil
+	s.chatSummaries = nil
+	s.chatSummaryOrder = nil
+	return n
+}
+
+// cmdReloadConfig re-reads the configuration and rebuilds the LLM client.
+// No arguments. The model override and caches are reset.
+func (s *Server) cmdReloadConfig(_ []json.RawMessage) (any, error) {
+	if s.reloadConfig == nil {
+		return nil, fmt.Errorf("reloadConfig is not supported by this server")
+	}
+	if err := s.reload("command"); err != nil {
+		return nil, fmt.Errorf("reload config: %v (keeping current settings)", err)
+	}
+	s.mu.Lock()
+	s.modelOverride = ""
+	s.mu.Unlock()
+	msg := "Hexai: configuration reloaded"
+	if client := s.config().llmClient; client != nil {
+		msg += fmt.Sprintf(" (%s:%s)", client.Name(), s.currentModel())
+	}
+	s.showMessage(messageInfo, msg)
+	return nil, nil
+}
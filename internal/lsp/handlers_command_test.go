@@ -0,0 +1,204 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"hexai/internal/llm"
+)
+
+// modelRecordingLLM records the model requested via options.
+type modelRecordingLLM struct{ models []string }
+
+func (f *modelRecordingLLM) Chat(_ context.Context, _ []llm.Message, opts ...llm.RequestOption) (string, error) {
+	o := llm.Options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	f.models = append(f.models, o.Model)
+	return "It adds numbers.", nil
+}
+func (f *modelRecordingLLM) Name() string         { return "fake" }
+func (f *modelRecordingLLM) DefaultModel() string { return "m" }
+
+// readAllMessages decodes every framed message written to buf.
+func readAllMessages(t *testing.T, buf *bytes.Buffer) []map[string]json.RawMessage {
+	t.Helper()
+	r := &Server{in: bufio.NewReader(buf)}
+	var out []map[string]json.RawMessage
+	for {
+		body, err := r.readMessage()
+		if err != nil {
+			return out
+		}
+		var m map[string]json.RawMessage
+		if err := json.Unmarshal(body, &m); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		out = append(out, m)
+	}
+}
+
+func shownMessages(msgs []map[string]json.RawMessage) []string {
+	var out []string
+	for _, m := range msgs {
+		if string(m["method"]) != `"window/showMessage"` {
+			continue
+		}
+		var p ShowMessageParams
+		_ = json.Unmarshal(m["params"], &p)
+		out = append(out, p.Message)
+	}
+	return out
+}
+
+func commandRequest(cmd string, args ...any) Request {
+	p := map[string]any{"command": cmd}
+	if len(args) > 0 {
+		p["arguments"] = args
+	}
+	b, _ := json.Marshal(p)
+	return Request{ID: json.RawMessage(`7`), Params: b}
+}
+
+func TestExecuteCommand_UnknownCommandIsError(t *testing.T) {
+	var buf bytes.Buffer
+	s := newTestServer()
+	s.out = &buf
+	s.handleExecuteCommand(commandRequest("hexai.nope"))
+	msgs := readAllMessages(t, &buf)
+	if len(msgs) != 1 || msgs[0]["error"] == nil {
+		t.Fatalf("expected an error response, got %v", msgs)
+	}
+}
+
+func TestExecuteCommand_ExplainUsesArgumentsOrLastCursor(t *testing.T) {
+	var buf bytes.Buffer
+	s := newTestServer()
+	s.out = &buf
+	setConfig(s, func(c *serverConfig) { c.llmClient = &modelRecordingLLM{} })
+	s.setDocument("file:///a.go", "func add(a, b int) int {\n\treturn a + b\n}")
+	s.handleExecuteCommand(commandRequest(cmdExplain, "file:///a.go", Range{Start: Position{Line: 1, Character: 1}, End: Position{Line: 1, Character: 13}}))
+	if got := shownMessages(readAllMessages(t, &buf)); len(got) != 1 || got[0] != "It adds numbers." {
+		t.Fatalf("unexpected messages: %v", got)
+	}
+	s.handleExecuteCommand(commandRequest(cmdExplain))
+	if msgs := readAllMessages(t, &buf); msgs[len(msgs)-1]["error"] == nil {
+		t.Fatalf("expected error without arguments and unknown cursor")
+	}
+	s.noteCursor("file:///a.go", Range{Start: Position{Line: 0, Character: 6}, End: Position{Line: 0, Character: 6}})
+	s.handleExecuteCommand(commandRequest(cmdExplain))
+	if got := shownMessages(readAllMessages(t, &buf)); len(got) != 1 {
+		t.Fatalf("expected explanation from last cursor, got %v", got)
+	}
+}
+
+func TestExecuteCommand_ExplainClampsStaleRange(t *testing.T) {
+	var buf bytes.Buffer
+	s := newTestServer()
+	s.out = &buf
+	setConfig(s, func(c *serverConfig) { c.llmClient = &modelRecordingLLM{} })
+	s.setDocument("file:///a.go", "func add(a, b int) int {\n\treturn a + b\n}")
+	// a resolved code lens may still carry the range of a longer function
+	s.handleExecuteCommand(commandRequest(cmdExplain, "file:///a.go", Range{Start: Position{Line: 1}, End: Position{Line: 10, Character: 4}}))
+	if got := shownMessages(readAllMessages(t, &buf)); len(got) != 1 || got[0] != "It adds numbers." {
+		t.Fatalf("unexpected messages: %v", got)
+	}
+	if got := extractRangeText(s.getDocument("file:///a.go"), Range{Start: Position{Line: 1}, End: Position{Line: 10}}); got != "\treturn a + b\n}" {
+		t.Fatalf("unexpected clamped text %q", got)
+	}
+}
+
+func TestHandle_RecoversFromPanic(t *testing.T) {
+	var buf bytes.Buffer
+	s := newTestServer()
+	s.out = &buf
+	s.handlers = map[string]func(Request){"boom": func(Request) { panic("stale range") }}
+	s.handle(Request{ID: json.RawMessage(`3`), Method: "boom"})
+	msgs := readAllMessages(t, &buf)
+	if len(msgs) != 1 || msgs[0]["error"] == nil {
+		t.Fatalf("expected an error response, got %v", msgs)
+	}
+}
+
+func TestExecuteCommand_SwitchModelOverridesPrimaryRequests(t *testing.T) {
+	var buf bytes.Buffer
+	s := newTestServer()
+	s.out = &buf
+	fake := &modelRecordingLLM{}
+	setConfig(s, func(c *serverConfig) { c.llmClient = fake })
+	s.compCache = map[string]string{"k": "v"}
+	s.handleExecuteCommand(commandRequest(cmdSwitchModel, "big-model"))
+	if s.currentModel() != "big-model" || len(s.compCache) != 0 {
+		t.Fatalf("expected override and cleared cache; model=%s cache=%d", s.currentModel(), len(s.compCache))
+	}
+	if _, err := s.completionChat(context.Background(), "", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	s.handleExecuteCommand(commandRequest(cmdSwitchModel, "default"))
+	if _, err := s.completionChat(context.Background(), "", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.models) != 2 || fake.models[0] != "big-model" || fake.models[1] != "" {
+		t.Fatalf("unexpected requested models: %v", fake.models)
+	}
+}
+
+func TestExecuteCommand_ShowStatsAndClearCache(t *testing.T) {
+	var buf bytes.Buffer
+	s := newTestServer()
+	s.out = &buf
+	setConfig(s, func(c *serverConfig) { c.llmClient = &countingLLM{} })
+	s.incSentCounters(10)
+	s.hoverCachePut("h", "x")
+	s.handleExecuteCommand(commandRequest(cmdShowStats))
+	s.handleExecuteCommand(commandRequest(cmdClearCache))
+	got := shownMessages(readAllMessages(t, &buf))
+	if len(got) != 2 || !strings.Contains(got[0], "1 requests") || got[1] != "Hexai: cleared 1 cached entries" {
+		t.Fatalf("unexpected messages: %v", got)
+	}
+}
+
+func TestInitialize_ReloadsConfigForWorkspaceFolder(t *testing.T) {
+	var buf bytes.Buffer
+	s := newTestServer()
+	s.out = &buf
+	root := t.TempDir()
+	var gotRoot string
+	s.reloadConfig = func(r string, _ json.RawMessage) (ServerOptions, error) {
+		gotRoot = r
+		return ServerOptions{MaxTokens: 55, TriggerCharacters: []string{"#"}, Notice: "untrusted"}, nil
+	}
+	params, _ := json.Marshal(InitializeParams{WorkspaceFolders: []WorkspaceFolder{{URI: "file://" + root, Name: "w"}}})
+	s.handleInitialize(Request{ID: json.RawMessage(`1`), Params: params})
+	if gotRoot != root || s.config().maxTokens != 55 || s.root != root {
+		t.Fatalf("project config not applied: root=%q maxTokens=%d", gotRoot, s.config().maxTokens)
+	}
+	msgs := readAllMessages(t, &buf)
+	if got := shownMessages(msgs); len(got) != 1 || got[0] != "untrusted" {
+		t.Fatalf("expected the notice to be shown, got %v", got)
+	}
+	var res InitializeResult
+	b, _ := json.Marshal(msgs[len(msgs)-1]["result"])
+	if err := json.Unmarshal(b, &res); err != nil || len(res.Capabilities.CompletionProvider.TriggerCharacters) != 1 {
+		t.Fatalf("expected project trigger characters to be announced, got %s", b)
+	}
+}
+
+func TestExecuteCommand_ReloadConfigAppliesOptions(t *testing.T) {
+	var buf bytes.Buffer
+	s := newTestServer()
+	s.out = &buf
+	s.modelOverride = "x"
+	s.reloadConfig = func(string, json.RawMessage) (ServerOptions, error) {
+		return ServerOptions{MaxTokens: 77, Client: &countingLLM{}}, nil
+	}
+	s.handleExecuteCommand(commandRequest(cmdReloadConfig))
+	if s.config().maxTokens != 77 || s.config().llmClient == nil || s.modelOverride != "" {
+		t.Fatalf("reload not applied: maxTokens=%d client=%v override=%q", s.config().maxTokens, s.config().llmClient, s.modelOverride)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"hexai/internal/llm"
 	"hexai/internal/logging"
+	"hexai/internal/prompts"
 	"strings"
 	"time"
 )
@@ -15,6 +16,7 @@ func (s *Server) handleCompletion(req Request) {
 	var p CompletionParams
 	var docStr string
 	if err := json.Unmarshal(req.Params, &p); err == nil {
+		s.noteCursor(p.TextDocument.URI, Range{Start: p.Position, End: p.Position})
 		// Log trigger information for every completion request from client
 		tk, tch := extractTriggerInfo(p)
 		logging.Logf("lsp ", "completion trigger kind=%d char=%q uri=%s line=%d char=%d",
@@ -24,7 +26,13 @@ func (s *Server) handleCompletion(req Request) {
 		if s.logContext {
 			s.logCompletionContext(p, above, current, below, funcCtx)
 		}
-		if s.llmClient != nil {
+		enabled := s.config().llmClient != nil
+		if enabled && s.usesInlineCompletion() {
+			// LLM suggestions go through textDocument/inlineCompletion instead.
+			s.reply(req.ID, CompletionList{IsIncomplete: false, Items: []CompletionItem{}}, nil)
+			return
+		}
+		if enabled && !s.excluded(p.TextDocument.URI) {
 			newFunc := s.isDefiningNewFunction(p.TextDocument.URI, p.Position)
 			extra, has := s.buildAdditionalContext(newFunc, p.TextDocument.URI, p.Position)
 			items, ok := s.tryLLMCompletion(p, above, current, below, funcCtx, docStr, has, extra)
@@ -69,18 +77,41 @@ func (s *Server) logCompletionContext(p CompletionParams, above, current, below,
 		p.TextDocument.URI, p.Position.Line, p.Position.Character, trimLen(above), trimLen(current), trimLen(below), trimLen(funcCtx))
 }
 
+// completionResult is the outcome of the LLM completion pipeline before it
+// is shaped into classic completion items or inline completion items.
+type completionResult struct {
+	text     string // cleaned completion text; empty when there is nothing to suggest
+	inParams bool   // cursor is inside a function parameter list
+	busy     bool   // another LLM request is in flight
+}
+
 func (s *Server) tryLLMCompletion(p CompletionParams, above, current, below, funcCtx, docStr string, hasExtra bool, extraText string) ([]CompletionItem, bool) {
+	res, ok := s.llmCompletion(p, above, current, below, funcCtx, hasExtra, extraText)
+	switch {
+	case !ok:
+		return nil, false
+	case res.busy:
+		return []CompletionItem{s.busyCompletionItem()}, true
+	case res.text == "":
+		return []CompletionItem{}, true
+	}
+	return s.makeCompletionItems(res.text, res.inParams, current, p, docStr), true
+}
+
+// llmCompletion runs the shared completion pipeline: trigger and prefix
+// gating, the cache, the provider-native path and the chat path. It returns
+// ok=false when the LLM failed and the caller should fall back.
+func (s *Server) llmCompletion(p CompletionParams, above, current, below, funcCtx string, hasExtra bool, extraText string) (completionResult, bool) {
 	ctx, cancel := context.WithTimeout(context.Background(), 6*time.Second)
 	defer cancel()
-	locked := false // track if we've taken the LLM busy lock
 
 	inlinePrompt := lineHasInlinePrompt(current)
 	if !inlinePrompt && !s.isTriggerEvent(p, current) {
 		logging.Logf("lsp ", "%scompletion skip=no-trigger line=%d char=%d current=%q%s", logging.AnsiYellow, p.Position.Line, p.Position.Character, trimLen(current), logging.AnsiBase)
-		return []CompletionItem{}, true
+		return completionResult{}, true
 	}
 	if s.shouldSuppressForChatTriggerEOL(current, p) {
-		return []CompletionItem{}, true
+		return completionResult{}, true
 	}
 
 	inParams := inParamList(current, p.Position.Character)
@@ -92,21 +123,21 @@ func (s *Server) tryLLMCompletion(p CompletionParams, above, current, below, fun
 		logging.Logf("lsp ", "completion cache hit uri=%s line=%d char=%d preview=%s%s%s",
 			p.TextDocument.URI, p.Position.Line, p.Position.Character,
 			logging.AnsiGreen, logging.PreviewForLog(cleaned), logging.AnsiBase)
-		return s.makeCompletionItems(cleaned, inParams, current, p, docStr), true
+		return completionResult{text: cleaned, inParams: inParams}, true
 	}
 	if (isBareDoubleSemicolon(current) || isBareDoubleSemicolon(below)) && !manualInvoke {
 		logging.Logf("lsp ", "%scompletion skip=empty-double-semicolon line=%d char=%d current=%q%s", logging.AnsiYellow, p.Position.Line, p.Position.Character, trimLen(current), logging.AnsiBase)
-		return []CompletionItem{}, true
+		return completionResult{}, true
 	}
 
 	if !inParams && !s.prefixHeuristicAllows(inlinePrompt, current, p, manualInvoke) {
 		logging.Logf("lsp ", "%scompletion skip=short-prefix line=%d char=%d current=%q%s", logging.AnsiYellow, p.Position.Line, p.Position.Character, trimLen(current), logging.AnsiBase)
-		return []CompletionItem{}, true
+		return completionResult{}, true
 	}
 
 	// Provider-native path
-	if items, ok := s.tryProviderNativeCompletion(current, p, above, below, funcCtx, docStr, hasExtra, extraText, inParams); ok {
-		return items, true
+	if res, ok := s.tryProviderNativeCompletion(current, p, key, inParams); ok {
+		return res, true
 	}
 
 	// Chat path
@@ -117,36 +148,31 @@ func (s *Server) tryLLMCompletion(p CompletionParams, above, current, below, fun
 		sentSize += len(m.Content)
 	}
 	s.incSentCounters(sentSize)
-	opts := []llm.RequestOption{llm.WithMaxTokens(s.maxTokens)}
-	if s.codingTemperature != nil {
-		opts = append(opts, llm.WithTemperature(*s.codingTemperature))
-	}
-	logging.Logf("lsp ", "completion llm=requesting model=%s", s.llmClient.DefaultModel())
+	opts := s.settingsFor(p.TextDocument.URI).requestOpts()
+	logging.Logf("lsp ", "completion llm=requesting model=%s", s.currentModelFor(p.TextDocument.URI))
 
 	// Concurrency guard for chat path as well
-	if !locked {
-		if s.isLLMBusy() {
-			return []CompletionItem{s.busyCompletionItem()}, true
-		}
-		s.setLLMBusy(true)
-		defer s.setLLMBusy(false)
+	if s.isLLMBusy() {
+		return completionResult{busy: true}, true
 	}
+	s.setLLMBusy(true)
+	defer s.setLLMBusy(false)
 
-	text, err := s.llmClient.Chat(ctx, messages, opts...)
+	text, err := s.completionChat(ctx, p.TextDocument.URI, messages, opts)
 	if err != nil {
-		logging.Logf("lsp ", "llm completion error: %v", err)
+		s.llmError("completion", err)
 		s.logLLMStats()
-		return nil, false
+		return completionResult{}, false
 	}
 	s.incRecvCounters(len(text))
 	s.logLLMStats()
 
 	cleaned := s.postProcessCompletion(strings.TrimSpace(text), current[:p.Position.Character], current)
 	if cleaned == "" {
-		return nil, false
+		return completionResult{}, false
 	}
 	s.completionCachePut(key, cleaned)
-	return s.makeCompletionItems(cleaned, inParams, current, p, docStr), true
+	return completionResult{text: cleaned, inParams: inParams}, true
 }
 
 // parseManualInvoke inspects the LSP completion context and reports whether the user manually invoked completion.
@@ -208,77 +234,91 @@ func (s *Server) prefixHeuristicAllows(inlinePrompt bool, current string, p Comp
 	}
 	start := computeWordStart(current, j)
 	min := 1
-	if manualInvoke && s.manualInvokeMinPrefix >= 0 {
-		min = s.manualInvokeMinPrefix
+	if prefix := s.config().manualInvokeMinPrefix; manualInvoke && prefix >= 0 {
+		min = prefix
 	}
 	return j-start >= min
 }
 
-// tryProviderNativeCompletion attempts provider-native completion and returns items when successful.
-func (s *Server) tryProviderNativeCompletion(current string, p CompletionParams, above, below, funcCtx, docStr string, hasExtra bool, extraText string, inParams bool) ([]CompletionItem, bool) {
-	cc, ok := s.llmClient.(llm.CodeCompleter)
+// tryProviderNativeCompletion attempts provider-native completion and stores
+// successful results in the completion cache under key.
+func (s *Server) tryProviderNativeCompletion(current string, p CompletionParams, key string, inParams bool) (completionResult, bool) {
+	client := s.config().llmClient
+	cc, ok := client.(llm.CodeCompleter)
 	if !ok {
-		return nil, false
+		return completionResult{}, false
 	}
 	before, after := s.docBeforeAfter(p.TextDocument.URI, p.Position)
 	path := strings.TrimPrefix(p.TextDocument.URI, "file://")
 	prompt := "// Path: " + path + "\n" + before
 	lang := ""
 	temp := 0.0
-	if s.codingTemperature != nil {
-		temp = *s.codingTemperature
+	if t := s.settingsFor(p.TextDocument.URI).temperature; t != nil {
+		temp = *t
 	}
-	prov := ""
-	if s.llmClient != nil {
-		prov = s.llmClient.Name()
-	}
-	logging.Logf("lsp ", "completion path=codex provider=%s uri=%s", prov, path)
+	logging.Logf("lsp ", "completion path=codex provider=%s uri=%s", client.Name(), path)
 	ctx2, cancel2 := context.WithTimeout(context.Background(), 8*time.Second)
 	defer cancel2()
 	if s.isLLMBusy() {
-		return []CompletionItem{s.busyCompletionItem()}, true
+		return completionResult{busy: true}, true
 	}
 	s.setLLMBusy(true)
 	defer s.setLLMBusy(false)
 
-	suggestions, err := cc.CodeCompletion(ctx2, prompt, after, 1, lang, temp)
-	if err == nil && len(suggestions) > 0 {
-		cleaned := strings.TrimSpace(suggestions[0])
-		if cleaned != "" {
-			cleaned = stripDuplicateAssignmentPrefix(current[:p.Position.Character], cleaned)
-			if cleaned != "" {
-				cleaned = stripDuplicateGeneralPrefix(current[:p.Position.Character], cleaned)
-			}
-			if cleaned != "" && hasDoubleSemicolonTrigger(current) {
-				indent := leadingIndent(current)
-				if indent != "" {
-					cleaned = applyIndent(indent, cleaned)
-				}
-			}
-			if strings.TrimSpace(cleaned) != "" {
-				key := s.completionCacheKey(p, above, current, below, funcCtx, inParams, hasExtra, extraText)
-				s.completionCachePut(key, cleaned)
-				return s.makeCompletionItems(cleaned, inParams, current, p, docStr), true
-			}
-		}
-	} else if err != nil {
+	suggestions, err := s.completionCode(ctx2, cc, prompt, after, lang, temp)
+	if err != nil {
 		logging.Logf("lsp ", "completion path=codex error=%v (falling back to chat)", err)
+		return completionResult{}, false
+	}
+	if len(suggestions) == 0 {
+		return completionResult{}, false
+	}
+	cleaned := s.cleanNativeSuggestion(suggestions[0], current, p.Position.Character)
+	if strings.TrimSpace(cleaned) == "" {
+		return completionResult{}, false
 	}
-	return nil, false
+	s.completionCachePut(key, cleaned)
+	return completionResult{text: cleaned, inParams: inParams}, true
+}
+
+// cleanNativeSuggestion removes prefixes the model repeated from the line and
+// applies ";;" indentation rules to a provider-native suggestion.
+func (s *Server) cleanNativeSuggestion(suggestion, current string, cursor int) string {
+	cleaned := strings.TrimSpace(suggestion)
+	if cleaned != "" {
+		cleaned = stripDuplicateAssignmentPrefix(current[:cursor], cleaned)
+	}
+	if cleaned != "" {
+		cleaned = stripDuplicateGeneralPrefix(current[:cursor], cleaned)
+	}
+	if cleaned != "" && hasDoubleSemicolonTrigger(current) {
+		if indent := leadingIndent(current); indent != "" {
+			cleaned = applyIndent(indent, cleaned)
+		}
+	}
+	return cleaned
 }
 
 // buildCompletionMessages constructs the LLM messages for completion.
 func (s *Server) buildCompletionMessages(inlinePrompt, hasExtra bool, extraText string, inParams bool, p CompletionParams, above, current, below, funcCtx string) []llm.Message {
-	sysPrompt, userPrompt := buildPrompts(inParams, p, above, current, below, funcCtx)
+	reg := s.config().prompts
+	sysPrompt, userPrompt := s.buildPrompts(inParams, p, above, current, below, funcCtx)
 	messages := []llm.Message{
 		{Role: "system", Content: sysPrompt},
 		{Role: "user", Content: userPrompt},
 	}
 	if hasExtra && extraText != "" {
-		messages = append(messages, llm.Message{Role: "user", Content: "Additional context:\n" + extraText})
+		extra := reg.Render(prompts.CompletionContext, prompts.Data{File: p.TextDocument.URI, Language: s.languageFor(p.TextDocument.URI), Context: extraText})
+		messages = append(messages, llm.Message{Role: "user", Content: extra})
 	}
 	if inlinePrompt {
-		messages[0].Content = "You are a precise code completion/refactoring engine. Output only the code to insert with no prose, no comments, and no backticks. Return raw code only."
+		messages[0].Content = reg.Render(prompts.CompletionInlineSystem, prompts.Data{File: p.TextDocument.URI, Language: s.languageFor(p.TextDocument.URI)})
+		if tag, _, _, ok := findStrictSemicolonTag(current); ok {
+			// mentioned files and symbols go right after the system prompt;
+			// the tag may predate this edit, so files stay in the workspace
+			mentions := s.mentionMessages(p.TextDocument.URI, tag, "")
+			messages = append(messages[:1], append(mentions, messages[1:]...)...)
+		}
 	}
 	return messages
 }
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"hexai/internal/llm"
 	"hexai/internal/logging"
+	"hexai/internal/prompts"
 	"strings"
 	"time"
 )
@@ -13,7 +14,10 @@ import (
 func (s *Server) handleDidOpen(req Request) {
 	var p DidOpenTextDocumentParams
 	if err := json.Unmarshal(req.Params, &p); err == nil {
-		s.setDocument(p.TextDocument.URI, p.TextDocument.Text)
+		d := newDocument(p.TextDocument.URI, p.TextDocument.Version, splitLines(p.TextDocument.Text))
+		d.languageID = p.TextDocument.LanguageID
+		s.putDocument(d)
+		s.markLexicalStale(d.uri)
 		s.markActivity()
 	}
 }
@@ -22,7 +26,9 @@ func (s *Server) handleDidChange(req Request) {
 	var p DidChangeTextDocumentParams
 	if err := json.Unmarshal(req.Params, &p); err == nil {
 		if len(p.ContentChanges) > 0 {
-			s.setDocument(p.TextDocument.URI, p.ContentChanges[len(p.ContentChanges)-1].Text)
+			s.applyContentChanges(p)
+			s.cancelReview(p.TextDocument.URI)
+			s.markLexicalStale(p.TextDocument.URI)
 		}
 		s.markActivity()
 		// Detect in-editor chat trigger lines and respond inline.
@@ -30,10 +36,33 @@ func (s *Server) handleDidChange(req Request) {
 	}
 }
 
+// applyContentChanges applies the changes of one didChange notification in
+// order and stores the resulting snapshot. Ranged changes to an unknown
+// document are dropped, since there is no base text to apply them to.
+func (s *Server) applyContentChanges(p DidChangeTextDocumentParams) {
+	uri := p.TextDocument.URI
+	d := s.getDocument(uri)
+	s.mu.RLock()
+	enc := s.posEncoding
+	s.mu.RUnlock()
+	for _, c := range p.ContentChanges {
+		if d == nil {
+			if c.Range != nil {
+				logging.Logf("lsp ", "didChange: ranged change for unknown document uri=%s", uri)
+				return
+			}
+			d = newDocument(uri, p.TextDocument.Version, nil)
+		}
+		d = d.applyChange(c, p.TextDocument.Version, enc)
+	}
+	s.putDocument(d)
+}
+
 func (s *Server) handleDidClose(req Request) {
 	var p DidCloseTextDocumentParams
 	if err := json.Unmarshal(req.Params, &p); err == nil {
 		s.deleteDocument(p.TextDocument.URI)
+		s.forgetReview(p.TextDocument.URI)
 		s.markActivity()
 	}
 }
@@ -83,15 +112,18 @@ func (s *Server) docBeforeAfter(uri string, pos Position) (string, string) {
 
 // detectAndHandleChat scans the current document for any line that starts with
 // a new trigger pair (e.g., "?>" ",>" ":>" ";>") at EOL and inserts the LLM
-// reply below.
+// reply below. Questions written as line comments are answered with comment
+// lines, and the code around them is sent along.
 func (s *Server) detectAndHandleChat(uri string) {
-	if s.llmClient == nil {
+	cfg := s.config()
+	if cfg.llmClient == nil {
 		return
 	}
 	d := s.getDocument(uri)
 	if d == nil || len(d.lines) == 0 {
 		return
 	}
+	lang := s.settingsFor(uri).language
 	for i, raw := range d.lines {
 		// Find last non-space character index
 		j := len(raw) - 1
@@ -115,37 +147,73 @@ func (s *Server) detectAndHandleChat(uri string) {
 		for k < len(d.lines) && strings.TrimSpace(d.lines[k]) == "" {
 			k++
 		}
-		if k < len(d.lines) && strings.HasPrefix(strings.TrimSpace(d.lines[k]), ">") {
+		if k < len(d.lines) && strings.HasPrefix(chatText(lang, d.lines[k]), ">") {
 			continue
 		}
-		// Derive prompt by removing only the trailing '>'
+		// Derive prompt by removing only the trailing '>' (and a comment marker)
 		removeCount := 1
 		base := raw[:j+1-removeCount]
-		prompt := strings.TrimSpace(base)
+		prompt := chatText(lang, base)
 		if prompt == "" {
 			continue
 		}
 		lineIdx := i
 		lastIdx := j
+		style := replyStyle(lang, raw)
+		if !s.beginChat(uri) {
+			return // a reply for this document is still in flight
+		}
+		if s.excluded(uri) {
+			// nothing is sent from an ignored document; the refusal is the
+			// reply, like any other chat error
+			go func() {
+				defer s.endChat(uri)
+				s.applyChatEdits(uri, lineIdx, lastIdx, removeCount, style, errExcluded(uri).Error())
+			}()
+			return
+		}
+		if cmd, ok := parseChatCommand(prompt); ok && cmd.name != "file" {
+			go s.runChatCommand(uri, cmd, lineIdx, lastIdx, style)
+			break
+		}
 		go func(prompt string, remove int) {
-			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			defer s.endChat(uri)
+			ctx, end := s.beginProgress(context.Background(), "Hexai: chat")
+			defer end()
+			data, extra, prompt, err := s.chatContext(d, lineIdx, style, prompt)
+			if err != nil {
+				s.applyChatEdits(uri, lineIdx, lastIdx, remove, style, err.Error())
+				return
+			}
+			sys := cfg.prompts.Render(prompts.ChatSystem, data)
+			// Chat sessions send the whole transcript; elsewhere a short
+			// history is built from the document above this line
+			var history []llm.Message
+			if isChatSession(uri) {
+				history = s.sessionHistory(ctx, uri, lineIdx, prompt)
+			} else {
+				history = s.buildChatHistory(uri, lineIdx, prompt)
+			}
+			msgs := append([]llm.Message{{Role: "system", Content: sys}}, extra...)
+			msgs = append(msgs, history...)
+			if st, ok := cfg.llmClient.(llm.Streamer); ok {
+				s.streamChatReply(ctx, st, msgs, uri, lineIdx, raw, lastIdx)
+				return
+			}
+			ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
 			defer cancel()
-			sys := "You are a helpful coding assistant. Answer concisely and clearly."
-			// Build short conversation history from the document above this line
-			history := s.buildChatHistory(uri, lineIdx, prompt)
-			msgs := append([]llm.Message{{Role: "system", Content: sys}}, history...)
-			opts := s.llmRequestOpts()
-			logging.Logf("lsp ", "chat llm=requesting model=%s", s.llmClient.DefaultModel())
-			text, err := s.llmClient.Chat(ctx, msgs, opts...)
+			opts := s.chatRequestOpts(uri)
+			logging.Logf("lsp ", "chat llm=requesting model=%s", s.chatModelFor(uri))
+			text, err := cfg.llmClient.Chat(ctx, msgs, opts...)
 			if err != nil {
-				logging.Logf("lsp ", "chat llm error: %v", err)
+				s.llmError("chat", err)
 				return
 			}
 			out := strings.TrimSpace(stripCodeFences(text))
 			if out == "" {
 				return
 			}
-			s.applyChatEdits(uri, lineIdx, lastIdx, remove, "> "+out)
+			s.applyChatEdits(uri, lineIdx, lastIdx, remove, style, out)
 		}(prompt, removeCount)
 		// Only handle one per change tick to avoid flooding
 		break
@@ -153,50 +221,57 @@ func (s *Server) detectAndHandleChat(uri string) {
 }
 
 // applyChatEdits removes the triggering punctuation at end of the line and
-// inserts two newlines followed by a new line with the response prefixed.
-func (s *Server) applyChatEdits(uri string, lineIdx int, lastNonSpace int, removeCount int, response string) {
+// inserts the response below it, laid out in style.
+func (s *Server) applyChatEdits(uri string, lineIdx int, lastNonSpace int, removeCount int, style chatReplyStyle, response string) {
 	d := s.getDocument(uri)
 	if d == nil {
 		return
 	}
+	s.clientApplyEdit("Hexai: insert chat response", chatReplyEdit(uri, d, lineIdx, lastNonSpace, removeCount, style, response))
+}
+
+// chatReplyEdit builds the edit of applyChatEdits for document d.
+func chatReplyEdit(uri string, d *document, lineIdx int, lastNonSpace int, removeCount int, style chatReplyStyle, response string) WorkspaceEdit {
 	// 1) Delete the trailing punctuation (1 or 2 chars)
 	delStart := Position{Line: lineIdx, Character: lastNonSpace + 1 - removeCount}
 	delEnd := Position{Line: lineIdx, Character: lastNonSpace + 1}
-	// 2) Insert two newlines and the response at end-of-line, then one extra blank line
+	// 2) Insert the prefixed response at end-of-line (plain replies are
+	// surrounded by blank lines)
 	insPos := Position{Line: lineIdx, Character: len(d.lines[lineIdx])}
-	resp := strings.TrimRight(response, "\n") + "\n"
-	insert := "\n\n" + resp + "\n"
+	insert := style.opening() + style.render(strings.TrimRight(response, "\n")) + style.closing()
 	edits := []TextEdit{
 		{Range: Range{Start: delStart, End: delEnd}, NewText: ""},
 		{Range: Range{Start: insPos, End: insPos}, NewText: insert},
 	}
-	we := WorkspaceEdit{Changes: map[string][]TextEdit{uri: edits}}
-	s.clientApplyEdit("Hexai: insert chat response", we)
+	return WorkspaceEdit{Changes: map[string][]TextEdit{uri: edits}}
 }
 
 // buildChatHistory walks upwards from the current line to collect the most recent
-// Q/A pairs in the in-editor transcript. Returns messages ending with current prompt.
+// Q/A pairs in the in-editor transcript, reading comment-prefixed pairs as
+// written for comment questions. Returns messages ending with current prompt.
 func (s *Server) buildChatHistory(uri string, lineIdx int, currentPrompt string) []llm.Message {
 	d := s.getDocument(uri)
 	if d == nil {
 		return []llm.Message{{Role: "user", Content: currentPrompt}}
 	}
+	lang := s.settingsFor(uri).language
+	text := func(i int) string { return chatText(lang, d.lines[i]) }
 	type pair struct{ q, a string }
 	pairs := []pair{}
 	i := lineIdx - 1
 	for i >= 0 && len(pairs) < 3 {
-		for i >= 0 && strings.TrimSpace(d.lines[i]) == "" {
+		for i >= 0 && text(i) == "" {
 			i--
 		}
 		if i < 0 {
 			break
 		}
-		if !strings.HasPrefix(strings.TrimSpace(d.lines[i]), ">") {
+		if !strings.HasPrefix(text(i), ">") {
 			break
 		}
 		var replyLines []string
 		for i >= 0 {
-			line := strings.TrimSpace(d.lines[i])
+			line := text(i)
 			if strings.HasPrefix(line, ">") {
 				replyLines = append([]string{strings.TrimSpace(strings.TrimPrefix(line, ">"))}, replyLines...)
 				i--
@@ -204,15 +279,19 @@ func (s *Server) buildChatHistory(uri string, lineIdx int, currentPrompt string)
 			}
 			break
 		}
-		for i >= 0 && strings.TrimSpace(d.lines[i]) == "" {
+		for i >= 0 && text(i) == "" {
 			i--
 		}
 		if i < 0 {
 			break
 		}
-		q := strings.TrimSpace(d.lines[i])
-		q = stripTrailingTrigger(q)
-		pairs = append([]pair{{q: q, a: strings.Join(replyLines, "\n")}}, pairs...)
+		q, keep, stop := historyTurn(stripTrailingTrigger(text(i)))
+		if stop {
+			break
+		}
+		if keep {
+			pairs = append([]pair{{q: q, a: strings.Join(replyLines, "\n")}}, pairs...)
+		}
 		i--
 	}
 	msgs := make([]llm.Message, 0, len(pairs)*2+1)
@@ -262,6 +341,27 @@ func (s *Server) clientApplyEdit(label string, edit WorkspaceEdit) {
 	s.writeMessage(req)
 }
 
+// beginChat marks a chat reply for uri as in flight; it returns false when
+// one is already running so repeated didChange events do not ask twice.
+func (s *Server) beginChat(uri string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.chatInFlight[uri] {
+		return false
+	}
+	if s.chatInFlight == nil {
+		s.chatInFlight = make(map[string]bool)
+	}
+	s.chatInFlight[uri] = true
+	return true
+}
+
+func (s *Server) endChat(uri string) {
+	s.mu.Lock()
+	delete(s.chatInFlight, uri)
+	s.mu.Unlock()
+}
+
 // nextReqID returns a unique json.RawMessage id for server-initiated requests.
 func (s *Server) nextReqID() json.RawMessage {
 	s.mu.Lock()
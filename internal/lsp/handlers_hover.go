@@ -0,0 +1,172 @@
+// Summary: textDocument/hover handler; explains the symbol or expression under the cursor with the LLM.
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"hexai/internal/llm"
+	"hexai/internal/logging"
+	"hexai/internal/prompts"
+)
+
+const (
+	// hoverSnippetLines caps the surrounding code sent along with a hover.
+	hoverSnippetLines = 60
+	// hoverCacheSize bounds the number of cached hover explanations.
+	hoverCacheSize = 32
+)
+
+func (s *Server) handleHover(req Request) {
+	arrived := time.Now()
+	var p HoverParams
+	if err := json.Unmarshal(req.Params, &p); err != nil {
+		s.reply(req.ID, nil, nil)
+		return
+	}
+	s.noteCursor(p.TextDocument.URI, Range{Start: p.Position, End: p.Position})
+	cfg := s.config()
+	if !cfg.hoverEnabled || cfg.llmClient == nil || s.excluded(p.TextDocument.URI) {
+		s.reply(req.ID, nil, nil)
+		return
+	}
+	d := s.getDocument(p.TextDocument.URI)
+	if d == nil || p.Position.Line < 0 || p.Position.Line >= len(d.lines) {
+		s.reply(req.ID, nil, nil)
+		return
+	}
+	expr, r := expressionAt(d.lines[p.Position.Line], p.Position)
+	if expr == "" {
+		s.reply(req.ID, nil, nil)
+		return
+	}
+	key := fmt.Sprintf("%s\x1f%d\x1f%d:%d\x1f%s", d.uri, d.version, r.Start.Line, r.Start.Character, expr)
+	if text, ok := s.hoverCacheGet(key); ok {
+		logging.Logf("lsp ", "hover cache hit uri=%s line=%d expr=%q", d.uri, p.Position.Line, expr)
+		s.reply(req.ID, hoverResult(text, r), nil)
+		return
+	}
+	if !s.hoverMayRun(arrived, cfg.hoverMinDelay) {
+		s.reply(req.ID, nil, nil)
+		return
+	}
+	text, err := s.explainExpression(cfg, d, expr, p.Position.Line)
+	if err != nil || text == "" {
+		if err != nil {
+			s.llmError("hover", err)
+		}
+		s.reply(req.ID, nil, nil)
+		return
+	}
+	s.hoverCachePut(key, text)
+	s.reply(req.ID, hoverResult(text, r), nil)
+}
+
+// hoverMayRun waits for the minimum hover delay and reports whether the LLM
+// may still be asked: the user must not have typed since the hover arrived,
+// and no completion may be in flight. Hover never takes the llmBusy lock, so
+// it cannot make completion report "busy".
+func (s *Server) hoverMayRun(arrived time.Time, minDelay time.Duration) bool {
+	time.Sleep(time.Until(arrived.Add(minDelay)))
+	s.mu.RLock()
+	typed, busy := s.lastInput.After(arrived), s.llmBusy
+	s.mu.RUnlock()
+	if typed || busy {
+		logging.Logf("lsp ", "hover skip typed=%t llm_busy=%t", typed, busy)
+		return false
+	}
+	return true
+}
+
+// explainExpression asks the LLM to explain expr within its surrounding code.
+func (s *Server) explainExpression(cfg *serverConfig, d *document, expr string, line int) (string, error) {
+	data := prompts.Data{File: d.uri, Language: d.language(), Symbol: expr, Current: d.lines[line], Function: functionSnippet(d.lines, line)}
+	msgs := []llm.Message{
+		{Role: "system", Content: cfg.prompts.Render(prompts.HoverSystem, data)},
+		{Role: "user", Content: cfg.prompts.Render(prompts.HoverUser, data)},
+	}
+	sent := 0
+	for _, m := range msgs {
+		sent += len(m.Content)
+	}
+	s.incSentCounters(sent)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	logging.Logf("lsp ", "hover llm=requesting model=%s expr=%q", s.currentModelFor(d.uri), expr)
+	text, err := cfg.llmClient.Chat(ctx, msgs, s.llmRequestOpts(d.uri)...)
+	if err != nil {
+		return "", err
+	}
+	s.incRecvCounters(len(text))
+	s.logLLMStats()
+	return strings.TrimSpace(text), nil
+}
+
+func hoverResult(text string, r Range) Hover {
+	return Hover{Contents: MarkupContent{Kind: "markdown", Value: text}, Range: &r}
+}
+
+// expressionAt returns the identifier or selector expression (a.b.c) under
+// pos, including the identifier just left of the cursor, and its range.
+func expressionAt(line string, pos Position) (string, Range) {
+	at := min(max(pos.Character, 0), len(line))
+	if (at == len(line) || !isIdentChar(line[at])) && at > 0 && isIdentChar(line[at-1]) {
+		at--
+	}
+	if at >= len(line) || !isIdentChar(line[at]) {
+		return "", Range{}
+	}
+	start := at
+	for start > 0 && (isIdentChar(line[start-1]) || line[start-1] == '.') {
+		start--
+	}
+	end := at
+	for end < len(line) && isIdentChar(line[end]) {
+		end++
+	}
+	for start < end && line[start] == '.' {
+		start++
+	}
+	r := Range{Start: Position{Line: pos.Line, Character: start}, End: Position{Line: pos.Line, Character: end}}
+	return line[start:end], r
+}
+
+// functionSnippet returns the code of the declaration enclosing line, found
+// with enclosingDeclaration like the doc action and mentions. Without an
+// enclosing declaration it returns a window of lines around line. The result
+// is capped at hoverSnippetLines lines.
+func functionSnippet(lines []string, line int) string {
+	start := enclosingDeclaration(lines, line)
+	if start < 0 {
+		start = max(0, line-10)
+		return strings.Join(lines[start:min(len(lines), line+11)], "\n")
+	}
+	return strings.Join(lines[start:declarationEnd(lines, start, hoverSnippetLines)+1], "\n")
+}
+
+func (s *Server) hoverCacheGet(key string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.hoverCache[key]
+	return v, ok
+}
+
+// hoverCachePut stores an explanation, evicting the oldest entry when full.
+func (s *Server) hoverCachePut(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.hoverCache == nil {
+		s.hoverCache = make(map[string]string)
+	}
+	if _, exists := s.hoverCache[key]; !exists {
+		s.hoverCacheOrder = append(s.hoverCacheOrder, key)
+		if len(s.hoverCacheOrder) > hoverCacheSize {
+			delete(s.hoverCache, s.hoverCacheOrder[0])
+			s.hoverCacheOrder = s.hoverCacheOrder[1:]
+		}
+	}
+	s.hoverCache[key] = value
+}
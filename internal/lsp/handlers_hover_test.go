@@ -0,0 +1,102 @@
+package lsp
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestExpressionAt(t *testing.T) {
+	cases := []struct {
+		line string
+		char int
+		want string
+	}{
+		{"\tfmt.Println(x)", 7, "fmt.Println"},
+		{"\tfmt.Println(x)", 2, "fmt"},
+		{"\tfmt.Println(x)", 12, "fmt.Println"}, // cursor right after the identifier
+		{"a := b.c.d + 1", 9, "b.c.d"},
+		{"x := 1 + 2", 7, ""},
+	}
+	for _, tc := range cases {
+		got, _ := expressionAt(tc.line, Position{Character: tc.char})
+		if got != tc.want {
+			t.Fatalf("%q@%d: got %q want %q", tc.line, tc.char, got, tc.want)
+		}
+	}
+}
+
+func TestFunctionSnippet_StopsAtNextFunction(t *testing.T) {
+	lines := splitLines("package a\n\nfunc A() {\n\tx := 1\n}\n\nfunc B() {}")
+	got := functionSnippet(lines, 3)
+	if got != "func A() {\n\tx := 1\n}" {
+		t.Fatalf("unexpected snippet: %q", got)
+	}
+}
+
+func TestFunctionSnippet_PythonMethod(t *testing.T) {
+	lines := splitLines("class C:\n    def m(self):\n        return 1\n\n    def n(self):\n        pass")
+	got := functionSnippet(lines, 2)
+	if got != "    def m(self):\n        return 1" {
+		t.Fatalf("unexpected snippet: %q", got)
+	}
+}
+
+func newHoverTestServer(buf *bytes.Buffer, enabled bool) (*Server, *countingLLM) {
+	s := newTestServer()
+	s.out = buf
+	setConfig(s, func(c *serverConfig) { c.hoverEnabled = enabled })
+	fake := &countingLLM{}
+	setConfig(s, func(c *serverConfig) { c.llmClient = fake })
+	s.setDocument("file:///a.go", "func A() {\n\tvalue := compute()\n}")
+	return s, fake
+}
+
+func hoverRequest(line, char int) Request {
+	params, _ := json.Marshal(HoverParams{TextDocument: TextDocumentIdentifier{URI: "file:///a.go"}, Position: Position{Line: line, Character: char}})
+	return Request{ID: json.RawMessage(`1`), Params: params}
+}
+
+func TestHover_DisabledDoesNotCallLLM(t *testing.T) {
+	var buf bytes.Buffer
+	s, fake := newHoverTestServer(&buf, false)
+	s.handleHover(hoverRequest(1, 12))
+	if fake.calls != 0 {
+		t.Fatalf("expected no LLM call when hover is disabled")
+	}
+}
+
+func TestHover_ExplainsAndCachesPerVersionAndPosition(t *testing.T) {
+	var buf bytes.Buffer
+	s, fake := newHoverTestServer(&buf, true)
+	s.handleHover(hoverRequest(1, 12))
+	var h Hover
+	readReply(t, &buf, &h)
+	if h.Contents.Kind != "markdown" || h.Contents.Value == "" || h.Range == nil || h.Range.Start.Character != 10 {
+		t.Fatalf("unexpected hover: %+v", h)
+	}
+	s.handleHover(hoverRequest(1, 14)) // same expression and document version
+	readReply(t, &buf, &h)
+	if fake.calls != 1 {
+		t.Fatalf("expected cached second hover, got %d LLM calls", fake.calls)
+	}
+	s.setDocument("file:///a.go", "func A() {\n\tvalue := compute()\n}")
+	s.docs["file:///a.go"].version = 2
+	s.handleHover(hoverRequest(1, 12))
+	if fake.calls != 2 {
+		t.Fatalf("expected new LLM call for a new document version, got %d", fake.calls)
+	}
+}
+
+func TestHover_SkipsWhileCompletionInFlight(t *testing.T) {
+	var buf bytes.Buffer
+	s, fake := newHoverTestServer(&buf, true)
+	s.setLLMBusy(true)
+	s.handleHover(hoverRequest(1, 12))
+	if fake.calls != 0 {
+		t.Fatalf("hover must not call the LLM while completion is busy")
+	}
+	if s.isLLMBusy() != true {
+		t.Fatalf("hover must not touch the llmBusy flag")
+	}
+}
@@ -2,32 +2,134 @@
 package lsp
 
 import (
+	"encoding/json"
 	"hexai/internal"
 	"hexai/internal/logging"
 	"os"
+	"slices"
 )
 
 func (s *Server) handleInitialize(req Request) {
+	var p InitializeParams
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			logging.Logf("lsp ", "initialize: cannot parse params: %v", err)
+		}
+	}
+	td := p.Capabilities.TextDocument
+	inline := td != nil && len(td.InlineCompletion) > 0
+	dynamic := td != nil && td.Completion != nil && td.Completion.DynamicRegistration
+	progress := p.Capabilities.Window != nil && p.Capabilities.Window.WorkDoneProgress
+	enc := negotiatePositionEncoding(p.Capabilities)
+	var docChanges, createFiles bool
+	if ws := p.Capabilities.Workspace; ws != nil && ws.WorkspaceEdit != nil {
+		docChanges = ws.WorkspaceEdit.DocumentChanges
+		createFiles = docChanges && slices.Contains(ws.WorkspaceEdit.ResourceOperations, "create")
+	}
+	s.mu.Lock()
+	s.clientInlineCompletion = inline
+	s.dynamicCompletion = dynamic
+	s.workDoneProgress = progress
+	s.posEncoding = enc
+	s.editDocumentChanges, s.editCreateFiles = docChanges, createFiles
+	s.mu.Unlock()
+	logging.Logf("lsp ", "client inlineCompletion=%t positionEncoding=%s", inline, enc)
+	root := workspaceRoot(p)
+	if root != "" {
+		s.applyProjectConfig(root)
+		s.loadIgnore(root)
+	}
+	if s.usesContextMode("retrieval") {
+		s.startLexicalIndex(root)
+	}
+	s.mu.RLock()
+	embeddings := s.embedder != nil
+	s.mu.RUnlock()
+	if embeddings && root != "" {
+		s.startVectorIndex(root)
+	}
+	cfg := s.config()
 	version := internal.Version
-	if s.llmClient != nil {
-		version = version + " [" + s.llmClient.Name() + ":" + s.llmClient.DefaultModel() + "]"
+	if cfg.llmClient != nil {
+		version = version + " [" + cfg.llmClient.Name() + ":" + cfg.llmClient.DefaultModel() + "]"
 	}
 	res := InitializeResult{
 		Capabilities: ServerCapabilities{
-			TextDocumentSync: 1, // 1 = TextDocumentSyncKindFull
-			CompletionProvider: &CompletionOptions{
-				ResolveProvider:   false,
-				TriggerCharacters: s.triggerChars,
-			},
-			CodeActionProvider: CodeActionOptions{ResolveProvider: true},
+			PositionEncoding:         enc,
+			TextDocumentSync:         TextDocumentSyncOptions{OpenClose: true, Change: TextDocumentSyncKindIncremental, Save: &SaveOptions{}},
+			HoverProvider:            cfg.hoverEnabled && cfg.llmClient != nil,
+			ExecuteCommandProvider:   &ExecuteCommandOptions{Commands: s.commandNames()},
+			CodeLensProvider:         &CodeLensOptions{ResolveProvider: true},
+			CodeActionProvider:       CodeActionOptions{ResolveProvider: true},
+			InlineCompletionProvider: true,
 		},
 		ServerInfo: &ServerInfo{Name: "hexai", Version: version},
 	}
+	if !dynamic {
+		// Registered in handleInitialized otherwise, so that reloads can
+		// change the trigger characters.
+		res.Capabilities.CompletionProvider = &CompletionOptions{TriggerCharacters: s.advertisedTriggerChars()}
+	}
 	s.reply(req.ID, res, nil)
 }
 
+const (
+	positionEncodingUTF8  = "utf-8"
+	positionEncodingUTF16 = "utf-16"
+)
+
+// negotiatePositionEncoding picks utf-8 when the client offers it, since the
+// server indexes lines by byte; otherwise the LSP default utf-16 applies.
+func negotiatePositionEncoding(c ClientCapabilities) string {
+	if c.General != nil {
+		for _, e := range c.General.PositionEncodings {
+			if e == positionEncodingUTF8 {
+				return positionEncodingUTF8
+			}
+		}
+	}
+	return positionEncodingUTF16
+}
+
+// workspaceRoot returns the directory of the root URI, or of the first
+// workspace folder when the client sends no root URI.
+func workspaceRoot(p InitializeParams) string {
+	if p.RootURI != "" {
+		return uriToPath(p.RootURI)
+	}
+	if len(p.WorkspaceFolders) > 0 {
+		return uriToPath(p.WorkspaceFolders[0].URI)
+	}
+	return ""
+}
+
+// applyProjectConfig reloads the configuration with the project config of
+// root, so that it applies before the capabilities are announced. On error
+// the current settings are kept.
+func (s *Server) applyProjectConfig(root string) {
+	s.mu.Lock()
+	s.root = root
+	s.mu.Unlock()
+	if s.reloadConfig == nil {
+		return
+	}
+	if err := s.reload("initialize"); err != nil {
+		logging.Logf("lsp ", "project config: %v (keeping current settings)", err)
+	}
+}
+
 func (s *Server) handleInitialized() {
 	logging.Logf("lsp ", "client initialized")
+	s.reportConfigProblems(true)
+	s.mu.RLock()
+	dynamic := s.dynamicCompletion
+	s.mu.RUnlock()
+	if dynamic {
+		s.registerCompletion(s.advertisedTriggerChars())
+	}
+	if s.reloadConfig != nil {
+		go s.watchConfig(configPollInterval)
+	}
 }
 
 func (s *Server) handleShutdown(req Request) {
@@ -0,0 +1,99 @@
+// Summary: textDocument/inlineCompletion handler; serves LLM completions as ghost text via the shared completion pipeline.
+package lsp
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"hexai/internal/logging"
+)
+
+func (s *Server) handleInlineCompletion(req Request) {
+	var p InlineCompletionParams
+	if err := json.Unmarshal(req.Params, &p); err != nil || s.config().llmClient == nil || s.excluded(p.TextDocument.URI) {
+		s.reply(req.ID, InlineCompletionList{Items: []InlineCompletionItem{}}, nil)
+		return
+	}
+	cp := completionParamsFromInline(p)
+	s.noteCursor(cp.TextDocument.URI, Range{Start: cp.Position, End: cp.Position})
+	logging.Logf("lsp ", "inlineCompletion trigger kind=%d uri=%s line=%d char=%d",
+		p.Context.TriggerKind, p.TextDocument.URI, p.Position.Line, p.Position.Character)
+	above, current, below, funcCtx := s.lineContext(cp.TextDocument.URI, cp.Position)
+	if s.logContext {
+		s.logCompletionContext(cp, above, current, below, funcCtx)
+	}
+	newFunc := s.isDefiningNewFunction(cp.TextDocument.URI, cp.Position)
+	extra, has := s.buildAdditionalContext(newFunc, cp.TextDocument.URI, cp.Position)
+	res, ok := s.llmCompletion(cp, above, current, below, funcCtx, has, extra)
+	items := []InlineCompletionItem{}
+	if ok && !res.busy && res.text != "" {
+		items = append(items, s.makeInlineCompletionItem(res.text, res.inParams, current, cp))
+	}
+	s.reply(req.ID, InlineCompletionList{Items: items}, nil)
+}
+
+// usesInlineCompletion reports whether the client declared inline completion
+// support, in which case classic completion skips the LLM.
+func (s *Server) usesInlineCompletion() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.clientInlineCompletion
+}
+
+// completionParamsFromInline maps inline completion params onto the classic
+// ones. An explicit invoke becomes a manual completion (triggerKind 1); an
+// automatic request carries no context, so the pipeline falls back to checking
+// the character left of the cursor against the trigger characters.
+func completionParamsFromInline(p InlineCompletionParams) CompletionParams {
+	cp := CompletionParams{TextDocument: p.TextDocument, Position: p.Position}
+	if p.Context.TriggerKind == 1 {
+		cp.Context = json.RawMessage(`{"triggerKind":1}`)
+	}
+	return cp
+}
+
+// makeInlineCompletionItem builds a ghost-text item that has the same effect
+// as accepting the classic completion item. Inline completion items cannot
+// carry additional edits, so when inline prompt markers must be removed the
+// item's range is widened to cover every affected line.
+func (s *Server) makeInlineCompletionItem(cleaned string, inParams bool, current string, p CompletionParams) InlineCompletionItem {
+	te, filter := computeTextEditAndFilter(cleaned, inParams, current, p)
+	rm := s.collectPromptRemovalEdits(p.TextDocument.URI)
+	d := s.getDocument(p.TextDocument.URI)
+	if len(rm) == 0 || d == nil {
+		return InlineCompletionItem{InsertText: te.NewText, FilterText: strings.TrimLeft(filter, " \t"), Range: &te.Range}
+	}
+	edits := append(rm, *te)
+	first, last := te.Range.Start.Line, te.Range.End.Line
+	for _, e := range rm {
+		first, last = min(first, e.Range.Start.Line), max(last, e.Range.End.Line)
+	}
+	last = min(last, len(d.lines)-1)
+	text := applyEditsToLines(d.lines[first:last+1], first, edits)
+	r := Range{Start: Position{Line: first}, End: Position{Line: last, Character: len(d.lines[last])}}
+	return InlineCompletionItem{InsertText: text, Range: &r}
+}
+
+// applyEditsToLines applies non-overlapping edits to lines (which start at
+// document line first) and returns the resulting text.
+func applyEditsToLines(lines []string, first int, edits []TextEdit) string {
+	text := strings.Join(lines, "\n")
+	offset := func(p Position) int {
+		n := 0
+		for i := 0; i < p.Line-first && i < len(lines); i++ {
+			n += len(lines[i]) + 1
+		}
+		return min(n+p.Character, len(text))
+	}
+	sorted := append([]TextEdit{}, edits...)
+	sort.SliceStable(sorted, func(i, j int) bool { return lessPos(sorted[j].Range.Start, sorted[i].Range.Start) })
+	for _, e := range sorted {
+		start, end := offset(e.Range.Start), offset(e.Range.End)
+		if start > end {
+			continue
+		}
+		text = text[:start] + e.NewText + text[end:]
+	}
+	return text
+}
@@ -0,0 +1,130 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// readReply decodes the single JSON-RPC response written to buf.
+func readReply(t *testing.T, buf *bytes.Buffer, result any) {
+	t.Helper()
+	body, err := (&Server{in: bufio.NewReader(buf)}).readMessage()
+	if err != nil {
+		t.Fatalf("read reply: %v", err)
+	}
+	var resp struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("decode reply: %v", err)
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		t.Fatalf("decode result: %v (%s)", err, resp.Result)
+	}
+}
+
+func TestInitialize_AdvertisesInlineCompletionAndRecordsClientSupport(t *testing.T) {
+	var buf bytes.Buffer
+	s := newTestServer()
+	s.out = &buf
+	s.handleInitialize(Request{ID: json.RawMessage(`1`), Params: json.RawMessage(`{"capabilities":{"textDocument":{"inlineCompletion":{}}}}`)})
+	var res InitializeResult
+	readReply(t, &buf, &res)
+	if res.Capabilities.InlineCompletionProvider != true {
+		t.Fatalf("expected inlineCompletionProvider=true, got %v", res.Capabilities.InlineCompletionProvider)
+	}
+	if !s.usesInlineCompletion() {
+		t.Fatalf("expected client inline completion support to be recorded")
+	}
+}
+
+func TestInitialize_RecordsWorkspaceEditSupport(t *testing.T) {
+	var buf bytes.Buffer
+	s := newTestServer()
+	s.out = &buf
+	s.handleInitialize(Request{ID: json.RawMessage(`1`), Params: json.RawMessage(`{"capabilities":{"workspace":{"workspaceEdit":{"documentChanges":true,"resourceOperations":["create","rename"]}}}}`)})
+	if !s.editDocumentChanges || !s.editCreateFiles {
+		t.Fatalf("expected documentChanges and create support, got %t %t", s.editDocumentChanges, s.editCreateFiles)
+	}
+	s.handleInitialize(Request{ID: json.RawMessage(`2`), Params: json.RawMessage(`{"capabilities":{}}`)})
+	if s.editDocumentChanges || s.editCreateFiles {
+		t.Fatalf("clients without the capability must not get documentChanges")
+	}
+}
+
+func TestCompletion_SkipsLLMForInlineCompletionClients(t *testing.T) {
+	var buf bytes.Buffer
+	s := newTestServer()
+	s.out = &buf
+	fake := &countingLLM{}
+	setConfig(s, func(c *serverConfig) { c.llmClient = fake })
+	s.clientInlineCompletion = true
+	s.setDocument("file:///a.go", "obj.")
+	params, _ := json.Marshal(CompletionParams{TextDocument: TextDocumentIdentifier{URI: "file:///a.go"}, Position: Position{Line: 0, Character: 4}})
+	s.handleCompletion(Request{ID: json.RawMessage(`1`), Params: params})
+	var list CompletionList
+	readReply(t, &buf, &list)
+	if len(list.Items) != 0 || fake.calls != 0 {
+		t.Fatalf("expected no items and no LLM call; items=%d calls=%d", len(list.Items), fake.calls)
+	}
+}
+
+func TestInlineCompletion_ReturnsGhostTextItemWithRange(t *testing.T) {
+	var buf bytes.Buffer
+	s := newTestServer()
+	s.out = &buf
+	setConfig(s, func(c *serverConfig) {
+		c.maxTokens = 32
+		c.triggerChars = []string{"."}
+	})
+	s.compCache = make(map[string]string)
+	setConfig(s, func(c *serverConfig) { c.llmClient = &fakeCodeLLM{result: "DoThing()"} })
+	s.setDocument("file:///a.go", "obj.")
+	params, _ := json.Marshal(InlineCompletionParams{TextDocument: TextDocumentIdentifier{URI: "file:///a.go"}, Position: Position{Line: 0, Character: 4}, Context: InlineCompletionContext{TriggerKind: 2}})
+	s.handleInlineCompletion(Request{ID: json.RawMessage(`1`), Params: params})
+	var list InlineCompletionList
+	readReply(t, &buf, &list)
+	if len(list.Items) != 1 {
+		t.Fatalf("expected one inline item, got %d", len(list.Items))
+	}
+	it := list.Items[0]
+	if it.InsertText != "DoThing()" || it.Range == nil {
+		t.Fatalf("unexpected item: %+v", it)
+	}
+	if it.Range.Start != (Position{Line: 0, Character: 4}) || it.Range.End != (Position{Line: 0, Character: 4}) {
+		t.Fatalf("unexpected range: %+v", *it.Range)
+	}
+}
+
+func TestInlineCompletion_NoTriggerYieldsNoItems(t *testing.T) {
+	var buf bytes.Buffer
+	s := newTestServer()
+	s.out = &buf
+	setConfig(s, func(c *serverConfig) { c.triggerChars = []string{"."} })
+	fake := &countingLLM{}
+	setConfig(s, func(c *serverConfig) { c.llmClient = fake })
+	s.setDocument("file:///a.go", "obj x")
+	params, _ := json.Marshal(InlineCompletionParams{TextDocument: TextDocumentIdentifier{URI: "file:///a.go"}, Position: Position{Line: 0, Character: 5}, Context: InlineCompletionContext{TriggerKind: 2}})
+	s.handleInlineCompletion(Request{ID: json.RawMessage(`1`), Params: params})
+	var list InlineCompletionList
+	readReply(t, &buf, &list)
+	if len(list.Items) != 0 || fake.calls != 0 {
+		t.Fatalf("expected no items and no LLM call; items=%d calls=%d", len(list.Items), fake.calls)
+	}
+}
+
+func TestMakeInlineCompletionItem_WidensRangeToRemovePromptMarkers(t *testing.T) {
+	s := newTestServer()
+	s.setDocument("file:///a.go", "first ;add func; line\nx := ")
+	p := CompletionParams{TextDocument: TextDocumentIdentifier{URI: "file:///a.go"}, Position: Position{Line: 1, Character: 5}}
+	it := s.makeInlineCompletionItem("1", false, "x := ", p)
+	if it.Range == nil || it.Range.Start != (Position{Line: 0, Character: 0}) || it.Range.End != (Position{Line: 1, Character: 5}) {
+		t.Fatalf("unexpected range: %+v", it.Range)
+	}
+	if strings.Contains(it.InsertText, ";add func;") || !strings.HasSuffix(it.InsertText, "\nx := 1") {
+		t.Fatalf("unexpected insert text: %q", it.InsertText)
+	}
+}
@@ -2,22 +2,39 @@
 package lsp
 
 import (
-	"fmt"
 	"hexai/internal/llm"
 	"hexai/internal/logging"
+	"hexai/internal/prompts"
 	"strings"
 	"time"
 )
 
-// llmRequestOpts builds request options from server settings.
-func (s *Server) llmRequestOpts() []llm.RequestOption {
-	opts := []llm.RequestOption{llm.WithMaxTokens(s.maxTokens)}
-	if s.codingTemperature != nil {
-		opts = append(opts, llm.WithTemperature(*s.codingTemperature))
+// llmRequestOpts builds request options for a primary request about the
+// document uri from the server and language settings.
+func (s *Server) llmRequestOpts(uri string) []llm.RequestOption {
+	return s.withModel(uri, s.settingsFor(uri).requestOpts())
+}
+
+// withModel appends the model for uri (see modelFor), if any. Only
+// requests to the primary client may carry it.
+func (s *Server) withModel(uri string, opts []llm.RequestOption) []llm.RequestOption {
+	if model := s.modelFor(uri); model != "" {
+		return append(opts, llm.WithModel(model))
 	}
 	return opts
 }
 
+// currentModel returns the model used for primary requests.
+func (s *Server) currentModel() string {
+	s.mu.RLock()
+	model := s.modelOverride
+	s.mu.RUnlock()
+	if client := s.config().llmClient; model == "" && client != nil {
+		model = client.DefaultModel()
+	}
+	return model
+}
+
 // small helpers for LLM traffic stats
 func (s *Server) incSentCounters(n int) {
 	s.mu.Lock()
@@ -34,25 +51,44 @@ func (s *Server) incRecvCounters(n int) {
 }
 
 func (s *Server) logLLMStats() {
+	st := s.llmStats()
+	logging.Logf("lsp ", "llm stats reqs=%d avg_sent=%d avg_recv=%d sent_total=%d recv_total=%d rpm=%.2f sent_per_min=%.0f recv_per_min=%.0f",
+		st.reqs, st.avgSent, st.avgRecv, st.sentTotal, st.recvTotal, st.rpm, st.sentPerMin, st.recvPerMin)
+	if st.hedged {
+		logging.Logf("lsp ", "llm hedge stats fired=%d primary_wins=%d secondary_wins=%d", st.hedgeFired, st.primaryWins, st.secondaryWins)
+	}
+}
+
+// llmStatsSnapshot is a consistent copy of the LLM traffic counters.
+type llmStatsSnapshot struct {
+	reqs, avgSent, avgRecv, sentTotal, recvTotal int64
+	rpm, sentPerMin, recvPerMin                  float64
+	hedged                                       bool
+	hedgeFired, primaryWins, secondaryWins       int64
+}
+
+func (s *Server) llmStats() llmStatsSnapshot {
 	s.mu.RLock()
-	avgSent := int64(0)
+	st := llmStatsSnapshot{
+		reqs: s.llmReqTotal, sentTotal: s.llmSentBytesTotal, recvTotal: s.llmRespBytesTotal,
+		hedged: s.config().hedgeClient != nil, hedgeFired: s.hedgeFired,
+		primaryWins: s.hedgePrimaryWins, secondaryWins: s.hedgeSecondaryWins,
+	}
 	if s.llmReqTotal > 0 {
-		avgSent = s.llmSentBytesTotal / s.llmReqTotal
+		st.avgSent = s.llmSentBytesTotal / s.llmReqTotal
 	}
-	avgRecv := int64(0)
 	if s.llmRespTotal > 0 {
-		avgRecv = s.llmRespBytesTotal / s.llmRespTotal
+		st.avgRecv = s.llmRespBytesTotal / s.llmRespTotal
 	}
-	reqs, sentTot, recvTot := s.llmReqTotal, s.llmSentBytesTotal, s.llmRespBytesTotal
 	s.mu.RUnlock()
 	mins := time.Since(s.startTime).Minutes()
 	if mins <= 0 {
 		mins = 0.001
 	}
-	rpm := float64(reqs) / mins
-	sentPerMin := float64(sentTot) / mins
-	recvPerMin := float64(recvTot) / mins
-	logging.Logf("lsp ", "llm stats reqs=%d avg_sent=%d avg_recv=%d sent_total=%d recv_total=%d rpm=%.2f sent_per_min=%.0f recv_per_min=%.0f", reqs, avgSent, avgRecv, sentTot, recvTot, rpm, sentPerMin, recvPerMin)
+	st.rpm = float64(st.reqs) / mins
+	st.sentPerMin = float64(st.sentTotal) / mins
+	st.recvPerMin = float64(st.recvTotal) / mins
+	return st
 }
 
 // Completion prompt builders and filters
@@ -65,15 +101,27 @@ func inParamList(current string, cursor int) bool {
 	return open >= 0 && cursor > open && (close == -1 || cursor <= close)
 }
 
-func buildPrompts(inParams bool, p CompletionParams, above, current, below, funcCtx string) (string, string) {
+// buildPrompts renders the completion system and user prompts.
+// Receiver, fields and imports come from the language analyser, if any.
+func (s *Server) buildPrompts(inParams bool, p CompletionParams, above, current, below, funcCtx string) (string, string) {
+	cc := s.codeContext(p.TextDocument.URI, p.Position)
+	data := prompts.Data{
+		File:     p.TextDocument.URI,
+		Language: s.languageFor(p.TextDocument.URI),
+		Above:    above,
+		Current:  current,
+		Below:    below,
+		Cursor:   p.Position.Character,
+		Function: funcCtx,
+		Receiver: cc.receiver,
+		Fields:   cc.fields,
+		Imports:  cc.imports,
+	}
+	reg := s.config().prompts
 	if inParams {
-		sys := "You are a code completion engine for function signatures. Return only the parameter list contents (without parentheses), no braces, no prose. Prefer idiomatic names and types."
-		user := fmt.Sprintf("Cursor is inside the function parameter list. Suggest only the parameter list (no parentheses).\nFunction line: %s\nCurrent line (cursor at %d): %s", funcCtx, p.Position.Character, current)
-		return sys, user
+		return reg.Render(prompts.CompletionParamsSystem, data), reg.Render(prompts.CompletionParamsUser, data)
 	}
-	sys := "You are a terse code completion engine. Return only the code to insert, no surrounding prose or backticks. Only continue from the cursor; never repeat characters already present to the left of the cursor on the current line (e.g., if 'name :=' is already typed, only return the right-hand side expression)."
-	user := fmt.Sprintf("Provide the next likely code to insert at the cursor.\nFile: %s\nFunction/context: %s\nAbove line: %s\nCurrent line (cursor at character %d): %s\nBelow line: %s\nOnly return the completion snippet.", p.TextDocument.URI, funcCtx, above, p.Position.Character, current, below)
-	return sys, user
+	return reg.Render(prompts.CompletionSystem, data), reg.Render(prompts.CompletionUser, data)
 }
 
 func computeTextEditAndFilter(cleaned string, inParams bool, current string, p CompletionParams) (*TextEdit, string) {
@@ -343,7 +391,16 @@ func labelForCompletion(cleaned, filter string) string {
 }
 
 // extractRangeText returns the exact text within the given document range.
+// Both ends are clamped to the document, since ranges from code lenses or
+// the last cursor position may be stale.
 func extractRangeText(d *document, r Range) string {
+	if len(d.lines) == 0 {
+		return ""
+	}
+	r.Start, r.End = d.clampPosition(r.Start), d.clampPosition(r.End)
+	if r.Start.Line > r.End.Line {
+		return ""
+	}
 	if r.Start.Line == r.End.Line {
 		line := d.lines[r.Start.Line]
 		if r.Start.Character < 0 {
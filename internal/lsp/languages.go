@@ -0,0 +1,151 @@
+// Summary: Per-language overrides of context mode, trigger characters, max tokens, temperature and model, keyed by LSP languageId.
+package lsp
+
+import (
+	"path"
+	"strings"
+
+	"hexai/internal/llm"
+)
+
+// LanguageOptions overrides global settings for documents of one LSP
+// language id. Zero values keep the global setting.
+type LanguageOptions struct {
+	ContextMode       string
+	TriggerCharacters []string
+	MaxTokens         int
+	Temperature       *float64
+	Model             string
+}
+
+// langSettings are the effective settings for one document.
+type langSettings struct {
+	language     string
+	contextMode  string
+	triggerChars []string
+	maxTokens    int
+	temperature  *float64
+	model        string // empty uses the client's default model
+}
+
+// languageFor returns the language id of the document uri: the languageId
+// sent with didOpen, or the one of its file extension.
+func (s *Server) languageFor(uri string) string {
+	if d := s.getDocument(uri); d != nil {
+		return d.language()
+	}
+	return languageIDsByExt[path.Ext(uri)]
+}
+
+// settingsFor resolves the settings for the document uri: global values
+// overlaid with the options of its language (see languageFor).
+func (s *Server) settingsFor(uri string) langSettings {
+	lang := s.languageFor(uri)
+	cfg := s.config()
+	ls := langSettings{
+		language:     lang,
+		contextMode:  cfg.contextMode,
+		triggerChars: cfg.triggerChars,
+		maxTokens:    cfg.maxTokens,
+		temperature:  cfg.codingTemperature,
+	}
+	o, ok := cfg.languages[lang]
+	if !ok {
+		return ls
+	}
+	if o.ContextMode != "" {
+		ls.contextMode = o.ContextMode
+	}
+	if len(o.TriggerCharacters) > 0 {
+		ls.triggerChars = o.TriggerCharacters
+	}
+	if o.MaxTokens > 0 {
+		ls.maxTokens = o.MaxTokens
+	}
+	if o.Temperature != nil {
+		ls.temperature = o.Temperature
+	}
+	ls.model = o.Model
+	return ls
+}
+
+// modelFor returns the model for primary requests about uri: the model
+// chosen via hexai.switchModel, else the language's model, else "" for the
+// client's default.
+func (s *Server) modelFor(uri string) string {
+	s.mu.RLock()
+	model := s.modelOverride
+	s.mu.RUnlock()
+	if model != "" {
+		return model
+	}
+	return s.settingsFor(uri).model
+}
+
+// currentModelFor is currentModel for a request about uri, for logs and
+// completion details.
+func (s *Server) currentModelFor(uri string) string {
+	if model := s.modelFor(uri); model != "" {
+		return model
+	}
+	return s.currentModel()
+}
+
+// advertisedTriggerChars returns the global trigger characters plus those
+// of every language, since the client only reports characters announced at
+// initialize. isTriggerEvent then filters by the document's language.
+func (s *Server) advertisedTriggerChars() []string {
+	cfg := s.config()
+	seen := make(map[string]bool)
+	var out []string
+	add := func(chars []string) {
+		for _, c := range chars {
+			if !seen[c] {
+				seen[c] = true
+				out = append(out, c)
+			}
+		}
+	}
+	add(cfg.triggerChars)
+	for _, o := range cfg.languages {
+		add(o.TriggerCharacters)
+	}
+	return out
+}
+
+// normalizeLanguages lower-cases language ids and drops empty ones.
+func normalizeLanguages(in map[string]LanguageOptions) map[string]LanguageOptions {
+	out := make(map[string]LanguageOptions, len(in))
+	for lang, o := range in {
+		if lang = strings.ToLower(strings.TrimSpace(lang)); lang != "" {
+			o.ContextMode = strings.ToLower(strings.TrimSpace(o.ContextMode))
+			out[lang] = o
+		}
+	}
+	return out
+}
+
+// requestOpts builds the request options for these settings; the model is
+// added by the caller since it applies to the primary client only.
+func (ls langSettings) requestOpts() []llm.RequestOption {
+	opts := []llm.RequestOption{llm.WithMaxTokens(ls.maxTokens)}
+	if ls.temperature != nil {
+		opts = append(opts, llm.WithTemperature(*ls.temperature))
+	}
+	return opts
+}
+
+// usesContextMode reports whether mode is the global context mode or that
+// of any language.
+func (s *Server) usesContextMode(mode string) bool {
+	cfg := s.config()
+	if cfg.contextMode == mode {
+		return true
+	}
+	for _, o := range cfg.languages {
+		if o.ContextMode == mode {
+			return true
+		}
+	}
+	return false
+}
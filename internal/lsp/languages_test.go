@@ -0,0 +1,117 @@
+// Summary: Tests for per-language overrides of context mode, trigger characters, request options and model.
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"hexai/internal/llm"
+	"hexai/internal/prompts"
+)
+
+func TestSettingsFor_LanguageOverridesGlobal(t *testing.T) {
+	s := newTestServer()
+	setConfig(s, func(c *serverConfig) {
+		c.contextMode = "always-full"
+		c.triggerChars = []string{"."}
+		c.maxTokens = 500
+	})
+	temp := 0.7
+	setConfig(s, func(c *serverConfig) {
+		c.languages = normalizeLanguages(map[string]LanguageOptions{
+			"Markdown": {ContextMode: "minimal", TriggerCharacters: []string{"#"}, MaxTokens: 80, Temperature: &temp},
+		})
+	})
+	s.setDocument("file:///notes.md", "# Title\n")
+
+	md := s.settingsFor("file:///notes.md")
+	if md.language != "markdown" || md.contextMode != "minimal" || md.maxTokens != 80 || md.temperature == nil || *md.temperature != 0.7 {
+		t.Fatalf("unexpected markdown settings: %+v", md)
+	}
+	if len(md.triggerChars) != 1 || md.triggerChars[0] != "#" {
+		t.Fatalf("unexpected markdown trigger chars: %v", md.triggerChars)
+	}
+	goSettings := s.settingsFor("file:///main.go")
+	if goSettings.contextMode != "always-full" || goSettings.maxTokens != 500 || goSettings.temperature != nil {
+		t.Fatalf("go should keep global settings: %+v", goSettings)
+	}
+	if ctx, ok := s.buildAdditionalContext(false, "file:///notes.md", Position{}); ok || ctx != "" {
+		t.Fatalf("markdown should use minimal context; got %q", ctx)
+	}
+	got := s.advertisedTriggerChars()
+	if len(got) != 2 || got[0] != "." || got[1] != "#" {
+		t.Fatalf("expected union of trigger chars, got %v", got)
+	}
+}
+
+func TestIsTriggerEvent_UsesLanguageTriggerChars(t *testing.T) {
+	s := newTestServer()
+	setConfig(s, func(c *serverConfig) {
+		c.triggerChars = []string{"."}
+		c.languages = normalizeLanguages(map[string]LanguageOptions{"markdown": {TriggerCharacters: []string{"#"}}})
+	})
+	params := func(uri, ch string) CompletionParams {
+		return CompletionParams{
+			TextDocument: TextDocumentIdentifier{URI: uri}, Position: Position{Character: 1},
+			Context: map[string]any{"triggerKind": 2, "triggerCharacter": ch},
+		}
+	}
+	if !s.isTriggerEvent(params("file:///a.md", "#"), "#") || s.isTriggerEvent(params("file:///a.md", "."), ".") {
+		t.Fatalf("markdown should trigger on '#' only")
+	}
+	if !s.isTriggerEvent(params("file:///a.go", "."), ".") || s.isTriggerEvent(params("file:///a.go", "#"), "#") {
+		t.Fatalf("go should trigger on '.' only")
+	}
+}
+
+func TestLanguageModel_SwitchModelTakesPrecedence(t *testing.T) {
+	s := newTestServer()
+	fake := &modelRecordingLLM{}
+	setConfig(s, func(c *serverConfig) {
+		c.llmClient = fake
+		c.languages = normalizeLanguages(map[string]LanguageOptions{"go": {Model: "code-model"}})
+	})
+	for _, uri := range []string{"file:///a.go", "file:///a.py"} {
+		if _, err := s.completionChat(context.Background(), uri, []llm.Message{{Role: "user", Content: "x"}}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.modelOverride = "big-model"
+	if _, err := s.completionChat(context.Background(), "file:///a.go", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.models) != 3 || fake.models[0] != "code-model" || fake.models[1] != "" || fake.models[2] != "big-model" {
+		t.Fatalf("unexpected requested models: %v", fake.models)
+	}
+}
+
+func TestPromptData_CarriesLanguage(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{prompts.CompletionUser, prompts.RewriteUser, prompts.HoverUser} {
+		if err := os.WriteFile(filepath.Join(dir, name+".tmpl"), []byte("lang={{.Language}}"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := newTestServer()
+	setConfig(s, func(c *serverConfig) { c.prompts = prompts.Load(dir) })
+	s.setDocument("file:///a.py", "x = 1\n")
+	p := CompletionParams{TextDocument: TextDocumentIdentifier{URI: "file:///a.py"}}
+	if _, user := s.buildPrompts(false, p, "", "x = 1", "", ""); user != "lang=python" {
+		t.Fatalf("completion prompt without language: %q", user)
+	}
+	fake := &chatRecordingLLM{resp: "y = 2"}
+	setConfig(s, func(c *serverConfig) { c.llmClient = fake })
+	ca := s.buildRewriteCodeAction(CodeActionParams{TextDocument: p.TextDocument}, "x = 1 # double it")
+	if ca == nil {
+		t.Fatalf("expected a rewrite action")
+	}
+	s.resolveCodeAction(context.Background(), *ca)
+	if len(fake.msgs) != 1 || fake.msgs[0][len(fake.msgs[0])-1].Content != "lang=python" {
+		t.Fatalf("rewrite prompt without language: %#v", fake.msgs)
+	}
+	if _, err := s.explainExpression(s.config(), s.getDocument("file:///a.py"), "x", 0); err != nil || fake.msgs[1][1].Content != "lang=python" {
+		t.Fatalf("hover prompt without language: %#v (%v)", fake.msgs, err)
+	}
+}
@@ -7,8 +7,12 @@ import (
 
 // Ensure a visible busy item is returned when a prior LLM request is in flight.
 func TestLLMBusy_YieldsBusyCompletionItem(t *testing.T) {
-	s := &Server{maxTokens: 32, triggerChars: []string{"."}, compCache: make(map[string]string)}
-	s.llmClient = &countingLLM{}
+	s := &Server{compCache: make(map[string]string)}
+	setConfig(s, func(c *serverConfig) {
+		c.maxTokens = 32
+		c.triggerChars = []string{"."}
+		c.llmClient = &countingLLM{}
+	})
 	// Mark busy
 	s.setLLMBusy(true)
 	t.Cleanup(func() { s.setLLMBusy(false) })
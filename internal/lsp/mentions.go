@@ -0,0 +1,166 @@
+// Summary: @-mentions in prompts (@path/to/file.go, @symbol, @selection) resolved to file contents or declaration snippets and attached as context messages.
+package lsp
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	"hexai/internal/llm"
+	"hexai/internal/logging"
+)
+
+// mentionSelection refers to the last non-empty selection seen by the server.
+const mentionSelection = "selection"
+
+// findMentions returns the distinct @-mentions in text, in order. A mention
+// starts at "@" at the beginning of text or after whitespace or an opening
+// bracket or quote (so e-mail addresses are not mentions); trailing
+// punctuation is not part of it.
+func findMentions(text string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for i := 0; i < len(text); i++ {
+		if text[i] != '@' || (i > 0 && !strings.ContainsRune(" \t\n([{\"'`", rune(text[i-1]))) {
+			continue
+		}
+		j := i + 1
+		for j < len(text) && isMentionChar(text[j]) {
+			j++
+		}
+		name := strings.TrimRight(text[i+1:j], ".,:;-")
+		if name != "" && !seen[name] {
+			seen[name] = true
+			out = append(out, name)
+		}
+		i = j - 1
+	}
+	return out
+}
+
+func isMentionChar(c byte) bool {
+	return isIdentChar(c) || c == '.' || c == '/' || c == '-'
+}
+
+// isPathMention reports whether a mention names a file rather than a symbol.
+func isPathMention(name string) bool {
+	return strings.Contains(name, "/") || strings.HasPrefix(name, ".")
+}
+
+// mentionMessages resolves the @-mentions in text, written in the document
+// uri, to system messages: files are read like /file, symbols become the
+// snippet of their declaration and @selection the last selected text.
+// Files outside the workspace are only read when mentioned in typed, the
+// text the user just typed, as text already in the document may come from
+// a cloned repository. Mentions that cannot be resolved are logged and
+// left out.
+func (s *Server) mentionMessages(uri, text, typed string) []llm.Message {
+	var out []llm.Message
+	typedNames := findMentions(typed)
+	for _, name := range findMentions(text) {
+		msg, ok := s.resolveMention(uri, name, slices.Contains(typedNames, name))
+		if !ok {
+			logging.Logf("lsp ", "mention @%s in %s not resolved", name, uri)
+			continue
+		}
+		out = append(out, msg)
+	}
+	return out
+}
+
+func (s *Server) resolveMention(uri, name string, typed bool) (llm.Message, bool) {
+	if name == mentionSelection {
+		return s.selectionMessage()
+	}
+	// "calc.go" may name a file next to uri or a method like "s.Run"
+	if text, err := s.chatFile(uri, name, typed); err == nil {
+		return s.fileMessage(name, text), true
+	} else if isPathMention(name) {
+		logging.Logf("lsp ", "mention @%s: %v", name, err)
+		return llm.Message{}, false
+	}
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		name = name[i+1:] // "Server.Run" is declared as "Run"
+	}
+	return s.declarationMessage(uri, name)
+}
+
+// selectionMessage returns the text of the last non-empty selection.
+func (s *Server) selectionMessage() (llm.Message, bool) {
+	s.mu.RLock()
+	uri, r := s.lastSelectionURI, s.lastSelection
+	s.mu.RUnlock()
+	if uri == "" || s.excluded(uri) {
+		return llm.Message{}, false
+	}
+	d := s.getDocument(uri)
+	if d == nil || r.End.Line >= len(d.lines) {
+		return llm.Message{}, false
+	}
+	text := extractRangeText(d, r)
+	if strings.TrimSpace(text) == "" {
+		return llm.Message{}, false
+	}
+	return llm.Message{Role: "system", Content: "Selected code in " + uriToPath(uri) + ":\n" + text}, true
+}
+
+// declarationMessage returns the declaration of name from the document uri
+// or, failing that, the other files crossFileSources offers, checked in
+// path order.
+func (s *Server) declarationMessage(uri, name string) (llm.Message, bool) {
+	d := s.getDocument(uri)
+	if d == nil {
+		return llm.Message{}, false
+	}
+	if snippet, ok := findDeclaration(d.lines, name); ok {
+		return declMessage(name, uriToPath(uri), snippet), true
+	}
+	sources := s.crossFileSources(d)
+	paths := make([]string, 0, len(sources))
+	for path := range sources {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if snippet, ok := findDeclaration(sources[path].load(), name); ok {
+			return declMessage(name, path, snippet), true
+		}
+	}
+	return llm.Message{}, false
+}
+
+func declMessage(name, path, snippet string) llm.Message {
+	return llm.Message{Role: "system", Content: "Declaration of " + name + " in " + path + ":\n" + snippet}
+}
+
+// findDeclaration returns the first function or type declaration of name in
+// lines, capped at crossFileChunkLines lines.
+func findDeclaration(lines []string, name string) (string, bool) {
+	for i, line := range lines {
+		if declaresName(strings.TrimSpace(line), name) {
+			end := declarationEnd(lines, i, crossFileChunkLines)
+			return strings.Join(lines[i:end+1], "\n"), true
+		}
+	}
+	return "", false
+}
+
+// declaresName reports whether the trimmed line declares name, skipping the
+// receiver of Go methods ("func (s *Server) Run(").
+func declaresName(trimmed, name string) bool {
+	kw := declKeyword(trimmed)
+	if kw == "" {
+		return false
+	}
+	rest := trimmed[strings.Index(trimmed, kw)+len(kw):]
+	if kw == "func " && strings.HasPrefix(rest, "(") {
+		if i := strings.IndexByte(rest, ')'); i >= 0 {
+			rest = strings.TrimSpace(rest[i+1:])
+		}
+	}
+	if !strings.HasPrefix(rest, name) {
+		return false
+	}
+	rest = rest[len(name):]
+	return rest == "" || !isIdentChar(rest[0])
+}
@@ -0,0 +1,126 @@
+// Summary: Tests for @-mentions of files, symbols and the selection in prompts.
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindMentions(t *testing.T) {
+	got := findMentions("refactor like @internal/calc.go, use @Server.Run and @selection? mail me@example.com (@calc.go)")
+	want := []string{"internal/calc.go", "Server.Run", "selection", "calc.go"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %q got %q", want, got)
+	}
+}
+
+func TestMentionMessages_FileSymbolAndSelection(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "calc.go"), []byte("package calc\n\nfunc Add(a, b int) int { return a + b }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer()
+	s.root = root
+	s.setDocument("file:///b.go", "package b\n\n// Sum adds.\nfunc (c *Calc) Sum(v []int) (n int) {\n\tfor _, x := range v {\n\t\tn += x\n\t}\n\treturn n\n}\n\nfunc Other() {}\n")
+	uri := "file:///a.go"
+	s.setDocument(uri, "package a\n\nvar total = 1\n")
+	s.noteCursor(uri, Range{Start: Position{Line: 2}, End: Position{Line: 2, Character: 9}})
+	s.noteCursor(uri, Range{Start: Position{Line: 0}, End: Position{Line: 0}})
+
+	msgs := s.mentionMessages(uri, "do it like @calc.go using @Calc.Sum on @selection, not @Missing", "")
+	if len(msgs) != 3 {
+		t.Fatalf("expected three messages, got %#v", msgs)
+	}
+	if !strings.Contains(msgs[0].Content, "Contents of calc.go:\npackage calc") {
+		t.Fatalf("unexpected file message %q", msgs[0].Content)
+	}
+	wantDecl := "Declaration of Sum in /b.go:\nfunc (c *Calc) Sum(v []int) (n int) {\n\tfor _, x := range v {\n\t\tn += x\n\t}\n\treturn n\n}"
+	if msgs[1].Content != wantDecl {
+		t.Fatalf("unexpected declaration:\n%q\nwant\n%q", msgs[1].Content, wantDecl)
+	}
+	if msgs[2].Content != "Selected code in /a.go:\nvar total" {
+		t.Fatalf("unexpected selection %q", msgs[2].Content)
+	}
+}
+
+func TestMentionMessages_RespectsNoDiskIOAndIgnoreRules(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "calc.go"), []byte("package calc\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer()
+	s.root = root
+	setConfig(s, func(c *serverConfig) { c.noDiskIO = true })
+	if msgs := s.mentionMessages("file:///a.go", "like @./calc.go", ""); len(msgs) != 0 {
+		t.Fatalf("no_disk_io must not read files, got %#v", msgs)
+	}
+}
+
+func TestMentionMessages_FilesOutsideWorkspaceNeedTypedMention(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "repo")
+	secret := filepath.Join(dir, "secret.txt")
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(secret, []byte("token"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer()
+	s.root = root
+	uri := pathToURI(filepath.Join(root, "notes.md"))
+	for _, text := range []string{"see @../secret.txt", "see @" + secret, "see @./sub/../../secret.txt"} {
+		if msgs := s.mentionMessages(uri, text, ""); len(msgs) != 0 {
+			t.Fatalf("%q escaped the workspace: %#v", text, msgs)
+		}
+	}
+	if msgs := s.mentionMessages(uri, "see @../secret.txt", "and @../secret.txt"); len(msgs) != 1 {
+		t.Fatalf("a typed mention may leave the workspace, got %#v", msgs)
+	}
+
+	// an earlier question in a cloned file must not pull in the secret
+	s.setDocument(uri, "What is @"+secret+"?\n\n> A file.\n\nAnd now?>")
+	_, extra, _, err := s.chatContext(s.getDocument(uri), 4, plainReplyStyle, "And now?")
+	if err != nil || len(extra) != 0 {
+		t.Fatalf("expected no attachments, got %#v (%v)", extra, err)
+	}
+}
+
+func TestChatContext_AttachesMentions(t *testing.T) {
+	s := newTestServer()
+	s.setDocument("file:///calc.go", "package calc\n\nfunc Add(a, b int) int { return a + b }\n")
+	uri := "file:///notes.md"
+	s.setDocument(uri, "How does @Add work?>")
+	_, extra, prompt, err := s.chatContext(s.getDocument(uri), 0, plainReplyStyle, "How does @Add work?")
+	if err != nil || len(extra) != 1 || extra[0].Content != "Declaration of Add in /calc.go:\nfunc Add(a, b int) int { return a + b }" {
+		t.Fatalf("expected the declaration, got %#v (%v)", extra, err)
+	}
+	if prompt != "How does @Add work?" {
+		t.Fatalf("the question must keep its mentions, got %q", prompt)
+	}
+}
+
+func TestBuildCompletionMessages_InlinePromptMentions(t *testing.T) {
+	s := newTestServer()
+	s.setDocument("file:///calc.go", "package calc\n\nfunc Add(a, b int) int { return a + b }\n")
+	s.setDocument("file:///a.go", "package a\n\n;write Sub like @Add;\n")
+	p := CompletionParams{TextDocument: TextDocumentIdentifier{URI: "file:///a.go"}}
+	msgs := s.buildCompletionMessages(true, false, "", false, p, "", ";write Sub like @Add;", "", "")
+	if len(msgs) != 3 || msgs[1].Role != "system" || !strings.Contains(msgs[1].Content, "Declaration of Add") || msgs[2].Role != "user" {
+		t.Fatalf("expected the declaration after the system prompt, got %#v", msgs)
+	}
+}
+
+func TestChatContext_FollowUpKeepsMentions(t *testing.T) {
+	s := newTestServer()
+	s.setDocument("file:///calc.go", "package calc\n\nfunc Add(a, b int) int { return a + b }\n")
+	uri := "file:///notes.md"
+	s.setDocument(uri, "What does @Add do?\n\n> It adds.\n\nAnd for negatives?>")
+	_, extra, _, err := s.chatContext(s.getDocument(uri), 4, plainReplyStyle, "And for negatives?")
+	if err != nil || len(extra) != 1 || !strings.Contains(extra[0].Content, "Declaration of Add") {
+		t.Fatalf("expected the earlier mention, got %#v (%v)", extra, err)
+	}
+}
@@ -0,0 +1,115 @@
+// Summary: Editor-visible status: window/logMessage warnings, configuration problems, and cancellable work-done progress for long LLM requests.
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"hexai/internal/logging"
+)
+
+// logMessage sends a window/logMessage notification; editors show these in
+// their LSP log rather than as a popup.
+func (s *Server) logMessage(typ int, msg string) {
+	b, _ := json.Marshal(ShowMessageParams{Type: typ, Message: msg})
+	s.writeMessage(Request{JSONRPC: "2.0", Method: "window/logMessage", Params: b})
+}
+
+// warnf logs a warning to the hexai log and the editor's LSP log.
+func (s *Server) warnf(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	logging.Logf("lsp ", "%s", msg)
+	s.logMessage(messageWarning, "Hexai: "+msg)
+}
+
+// llmError reports a failed LLM request for what (e.g. "chat"). Requests
+// the user cancelled are only logged.
+func (s *Server) llmError(what string, err error) {
+	if errors.Is(err, context.Canceled) {
+		logging.Logf("lsp ", "%s llm cancelled", what)
+		return
+	}
+	s.warnf("%s llm error: %v", what, err)
+}
+
+// reportConfigProblems sends the configuration warnings to the editor's
+// log and, with fatal, shows the fatal configuration problem.
+func (s *Server) reportConfigProblems(fatal bool) {
+	cfg := s.config()
+	cfgErr, warnings := cfg.configError, cfg.configWarnings
+	if fatal && cfgErr != "" {
+		logging.Logf("lsp ", "%s", cfgErr)
+		s.showMessage(messageError, cfgErr)
+	}
+	for _, w := range warnings {
+		s.warnf("%s", w)
+	}
+}
+
+// beginProgress shows work-done progress titled title while a long request
+// runs, if the client supports it. The returned context is cancelled when
+// the user cancels the progress; end finishes the progress and must be
+// called in all cases.
+func (s *Server) beginProgress(parent context.Context, title string) (ctx context.Context, end func()) {
+	ctx, cancel := context.WithCancel(parent)
+	s.mu.RLock()
+	supported := s.workDoneProgress
+	s.mu.RUnlock()
+	if !supported {
+		return ctx, cancel
+	}
+	token := "hexai-progress-" + string(s.nextReqID())
+	cctx, ccancel := context.WithTimeout(ctx, 2*time.Second)
+	_, err := s.callClient(cctx, "window/workDoneProgress/create", WorkDoneProgressCreateParams{Token: token})
+	ccancel()
+	if err != nil {
+		logging.Logf("lsp ", "progress create error: %v", err)
+		return ctx, cancel
+	}
+	s.mu.Lock()
+	if s.progress == nil {
+		s.progress = make(map[string]context.CancelFunc)
+	}
+	s.progress[token] = cancel
+	s.mu.Unlock()
+	s.sendProgress(token, WorkDoneProgressValue{Kind: "begin", Title: title, Cancellable: true})
+	return ctx, func() {
+		s.mu.Lock()
+		delete(s.progress, token)
+		s.mu.Unlock()
+		msg := ""
+		if errors.Is(ctx.Err(), context.Canceled) {
+			msg = "cancelled"
+		}
+		s.sendProgress(token, WorkDoneProgressValue{Kind: "end", Message: msg})
+		cancel()
+	}
+}
+
+func (s *Server) sendProgress(token string, v WorkDoneProgressValue) {
+	b, _ := json.Marshal(ProgressParams{Token: token, Value: v})
+	s.writeMessage(Request{JSONRPC: "2.0", Method: "$/progress", Params: b})
+}
+
+// handleProgressCancel cancels the request behind a progress the user
+// cancelled in the editor.
+func (s *Server) handleProgressCancel(req Request) {
+	var p WorkDoneProgressCancelParams
+	if err := json.Unmarshal(req.Params, &p); err != nil {
+		return
+	}
+	var token string
+	if err := json.Unmarshal(p.Token, &token); err != nil {
+		token = string(p.Token) // numeric token
+	}
+	s.mu.Lock()
+	cancel := s.progress[token]
+	s.mu.Unlock()
+	if cancel != nil {
+		logging.Logf("lsp ", "progress %s cancelled by the user", token)
+		cancel()
+	}
+}
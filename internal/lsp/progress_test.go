@@ -0,0 +1,143 @@
+// Summary: Tests for configuration problem reporting, logMessage warnings and cancellable work-done progress.
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"hexai/internal/llm"
+)
+
+// clientPipe connects s.out to a fake client that answers every server
+// request with a null result and forwards all messages to the channel.
+func clientPipe(t *testing.T, s *Server) <-chan map[string]json.RawMessage {
+	t.Helper()
+	pr, pw := io.Pipe()
+	t.Cleanup(func() { pw.Close() })
+	s.out = pw
+	out := make(chan map[string]json.RawMessage, 32)
+	go func() {
+		r := &Server{in: bufio.NewReader(pr)}
+		for {
+			body, err := r.readMessage()
+			if err != nil {
+				return
+			}
+			var m map[string]json.RawMessage
+			_ = json.Unmarshal(body, &m)
+			out <- m
+			if len(m["id"]) > 0 && len(m["method"]) > 0 {
+				s.deliverResponse([]byte(`{"jsonrpc":"2.0","id":` + string(m["id"]) + `,"result":null}`))
+			}
+		}
+	}()
+	return out
+}
+
+// nextMethod returns the next message with the given method.
+func nextMethod(t *testing.T, msgs <-chan map[string]json.RawMessage, method string) map[string]json.RawMessage {
+	t.Helper()
+	for {
+		select {
+		case m := <-msgs:
+			if string(m["method"]) == `"`+method+`"` {
+				return m
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for %s", method)
+		}
+	}
+}
+
+// blockingLLM answers only when the request context ends.
+type blockingLLM struct{}
+
+func (blockingLLM) Chat(ctx context.Context, _ []llm.Message, _ ...llm.RequestOption) (string, error) {
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+func (blockingLLM) Name() string         { return "fake" }
+func (blockingLLM) DefaultModel() string { return "m" }
+
+func TestInitialized_ReportsConfigProblems(t *testing.T) {
+	var buf bytes.Buffer
+	s := newTestServer()
+	s.out = &buf
+	setConfig(s, func(c *serverConfig) {
+		c.configError = "Hexai: LLM disabled: missing OPENAI_API_KEY"
+		c.configWarnings = []string{"embeddings disabled: boom"}
+	})
+	s.handleInitialized()
+	msgs := readAllMessages(t, &buf)
+	if got := shownMessages(msgs); len(got) != 1 || got[0] != s.config().configError {
+		t.Fatalf("expected the config error to be shown, got %v", got)
+	}
+	var logged []ShowMessageParams
+	for _, m := range msgs {
+		if string(m["method"]) == `"window/logMessage"` {
+			var p ShowMessageParams
+			_ = json.Unmarshal(m["params"], &p)
+			logged = append(logged, p)
+		}
+	}
+	if len(logged) != 1 || logged[0].Type != messageWarning || logged[0].Message != "Hexai: embeddings disabled: boom" {
+		t.Fatalf("expected the warning in window/logMessage, got %+v", logged)
+	}
+}
+
+func TestResolveCodeAction_ProgressCancelStopsRequest(t *testing.T) {
+	s := newTestServer()
+	setConfig(s, func(c *serverConfig) { c.llmClient = blockingLLM{} })
+	s.workDoneProgress = true
+	msgs := clientPipe(t, s)
+	p := CodeActionParams{TextDocument: TextDocumentIdentifier{URI: "file:///t.go"}}
+	ca := s.buildRewriteCodeAction(p, ";rewrite;\nold code")
+	done := make(chan bool, 1)
+	go func() {
+		_, ok := s.resolveCodeAction(context.Background(), *ca)
+		done <- ok
+	}()
+	nextMethod(t, msgs, "window/workDoneProgress/create")
+	var begin struct {
+		Token string                `json:"token"`
+		Value WorkDoneProgressValue `json:"value"`
+	}
+	_ = json.Unmarshal(nextMethod(t, msgs, "$/progress")["params"], &begin)
+	if begin.Value.Kind != "begin" || !begin.Value.Cancellable || begin.Value.Title != ca.Title {
+		t.Fatalf("unexpected progress begin: %+v", begin)
+	}
+	token, _ := json.Marshal(begin.Token)
+	params, _ := json.Marshal(WorkDoneProgressCancelParams{Token: token})
+	s.handleProgressCancel(Request{Params: params})
+	select {
+	case ok := <-done:
+		if ok {
+			t.Fatalf("cancelled action must not resolve")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("cancel did not stop the request")
+	}
+	// A warning would precede the end of the progress
+	for {
+		m := <-msgs
+		if string(m["method"]) == `"window/logMessage"` {
+			t.Fatalf("cancelled requests must not be reported as warnings: %s", m["params"])
+		}
+		if string(m["method"]) != `"$/progress"` {
+			continue
+		}
+		var end struct {
+			Value WorkDoneProgressValue `json:"value"`
+		}
+		_ = json.Unmarshal(m["params"], &end)
+		if end.Value.Kind != "end" || end.Value.Message != "cancelled" {
+			t.Fatalf("unexpected progress end: %+v", end.Value)
+		}
+		return
+	}
+}
@@ -0,0 +1,319 @@
+// Summary: LLM code review on textDocument/didSave; reviews changed lines and publishes findings as diagnostics.
+package lsp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"hexai/internal/llm"
+	"hexai/internal/logging"
+	"hexai/internal/prompts"
+)
+
+const (
+	reviewSource    = "hexai"
+	reviewMaxLines  = 200 // changed lines sent per review
+	reviewCacheSize = 32
+)
+
+// pendingReview is a debounced or running review that the next change to
+// the document cancels.
+type pendingReview struct {
+	timer  *time.Timer
+	cancel context.CancelFunc
+}
+
+// reviewSnapshot is the document content of the last published review and
+// its findings. Findings on lines unchanged since then are carried over.
+type reviewSnapshot struct {
+	lines []string
+	diags []Diagnostic
+}
+
+func (s *Server) handleDidSave(req Request) {
+	var p DidSaveTextDocumentParams
+	if err := json.Unmarshal(req.Params, &p); err != nil {
+		return
+	}
+	d := s.getDocument(p.TextDocument.URI)
+	if d == nil {
+		return
+	}
+	s.markLexicalStale(d.uri)
+	s.updateVectorIndex(d)
+	if s.reviewEnabled(d) {
+		s.scheduleReview(d.uri)
+	}
+}
+
+func (s *Server) reviewEnabled(d *document) bool {
+	cfg := s.config()
+	return cfg.llmClient != nil && cfg.reviewLanguages[d.language()] && !s.excluded(d.uri)
+}
+
+// scheduleReview (re)starts the debounce timer for uri, cancelling any
+// review still pending or running for it.
+func (s *Server) scheduleReview(uri string) {
+	s.cancelReview(uri)
+	ctx, cancel := context.WithCancel(context.Background())
+	pr := &pendingReview{cancel: cancel}
+	debounce := s.config().reviewDebounce
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.reviews == nil {
+		s.reviews = make(map[string]*pendingReview)
+	}
+	pr.timer = time.AfterFunc(debounce, func() { s.runReview(ctx, uri, pr) })
+	s.reviews[uri] = pr
+}
+
+// cancelReview stops a pending or running review for uri; its findings are
+// never published.
+func (s *Server) cancelReview(uri string) {
+	s.mu.Lock()
+	pr := s.reviews[uri]
+	delete(s.reviews, uri)
+	s.mu.Unlock()
+	if pr != nil {
+		pr.timer.Stop()
+		pr.cancel()
+	}
+}
+
+// forgetReview drops all review state of a closed document and clears its
+// diagnostics in the client.
+func (s *Server) forgetReview(uri string) {
+	s.cancelReview(uri)
+	s.mu.Lock()
+	_, had := s.reviewed[uri]
+	delete(s.reviewed, uri)
+	s.mu.Unlock()
+	if had {
+		s.publishDiagnostics(uri, nil, []Diagnostic{})
+	}
+}
+
+func (s *Server) runReview(ctx context.Context, uri string, pr *pendingReview) {
+	defer func() {
+		s.mu.Lock()
+		if s.reviews[uri] == pr {
+			delete(s.reviews, uri)
+		}
+		s.mu.Unlock()
+		pr.cancel()
+	}()
+	d := s.getDocument(uri)
+	if d == nil {
+		return
+	}
+	key := contentHash(d.Text())
+	diags, ok := s.reviewCacheGet(key)
+	if !ok {
+		var err error
+		if diags, err = s.reviewChanges(ctx, d); err != nil {
+			if ctx.Err() == nil {
+				s.llmError("review", err)
+			}
+			return
+		}
+		s.reviewCachePut(key, diags)
+	}
+	if ctx.Err() != nil || s.getDocument(uri) != d {
+		return // changed while the review was running
+	}
+	s.mu.Lock()
+	if s.reviewed == nil {
+		s.reviewed = make(map[string]reviewSnapshot)
+	}
+	s.reviewed[uri] = reviewSnapshot{lines: d.lines, diags: diags}
+	s.mu.Unlock()
+	version := d.version
+	s.publishDiagnostics(uri, &version, diags)
+}
+
+// reviewChanges asks the LLM to review the lines changed since the last
+// published review (the whole document the first time) and returns the
+// complete finding list for d.
+func (s *Server) reviewChanges(ctx context.Context, d *document) ([]Diagnostic, error) {
+	cfg := s.config()
+	if cfg.llmClient == nil {
+		return nil, errLLMDisabled
+	}
+	s.mu.RLock()
+	base, hasBase := s.reviewed[d.uri]
+	s.mu.RUnlock()
+	from, to, kept := 0, len(d.lines), []Diagnostic{}
+	if hasBase {
+		var oldTo int
+		from, oldTo, to = changedLines(base.lines, d.lines)
+		kept = carryDiagnostics(base.diags, from, oldTo, to-oldTo)
+	}
+	if from >= to {
+		return kept, nil
+	}
+	to = min(to, from+reviewMaxLines)
+	var numbered strings.Builder
+	for i := from; i < to; i++ {
+		fmt.Fprintf(&numbered, "%d: %s\n", i+1, d.lines[i])
+	}
+	data := prompts.Data{File: d.uri, Language: d.language(), Selection: numbered.String()}
+	msgs := []llm.Message{
+		{Role: "system", Content: cfg.prompts.Render(prompts.ReviewSystem, data)},
+		{Role: "user", Content: cfg.prompts.Render(prompts.ReviewUser, data)},
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	logging.Logf("lsp ", "review llm=requesting uri=%s lines=%d-%d model=%s", d.uri, from+1, to, s.currentModelFor(d.uri))
+	text, err := cfg.llmClient.Chat(ctx, msgs, s.llmRequestOpts(d.uri)...)
+	if err != nil {
+		return nil, err
+	}
+	return append(kept, parseReviewFindings(text, d.lines, from, to)...), nil
+}
+
+// changedLines compares two versions of a document by common prefix and
+// suffix. Lines [from, oldTo) of old were replaced by [from, newTo) of new.
+func changedLines(old, new []string) (from, oldTo, newTo int) {
+	for from < len(old) && from < len(new) && old[from] == new[from] {
+		from++
+	}
+	oldTo, newTo = len(old), len(new)
+	for oldTo > from && newTo > from && old[oldTo-1] == new[newTo-1] {
+		oldTo--
+		newTo--
+	}
+	return from, oldTo, newTo
+}
+
+// carryDiagnostics keeps findings outside the changed lines [from, oldTo),
+// shifting those below the change by delta lines.
+func carryDiagnostics(diags []Diagnostic, from, oldTo, delta int) []Diagnostic {
+	out := []Diagnostic{}
+	for _, dg := range diags {
+		switch {
+		case dg.Range.End.Line < from:
+			out = append(out, dg)
+		case dg.Range.Start.Line >= oldTo:
+			dg.Range.Start.Line += delta
+			dg.Range.End.Line += delta
+			out = append(out, dg)
+		}
+	}
+	return out
+}
+
+// parseReviewFindings parses "LINE[-END] | severity | code | message" lines.
+// Findings outside the reviewed lines [from, to) are dropped.
+func parseReviewFindings(text string, lines []string, from, to int) []Diagnostic {
+	var out []Diagnostic
+	for _, raw := range splitLines(stripCodeFences(text)) {
+		parts := strings.SplitN(raw, "|", 4)
+		if len(parts) != 4 {
+			continue
+		}
+		startStr, endStr, _ := strings.Cut(strings.TrimSpace(parts[0]), "-")
+		start, err := strconv.Atoi(strings.TrimSpace(startStr))
+		if err != nil {
+			continue
+		}
+		end := start
+		if n, err := strconv.Atoi(strings.TrimSpace(endStr)); err == nil && n >= start {
+			end = n
+		}
+		start, end = start-1, min(end-1, to-1)
+		msg := strings.TrimSpace(parts[3])
+		if start < from || start >= to || msg == "" {
+			continue
+		}
+		code := strings.TrimSpace(parts[2])
+		if code == "" {
+			code = "review"
+		}
+		out = append(out, Diagnostic{
+			Range: Range{
+				Start: Position{Line: start, Character: len(leadingIndent(lines[start]))},
+				End:   Position{Line: end, Character: len(lines[end])},
+			},
+			Severity: reviewSeverity(parts[1]),
+			Code:     code,
+			Source:   reviewSource,
+			Message:  msg,
+		})
+	}
+	return out
+}
+
+// reviewSeverity maps a severity word to the LSP DiagnosticSeverity.
+func reviewSeverity(word string) int {
+	switch strings.ToLower(strings.TrimSpace(word)) {
+	case "error":
+		return 1
+	case "info", "information":
+		return 3
+	case "hint":
+		return 4
+	}
+	return 2
+}
+
+func (s *Server) publishDiagnostics(uri string, version *int, diags []Diagnostic) {
+	b, _ := json.Marshal(PublishDiagnosticsParams{URI: uri, Version: version, Diagnostics: diags})
+	s.writeMessage(Request{JSONRPC: "2.0", Method: "textDocument/publishDiagnostics", Params: b})
+}
+
+// buildReviewFixActions returns one quick fix per review finding in the
+// code action context that overlaps the requested range. The fixes resolve
+// through the "diagnostics" action on the finding's lines.
+func (s *Server) buildReviewFixActions(p CodeActionParams, d *document) []CodeAction {
+	var out []CodeAction
+	for _, dg := range s.diagnosticsInRange(p.Context, p.Range) {
+		if dg.Source != reviewSource || dg.Range.Start.Line < 0 || dg.Range.End.Line >= len(d.lines) {
+			continue
+		}
+		r := Range{
+			Start: Position{Line: dg.Range.Start.Line},
+			End:   Position{Line: dg.Range.End.Line, Character: len(d.lines[dg.Range.End.Line])},
+		}
+		title := "Hexai: fix " + strings.TrimSpace(fmt.Sprint(dg.Code))
+		out = append(out, *s.buildCodeActionWithPayload(title, "quickfix", codeActionPayload{
+			Type: "diagnostics", URI: p.TextDocument.URI, Range: r,
+			Selection: extractRangeText(d, r), Diagnostics: []Diagnostic{dg},
+		}))
+	}
+	return out
+}
+
+func contentHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Server) reviewCacheGet(key string) ([]Diagnostic, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.reviewCache[key]
+	return v, ok
+}
+
+// reviewCachePut stores findings, evicting the oldest entry when full.
+func (s *Server) reviewCachePut(key string, diags []Diagnostic) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.reviewCache == nil {
+		s.reviewCache = make(map[string][]Diagnostic)
+	}
+	if _, exists := s.reviewCache[key]; !exists {
+		s.reviewCacheOrder = append(s.reviewCacheOrder, key)
+		if len(s.reviewCacheOrder) > reviewCacheSize {
+			delete(s.reviewCache, s.reviewCacheOrder[0])
+			s.reviewCacheOrder = s.reviewCacheOrder[1:]
+		}
+	}
+	s.reviewCache[key] = diags
+}
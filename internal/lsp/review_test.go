@@ -0,0 +1,108 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestChangedLines_PrefixSuffixDiff(t *testing.T) {
+	old := []string{"a", "b", "c", "d"}
+	cur := []string{"a", "x", "y", "c", "d"}
+	from, oldTo, newTo := changedLines(old, cur)
+	if from != 1 || oldTo != 2 || newTo != 3 {
+		t.Fatalf("got from=%d oldTo=%d newTo=%d", from, oldTo, newTo)
+	}
+	kept := carryDiagnostics([]Diagnostic{
+		{Range: *rangeOf(0, 0, 0, 1), Message: "above"},
+		{Range: *rangeOf(1, 0, 1, 1), Message: "changed"},
+		{Range: *rangeOf(3, 0, 3, 1), Message: "below"},
+	}, from, oldTo, newTo-oldTo)
+	if len(kept) != 2 || kept[0].Message != "above" || kept[1].Range.Start.Line != 4 {
+		t.Fatalf("unexpected carried diagnostics: %+v", kept)
+	}
+}
+
+func TestParseReviewFindings_FormatAndBounds(t *testing.T) {
+	lines := []string{"package p", "", "func f() {", "\t_ = os.Remove(x)", "}"}
+	text := "4 | error | unchecked-error | error from os.Remove is ignored\n1 | warning | x | outside reviewed lines\nnot a finding"
+	got := parseReviewFindings(text, lines, 2, 5)
+	if len(got) != 1 {
+		t.Fatalf("want 1 finding, got %+v", got)
+	}
+	f := got[0]
+	if f.Severity != 1 || f.Code != "unchecked-error" || f.Source != "hexai" || f.Range.Start != (Position{Line: 3, Character: 1}) {
+		t.Fatalf("unexpected finding: %+v", f)
+	}
+	if parseReviewFindings("NONE", lines, 0, 5) != nil {
+		t.Fatalf("NONE should yield no findings")
+	}
+}
+
+func TestRunReview_PublishesAndCachesByContent(t *testing.T) {
+	var buf bytes.Buffer
+	s := newTestServer()
+	s.out = &buf
+	llm := &countingLLM{}
+	setConfig(s, func(c *serverConfig) { c.llmClient = llm })
+	uri := "file:///p/a.go"
+	s.setDocument(uri, "package p\nx := 1")
+	s.runReview(context.Background(), uri, &pendingReview{cancel: func() {}})
+	msgs := readAllMessages(t, &buf)
+	if len(msgs) != 1 || string(msgs[0]["method"]) != `"textDocument/publishDiagnostics"` {
+		t.Fatalf("expected one publishDiagnostics, got %v", msgs)
+	}
+	var p PublishDiagnosticsParams
+	_ = json.Unmarshal(msgs[0]["params"], &p)
+	if p.URI != uri || p.Diagnostics == nil || len(p.Diagnostics) != 0 {
+		t.Fatalf("unexpected params: %+v", p)
+	}
+	// Same content again is served from the cache.
+	s.setDocument(uri, "package p\nx := 1")
+	s.runReview(context.Background(), uri, &pendingReview{cancel: func() {}})
+	if llm.calls != 1 {
+		t.Fatalf("expected cached review, llm calls=%d", llm.calls)
+	}
+}
+
+func TestScheduleReview_CancelledByChange(t *testing.T) {
+	s := newTestServer()
+	s.out = &bytes.Buffer{}
+	llm := &countingLLM{}
+	setConfig(s, func(c *serverConfig) {
+		c.llmClient = llm
+		c.reviewDebounce = 20 * time.Millisecond
+	})
+	uri := "file:///p/a.go"
+	s.setDocument(uri, "package p")
+	s.scheduleReview(uri)
+	s.handleDidChange(Request{Params: json.RawMessage(`{"textDocument":{"uri":"file:///p/a.go","version":2},"contentChanges":[{"text":"package q"}]}`)})
+	time.Sleep(60 * time.Millisecond)
+	if llm.calls != 0 {
+		t.Fatalf("review should have been cancelled, llm calls=%d", llm.calls)
+	}
+}
+
+func TestBuildReviewFixActions_FromContextDiagnostics(t *testing.T) {
+	s := newTestServer()
+	setConfig(s, func(c *serverConfig) {
+		c.llmClient = fakeLLM{resp: "\tif err := os.Remove(x); err != nil {\n\t\treturn err\n\t}"}
+	})
+	uri := "file:///p/a.go"
+	s.setDocument(uri, "package p\nfunc f() error {\n\tos.Remove(x)\n\treturn nil\n}")
+	ctx, _ := json.Marshal(CodeActionContext{Diagnostics: []Diagnostic{
+		{Range: *rangeOf(2, 1, 2, 13), Source: "hexai", Code: "unchecked-error", Message: "error ignored"},
+		{Range: *rangeOf(2, 1, 2, 13), Source: "gopls", Message: "other"},
+	}})
+	p := CodeActionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Range: *rangeOf(2, 3, 2, 3), Context: ctx}
+	actions := s.buildReviewFixActions(p, s.getDocument(uri))
+	if len(actions) != 1 || actions[0].Title != "Hexai: fix unchecked-error" || actions[0].Kind != "quickfix" {
+		t.Fatalf("unexpected actions: %+v", actions)
+	}
+	resolved, ok := s.resolveCodeAction(context.Background(), actions[0])
+	if !ok || resolved.Edit.Changes[uri][0].Range != *rangeOf(2, 0, 2, 13) {
+		t.Fatalf("expected edit over the finding's lines, got %+v", resolved.Edit)
+	}
+}
@@ -3,47 +3,119 @@ package lsp
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"hexai/internal/ignore"
 	"hexai/internal/llm"
 	"hexai/internal/logging"
+	"hexai/internal/prompts"
+	"hexai/internal/retrieval"
 	"io"
 	"log"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // Server implements a minimal LSP over stdio.
 type Server struct {
-	in               *bufio.Reader
-	out              io.Writer
-	logger           *log.Logger
-	exited           bool
-	mu               sync.RWMutex
-	docs             map[string]*document
-	logContext       bool
-	llmClient        llm.Client
-	lastInput        time.Time
-	maxTokens        int
-	contextMode      string
-	windowLines      int
-	maxContextTokens int
-	noDiskIO         bool
-	triggerChars     []string
-	// If set, used as the LSP coding temperature for all LLM calls
-	codingTemperature *float64
+	in         *bufio.Reader
+	out        io.Writer
+	logger     *log.Logger
+	exited     bool
+	mu         sync.RWMutex
+	docs       map[string]*document
+	logContext bool
+	lastInput  time.Time
+	// Settings derived from ServerOptions, replaced as a whole on reload
+	cfg atomic.Pointer[serverConfig]
 	// LLM request stats
 	llmReqTotal       int64
 	llmSentBytesTotal int64
 	llmRespTotal      int64
 	llmRespBytesTotal int64
 	startTime         time.Time
+	// Hedge stats: fired requests and which leg answered first
+	hedgeFired         int64
+	hedgePrimaryWins   int64
+	hedgeSecondaryWins int64
 	// Small LRU cache for recent code completion outputs (keyed by context)
 	compCache      map[string]string
 	compCacheOrder []string // most-recent at end; cap ~10
+	// Hover explanations keyed by document version and position
+	hoverCache      map[string]string
+	hoverCacheOrder []string // oldest first; capped at hoverCacheSize
+	// Pending or running reviews, keyed by URI; cancelled by the next change
+	reviews map[string]*pendingReview
+	// Last published review per URI, the base for reviewing only changed lines
+	reviewed map[string]reviewSnapshot
+	// Review findings keyed by content hash
+	reviewCache      map[string][]Diagnostic
+	reviewCacheOrder []string // oldest first; capped at reviewCacheSize
+	// Workspace embeddings index (nil when no embedder is configured)
+	embedder llm.Embedder
+	indexDir string
+	vectors  *retrieval.VectorIndex
+	// Lexical index for the retrieval context mode; open documents changed
+	// since the last search are re-indexed lazily
+	lexical      *retrieval.LexicalIndex
+	lexicalStale map[string]bool
+	// Chunks of other files for the cross-file context mode, keyed by path
+	crossFileCache map[string]crossFileEntry
+	// Ignore rules; files they match are never sent to a provider
+	ignore         *ignore.Matcher
+	ignoreFile     string
+	ignoreNotified map[string]bool
+	// Model chosen via hexai.switchModel; empty uses the client's default
+	modelOverride string
+	// Last cursor position seen in a request, used by commands run without arguments
+	lastCursorURI   string
+	lastCursorRange Range
+	// Last non-empty selection, referenced by @selection in prompts
+	lastSelectionURI string
+	lastSelection    Range
+	// Re-reads configuration at initialize, on hexai.reloadConfig, on
+	// workspace/didChangeConfiguration and when a config file changes (nil
+	// when unsupported)
+	reloadConfig func(root string, settings json.RawMessage) (ServerOptions, error)
+	// Serializes reloads; held while a reload builds and applies options
+	reloadMu sync.Mutex
+	// Workspace root directory from initialize; empty before or without one
+	root string
+	// Settings last pushed by the editor with workspace/didChangeConfiguration
+	editorSettings json.RawMessage
+	// Completion is registered dynamically, so its trigger characters can
+	// change on reload; completionRegistered once the client accepted it
+	dynamicCompletion    bool
+	completionRegistered bool
+	// Client supports window/workDoneProgress; cancel funcs of running
+	// progress keyed by token
+	workDoneProgress bool
+	progress         map[string]context.CancelFunc
 	// Outgoing JSON-RPC id counter for server-initiated requests
 	nextID int64
-	// Minimum identifier chars required for manual invoke to bypass prefix checks
-	manualInvokeMinPrefix int
+	// Channels awaiting client responses, keyed by request id
+	pending map[string]chan clientResponse
+	// Documents with an in-editor chat reply currently in flight
+	chatInFlight map[string]bool
+	// Chat settings changed with slash commands, keyed by URI
+	chatSessions map[string]chatSession
+	// Summaries of older chat session turns keyed by content hash
+	chatSummaries    map[string]string
+	chatSummaryOrder []string // oldest first; capped at chatSummaryCacheSize
+
+	// Client declared textDocument/inlineCompletion support at initialize;
+	// LLM suggestions are then served as ghost text only.
+	clientInlineCompletion bool
+
+	// Client accepts WorkspaceEdit.documentChanges, and file creation in
+	// them (workspace.workspaceEdit capabilities from initialize)
+	editDocumentChanges bool
+	editCreateFiles     bool
+
+	// Position encoding negotiated at initialize ("utf-8" or "utf-16")
+	posEncoding string
 
 	// LLM concurrency guard: allow at most one in-flight request
 	llmBusy bool
@@ -59,59 +131,215 @@ type ServerOptions struct {
 	ContextMode      string
 	WindowLines      int
 	MaxContextTokens int
+	// NoDiskIO restricts the server to open documents; files are never read from disk.
+	NoDiskIO bool
 
 	Client                llm.Client
 	TriggerCharacters     []string
 	CodingTemperature     *float64
 	ManualInvokeMinPrefix int
+
+	// HedgeClient, when set, receives a duplicate completion request if the
+	// primary client has not answered within HedgeDelay.
+	HedgeClient llm.Client
+	HedgeDelay  time.Duration
+
+	// Prompts renders system/user prompts; nil uses the embedded defaults.
+	Prompts *prompts.Registry
+
+	// HoverEnabled turns on LLM explanations for textDocument/hover. A hover
+	// only reaches the LLM after HoverMinDelay without typing.
+	HoverEnabled  bool
+	HoverMinDelay time.Duration
+
+	// ReviewLanguages lists the LSP language ids reviewed by the LLM on
+	// textDocument/didSave; ReviewDebounce delays the review after a save.
+	ReviewLanguages []string
+	ReviewDebounce  time.Duration
+
+	// Embedder enables the workspace embeddings index, built at initialize
+	// from the root URI and stored below IndexDir (in memory when empty).
+	// Reloaded options replace it; the index is only rebuilt when the
+	// embedding model changes.
+	// RetrievalTopK similar chunks are added to chat and code action
+	// prompts, and to completions when CompletionRetrieval is set.
+	Embedder            llm.Embedder
+	IndexDir            string
+	RetrievalTopK       int
+	CompletionRetrieval bool
+
+	// ChatDir holds the chat sessions created by hexai.newChat; empty
+	// disables the chat commands. ChatHistoryTokens bounds the transcript
+	// sent for a session; older turns are summarised to fit.
+	ChatDir           string
+	ChatHistoryTokens int
+
+	// IgnoreFile is the user ignore file read in addition to .gitignore and
+	// .hexaiignore at the workspace root. IgnoreShowMessage tells the user
+	// when a request is refused because its file is ignored.
+	IgnoreFile        string
+	IgnoreShowMessage bool
+
+	// Languages overrides settings per LSP language id (e.g. "go").
+	Languages map[string]LanguageOptions
+
+	// ReloadConfig re-reads the configuration, including the project config
+	// of the workspace root and the settings pushed by the editor (nil when
+	// none), at initialize, for the hexai.reloadConfig command, on
+	// workspace/didChangeConfiguration and when one of ConfigFiles changes;
+	// nil disables reloading.
+	ReloadConfig func(root string, settings json.RawMessage) (ServerOptions, error)
+	// ConfigFiles are polled for changes while the server runs.
+	ConfigFiles []string
+	// ConfigError is a fatal configuration problem (e.g. why the LLM is
+	// disabled), shown with window/showMessage once the client is
+	// initialized. ConfigWarnings go to window/logMessage then and on
+	// reload.
+	ConfigError    string
+	ConfigWarnings []string
+	// Notice is shown to the user via window/showMessage when the options
+	// are applied at initialize or reload (e.g. an untrusted project config).
+	Notice string
 }
 
-func NewServer(r io.Reader, w io.Writer, logger *log.Logger, opts ServerOptions) *Server {
-	s := &Server{in: bufio.NewReader(r), out: w, logger: logger, docs: make(map[string]*document), logContext: opts.LogContext}
-	maxTokens := opts.MaxTokens
-	if maxTokens <= 0 {
-		maxTokens = 500
-	}
-	s.maxTokens = maxTokens
-	contextMode := opts.ContextMode
-	if contextMode == "" {
-		contextMode = "file-on-new-func"
+// serverConfig holds the settings derived from ServerOptions. A reload
+// publishes a new value and never modifies a published one, so a request
+// reads s.config() once and keeps consistent settings while a reload runs
+// (e.g. the client it checked for nil is the one it calls).
+type serverConfig struct {
+	llmClient        llm.Client
+	maxTokens        int
+	contextMode      string
+	windowLines      int
+	maxContextTokens int
+	noDiskIO         bool
+	triggerChars     []string
+	// If set, used as the LSP coding temperature for all LLM calls
+	codingTemperature *float64
+	// Per-language overrides keyed by lower-case LSP language id
+	languages map[string]LanguageOptions
+	// Minimum identifier chars required for manual invoke to bypass prefix checks
+	manualInvokeMinPrefix int
+	// Optional secondary client for hedged completion requests
+	hedgeClient llm.Client
+	hedgeDelay  time.Duration
+	// Prompt templates for every LLM interaction (nil renders defaults)
+	prompts *prompts.Registry
+	// Hover explanations; off by default since hover fires often
+	hoverEnabled  bool
+	hoverMinDelay time.Duration
+	// LLM review on save: enabled language ids and debounce after a save
+	reviewLanguages map[string]bool
+	reviewDebounce  time.Duration
+	// Similar chunks from the embeddings index; completions use them only
+	// when completionRetrieval is set
+	retrievalTopK       int
+	completionRetrieval bool
+	// Chat sessions: directory for hexai.newChat and transcript token budget
+	chatDir           string
+	chatHistoryTokens int
+	ignoreShowMessage bool
+	// Config files polled for changes
+	configFiles []string
+	// Configuration problems, reported after initialize
+	configError    string
+	configWarnings []string
+}
+
+// config returns the current settings; see serverConfig.
+func (s *Server) config() *serverConfig {
+	if c := s.cfg.Load(); c != nil {
+		return c
 	}
-	windowLines := opts.WindowLines
-	if windowLines <= 0 {
-		windowLines = 120
+	return &serverConfig{}
+}
+
+// applyOptions publishes the settings derived from opts, applying defaults
+// for unset values. It is used at construction and on reload.
+func (s *Server) applyOptions(opts ServerOptions) {
+	c := &serverConfig{
+		llmClient:             opts.Client,
+		maxTokens:             positiveOr(opts.MaxTokens, 500),
+		contextMode:           opts.ContextMode,
+		windowLines:           positiveOr(opts.WindowLines, 120),
+		maxContextTokens:      positiveOr(opts.MaxContextTokens, 2000),
+		noDiskIO:              opts.NoDiskIO,
+		codingTemperature:     opts.CodingTemperature,
+		languages:             normalizeLanguages(opts.Languages),
+		manualInvokeMinPrefix: opts.ManualInvokeMinPrefix,
+		hedgeClient:           opts.HedgeClient,
+		hedgeDelay:            opts.HedgeDelay,
+		prompts:               opts.Prompts,
+		hoverEnabled:          opts.HoverEnabled,
+		hoverMinDelay:         opts.HoverMinDelay,
+		reviewLanguages:       make(map[string]bool, len(opts.ReviewLanguages)),
+		reviewDebounce:        opts.ReviewDebounce,
+		retrievalTopK:         opts.RetrievalTopK,
+		completionRetrieval:   opts.CompletionRetrieval,
+		chatDir:               opts.ChatDir,
+		chatHistoryTokens:     positiveOr(opts.ChatHistoryTokens, 6000),
+		ignoreShowMessage:     opts.IgnoreShowMessage,
+		configFiles:           append([]string(nil), opts.ConfigFiles...),
+		configError:           opts.ConfigError,
+		configWarnings:        opts.ConfigWarnings,
 	}
-	maxContextTokens := opts.MaxContextTokens
-	if maxContextTokens <= 0 {
-		maxContextTokens = 2000
+	if c.contextMode == "" {
+		c.contextMode = "file-on-new-func"
 	}
-	s.contextMode = contextMode
-	s.windowLines = windowLines
-	s.maxContextTokens = maxContextTokens
-
-	s.startTime = time.Now()
-	s.llmClient = opts.Client
 	if len(opts.TriggerCharacters) == 0 {
 		// Defaults (no space to avoid auto-trigger after whitespace)
-		s.triggerChars = []string{".", ":", "/", "_", ")", "{"}
+		c.triggerChars = []string{".", ":", "/", "_", ")", "{"}
 	} else {
-		s.triggerChars = append([]string{}, opts.TriggerCharacters...)
+		c.triggerChars = append([]string{}, opts.TriggerCharacters...)
+	}
+	if c.hedgeDelay <= 0 {
+		c.hedgeDelay = 400 * time.Millisecond
+	}
+	for _, l := range opts.ReviewLanguages {
+		c.reviewLanguages[strings.ToLower(strings.TrimSpace(l))] = true
 	}
-	s.codingTemperature = opts.CodingTemperature
+	if c.reviewDebounce <= 0 {
+		c.reviewDebounce = 1500 * time.Millisecond
+	}
+	s.cfg.Store(c)
+}
+
+func positiveOr(v, def int) int {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
+
+func NewServer(r io.Reader, w io.Writer, logger *log.Logger, opts ServerOptions) *Server {
+	s := &Server{in: bufio.NewReader(r), out: w, logger: logger, docs: make(map[string]*document), logContext: opts.LogContext}
+	s.startTime = time.Now()
 	s.compCache = make(map[string]string)
-	s.manualInvokeMinPrefix = opts.ManualInvokeMinPrefix
+	s.reloadConfig = opts.ReloadConfig
+	s.embedder, s.indexDir = opts.Embedder, opts.IndexDir
+	s.ignoreFile = opts.IgnoreFile
+	s.applyOptions(opts)
+	s.loadIgnore("")
 	// Initialize dispatch table
 	s.handlers = map[string]func(Request){
-		"initialize":              s.handleInitialize,
-		"initialized":             func(_ Request) { s.handleInitialized() },
-		"shutdown":                s.handleShutdown,
-		"exit":                    func(_ Request) { s.handleExit() },
-		"textDocument/didOpen":    s.handleDidOpen,
-		"textDocument/didChange":  s.handleDidChange,
-		"textDocument/didClose":   s.handleDidClose,
-		"textDocument/completion": s.handleCompletion,
-		"textDocument/codeAction": s.handleCodeAction,
-		"codeAction/resolve":      s.handleCodeActionResolve,
+		"initialize":                       s.handleInitialize,
+		"initialized":                      func(_ Request) { s.handleInitialized() },
+		"shutdown":                         s.handleShutdown,
+		"exit":                             func(_ Request) { s.handleExit() },
+		"textDocument/didOpen":             s.handleDidOpen,
+		"textDocument/didChange":           s.handleDidChange,
+		"textDocument/didClose":            s.handleDidClose,
+		"textDocument/didSave":             s.handleDidSave,
+		"textDocument/completion":          s.handleCompletion,
+		"textDocument/inlineCompletion":    s.handleInlineCompletion,
+		"textDocument/hover":               s.handleHover,
+		"textDocument/codeAction":          s.handleCodeAction,
+		"workspace/executeCommand":         s.handleExecuteCommand,
+		"workspace/didChangeConfiguration": s.handleDidChangeConfiguration,
+		"window/workDoneProgress/cancel":   s.handleProgressCancel,
+		"codeAction/resolve":               s.handleCodeActionResolve,
+		"textDocument/codeLens":            s.handleCodeLens,
+		"codeLens/resolve":                 s.handleCodeLensResolve,
 	}
 	return s
 }
@@ -131,7 +359,13 @@ func (s *Server) Run() error {
 			continue
 		}
 		if req.Method == "" {
-			// A response from client; ignore
+			// A response from client; hand it to any waiting caller
+			s.deliverResponse(body)
+			continue
+		}
+		if isDocumentSyncMethod(req.Method) {
+			// Changes must be applied in order; these handlers never block.
+			s.handle(req)
 			continue
 		}
 		go s.handle(req)
@@ -140,3 +374,13 @@ func (s *Server) Run() error {
 		}
 	}
 }
+
+// isDocumentSyncMethod reports whether method mutates the document store and
+// therefore has to be handled in arrival order.
+func isDocumentSyncMethod(method string) bool {
+	switch method {
+	case "textDocument/didOpen", "textDocument/didChange", "textDocument/didClose":
+		return true
+	}
+	return false
+}
@@ -23,6 +23,59 @@ type RespError struct {
 	Message string `json:"message"`
 }
 
+// InitializeParams is the subset of the initialize request the server reads.
+type InitializeParams struct {
+	RootURI          string             `json:"rootUri,omitempty"`
+	WorkspaceFolders []WorkspaceFolder  `json:"workspaceFolders,omitempty"`
+	Capabilities     ClientCapabilities `json:"capabilities"`
+}
+
+type WorkspaceFolder struct {
+	URI  string `json:"uri"`
+	Name string `json:"name"`
+}
+
+// ClientCapabilities (subset)
+type ClientCapabilities struct {
+	General      *GeneralClientCapabilities      `json:"general,omitempty"`
+	TextDocument *TextDocumentClientCapabilities `json:"textDocument,omitempty"`
+	Window       *WindowClientCapabilities       `json:"window,omitempty"`
+	Workspace    *WorkspaceClientCapabilities    `json:"workspace,omitempty"`
+}
+
+type WorkspaceClientCapabilities struct {
+	WorkspaceEdit *WorkspaceEditClientCapabilities `json:"workspaceEdit,omitempty"`
+}
+
+type WorkspaceEditClientCapabilities struct {
+	// The client accepts WorkspaceEdit.DocumentChanges.
+	DocumentChanges bool `json:"documentChanges,omitempty"`
+	// Resource operations the client supports in DocumentChanges
+	// ("create", "rename", "delete").
+	ResourceOperations []string `json:"resourceOperations,omitempty"`
+}
+
+type WindowClientCapabilities struct {
+	// The client supports server-initiated work-done progress.
+	WorkDoneProgress bool `json:"workDoneProgress,omitempty"`
+}
+
+type GeneralClientCapabilities struct {
+	// Position encodings the client supports, in order of preference (LSP 3.17).
+	PositionEncodings []string `json:"positionEncodings,omitempty"`
+}
+
+type TextDocumentClientCapabilities struct {
+	Completion *CompletionClientCapabilities `json:"completion,omitempty"`
+	// Present when the client supports textDocument/inlineCompletion.
+	InlineCompletion json.RawMessage `json:"inlineCompletion,omitempty"`
+}
+
+type CompletionClientCapabilities struct {
+	// The client supports client/registerCapability for completion.
+	DynamicRegistration bool `json:"dynamicRegistration,omitempty"`
+}
+
 // LSP responses (subset)
 type InitializeResult struct {
 	Capabilities ServerCapabilities `json:"capabilities"`
@@ -35,10 +88,33 @@ type ServerInfo struct {
 }
 
 type ServerCapabilities struct {
+	PositionEncoding   string             `json:"positionEncoding,omitempty"`
 	TextDocumentSync   any                `json:"textDocumentSync,omitempty"`
 	CompletionProvider *CompletionOptions `json:"completionProvider,omitempty"`
 	// bool | CodeActionOptions
-	CodeActionProvider any `json:"codeActionProvider,omitempty"`
+	CodeActionProvider     any                    `json:"codeActionProvider,omitempty"`
+	HoverProvider          bool                   `json:"hoverProvider,omitempty"`
+	CodeLensProvider       *CodeLensOptions       `json:"codeLensProvider,omitempty"`
+	ExecuteCommandProvider *ExecuteCommandOptions `json:"executeCommandProvider,omitempty"`
+	// bool | InlineCompletionOptions (LSP 3.18)
+	InlineCompletionProvider any `json:"inlineCompletionProvider,omitempty"`
+}
+
+// Text document sync kinds
+const (
+	TextDocumentSyncKindFull        = 1
+	TextDocumentSyncKindIncremental = 2
+)
+
+type TextDocumentSyncOptions struct {
+	OpenClose bool         `json:"openClose"`
+	Change    int          `json:"change"`
+	Save      *SaveOptions `json:"save,omitempty"`
+}
+
+// SaveOptions asks the client to send textDocument/didSave.
+type SaveOptions struct {
+	IncludeText bool `json:"includeText"`
 }
 
 type CompletionOptions struct {
@@ -46,6 +122,38 @@ type CompletionOptions struct {
 	TriggerCharacters []string `json:"triggerCharacters,omitempty"`
 }
 
+// Dynamic registration (client/registerCapability, client/unregisterCapability)
+type Registration struct {
+	ID              string `json:"id"`
+	Method          string `json:"method"`
+	RegisterOptions any    `json:"registerOptions,omitempty"`
+}
+
+type RegistrationParams struct {
+	Registrations []Registration `json:"registrations"`
+}
+
+type Unregistration struct {
+	ID     string `json:"id"`
+	Method string `json:"method"`
+}
+
+type UnregistrationParams struct {
+	// Misspelled in the LSP specification.
+	Unregisterations []Unregistration `json:"unregisterations"`
+}
+
+// CompletionRegistrationOptions are the register options of
+// textDocument/completion; a null documentSelector selects all documents.
+type CompletionRegistrationOptions struct {
+	DocumentSelector  any      `json:"documentSelector"`
+	TriggerCharacters []string `json:"triggerCharacters,omitempty"`
+}
+
+type DidChangeConfigurationParams struct {
+	Settings json.RawMessage `json:"settings"`
+}
+
 type CompletionList struct {
 	IsIncomplete bool             `json:"isIncomplete"`
 	Items        []CompletionItem `json:"items"`
@@ -90,8 +198,22 @@ type DidOpenTextDocumentParams struct {
 	TextDocument TextDocumentItem `json:"textDocument"`
 }
 
+type DidSaveTextDocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// PublishDiagnosticsParams is sent with textDocument/publishDiagnostics; an
+// empty Diagnostics list clears the document's diagnostics.
+type PublishDiagnosticsParams struct {
+	URI         string       `json:"uri"`
+	Version     *int         `json:"version,omitempty"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// TextDocumentContentChangeEvent is either a ranged (incremental) change or,
+// when Range is nil, the full new document text.
 type TextDocumentContentChangeEvent struct {
-	Range       any    `json:"range,omitempty"`
+	Range       *Range `json:"range,omitempty"`
 	RangeLength int    `json:"rangeLength,omitempty"`
 	Text        string `json:"text"`
 }
@@ -116,6 +238,108 @@ type CompletionParams struct {
 	Context      any                    `json:"context,omitempty"`
 }
 
+// Inline completion (LSP 3.18)
+type InlineCompletionParams struct {
+	TextDocument TextDocumentIdentifier  `json:"textDocument"`
+	Position     Position                `json:"position"`
+	Context      InlineCompletionContext `json:"context"`
+}
+
+type InlineCompletionContext struct {
+	// 1 = Invoked (explicit user request), 2 = Automatic (while typing)
+	TriggerKind int `json:"triggerKind"`
+}
+
+type InlineCompletionList struct {
+	Items []InlineCompletionItem `json:"items"`
+}
+
+// InlineCompletionItem is shown as ghost text; InsertText replaces Range.
+type InlineCompletionItem struct {
+	InsertText string `json:"insertText"`
+	FilterText string `json:"filterText,omitempty"`
+	Range      *Range `json:"range,omitempty"`
+}
+
+// Hover
+type HoverParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+type MarkupContent struct {
+	Kind  string `json:"kind"` // "plaintext" | "markdown"
+	Value string `json:"value"`
+}
+
+type Hover struct {
+	Contents MarkupContent `json:"contents"`
+	Range    *Range        `json:"range,omitempty"`
+}
+
+// Code lenses
+type CodeLensOptions struct {
+	ResolveProvider bool `json:"resolveProvider,omitempty"`
+}
+
+type CodeLensParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// CodeLens is sent without Command and filled in by codeLens/resolve.
+type CodeLens struct {
+	Range   Range           `json:"range"`
+	Command *Command        `json:"command,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// Command references a workspace/executeCommand command.
+type Command struct {
+	Title     string `json:"title"`
+	Command   string `json:"command"`
+	Arguments []any  `json:"arguments,omitempty"`
+}
+
+// Commands
+type ExecuteCommandOptions struct {
+	Commands []string `json:"commands"`
+}
+
+type ExecuteCommandParams struct {
+	Command   string            `json:"command"`
+	Arguments []json.RawMessage `json:"arguments,omitempty"`
+}
+
+// ShowMessageParams is the payload of window/showMessage and
+// window/logMessage.
+type ShowMessageParams struct {
+	Type    int    `json:"type"`
+	Message string `json:"message"`
+}
+
+// Work-done progress
+type WorkDoneProgressCreateParams struct {
+	Token string `json:"token"`
+}
+
+type WorkDoneProgressCancelParams struct {
+	Token json.RawMessage `json:"token"`
+}
+
+type ProgressParams struct {
+	Token string `json:"token"`
+	Value any    `json:"value"`
+}
+
+// WorkDoneProgressValue is the value of a begin, report or end $/progress
+// notification; Title and Cancellable only apply to begin.
+type WorkDoneProgressValue struct {
+	Kind        string `json:"kind"`
+	Title       string `json:"title,omitempty"`
+	Cancellable bool   `json:"cancellable,omitempty"`
+	Message     string `json:"message,omitempty"`
+}
+
 // Code actions
 type CodeActionParams struct {
 	TextDocument TextDocumentIdentifier `json:"textDocument"`
@@ -125,6 +349,32 @@ type CodeActionParams struct {
 
 type WorkspaceEdit struct {
 	Changes map[string][]TextEdit `json:"changes,omitempty"`
+	// Ordered TextDocumentEdit and resource operations (e.g., CreateFile).
+	DocumentChanges []any `json:"documentChanges,omitempty"`
+}
+
+// OptionalVersionedTextDocumentIdentifier identifies a document; a nil
+// Version (null) means the edit applies to any version.
+type OptionalVersionedTextDocumentIdentifier struct {
+	URI     string `json:"uri"`
+	Version *int   `json:"version"`
+}
+
+type TextDocumentEdit struct {
+	TextDocument OptionalVersionedTextDocumentIdentifier `json:"textDocument"`
+	Edits        []TextEdit                              `json:"edits"`
+}
+
+// CreateFile is a resource operation in WorkspaceEdit.DocumentChanges.
+type CreateFile struct {
+	Kind    string             `json:"kind"` // always "create"
+	URI     string             `json:"uri"`
+	Options *CreateFileOptions `json:"options,omitempty"`
+}
+
+type CreateFileOptions struct {
+	Overwrite      bool `json:"overwrite,omitempty"`
+	IgnoreIfExists bool `json:"ignoreIfExists,omitempty"`
 }
 
 // ApplyWorkspaceEditParams is the client request payload for workspace/applyEdit.
@@ -133,6 +383,13 @@ type ApplyWorkspaceEditParams struct {
 	Edit  WorkspaceEdit `json:"edit"`
 }
 
+// ShowDocumentParams is the server request payload for window/showDocument.
+type ShowDocumentParams struct {
+	URI       string `json:"uri"`
+	TakeFocus bool   `json:"takeFocus,omitempty"`
+	Selection *Range `json:"selection,omitempty"`
+}
+
 type CodeAction struct {
 	Title string          `json:"title"`
 	Kind  string          `json:"kind,omitempty"`
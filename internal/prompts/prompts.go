@@ -0,0 +1,213 @@
+// Summary: Prompt registry backed by text/template; embedded defaults can be overridden by files on disk.
+package prompts
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"hexai/internal/logging"
+)
+
+// Prompt names. Each name maps to "<name>.tmpl" in the defaults and in the
+// user prompts directory.
+const (
+	CompletionSystem       = "completion_system"
+	CompletionUser         = "completion_user"
+	CompletionParamsSystem = "completion_params_system"
+	CompletionParamsUser   = "completion_params_user"
+	CompletionInlineSystem = "completion_inline_system"
+	CompletionContext      = "completion_context"
+	RewriteSystem          = "rewrite_system"
+	RewriteUser            = "rewrite_user"
+	DiagnosticsSystem      = "diagnostics_system"
+	DiagnosticsUser        = "diagnostics_user"
+	ChatSystem             = "chat_system"
+	ChatSummarySystem      = "chat_summary_system"
+	ChatSummaryUser        = "chat_summary_user"
+	HoverSystem            = "hover_system"
+	HoverUser              = "hover_user"
+	ExplainSystem          = "explain_system"
+	ExplainUser            = "explain_user"
+	TestsSystem            = "tests_system"
+	TestsUser              = "tests_user"
+	DocSystem              = "doc_system"
+	DocUser                = "doc_user"
+	ReviewSystem           = "review_system"
+	ReviewUser             = "review_user"
+	CLISystem              = "cli_system"
+	CLIExplainSystem       = "cli_explain_system"
+)
+
+//go:embed defaults/*.tmpl
+var defaultFS embed.FS
+
+// Diagnostic is the template view of a diagnostic passed to a prompt.
+type Diagnostic struct {
+	Source  string
+	Message string
+}
+
+// Data holds the variables available to prompt templates. Fields not
+// relevant to a prompt are left empty.
+type Data struct {
+	File        string       // document URI or path
+	Language    string       // language identifier, when known
+	Above       string       // line above the cursor
+	Current     string       // line containing the cursor
+	Below       string       // line below the cursor
+	Cursor      int          // cursor character offset in Current
+	Function    string       // enclosing function (declaration line, or its code for hover)
+	Receiver    string       // receiver type of the enclosing method (Go)
+	Fields      []string     // fields of the receiver struct, "name Type" (Go)
+	Imports     []string     // imported packages (Go)
+	Symbol      string       // identifier or expression under the cursor (hover)
+	Selection   string       // selected code for code actions (numbered changed lines for reviews)
+	Instruction string       // user instruction for code actions
+	Diagnostics []Diagnostic // diagnostics for the diagnostics action
+	Context     string       // additional context text (completion), related workspace code (code actions)
+	TestFile    string       // target test file (generate tests action)
+	Package     string       // package clause of the source file, when the language has one
+	Helpers     string       // existing test file header and helper signatures
+	Append      bool         // tests are appended to an existing test file
+	DocComment  string       // existing documentation comment (doc action)
+	Input       string       // raw user input (CLI)
+	Transcript  string       // chat session turns to summarise
+	Summary     string       // earlier summary of the chat session, if any
+}
+
+// Registry renders named prompts. A nil *Registry renders the embedded
+// defaults, so callers never need to guard against a missing registry.
+type Registry struct {
+	templates map[string]*template.Template
+}
+
+var funcs = template.FuncMap{"inc": func(i int) int { return i + 1 }, "join": strings.Join}
+
+var defaults = mustLoadDefaults()
+
+// Default returns a registry containing only the embedded defaults.
+func Default() *Registry { return defaults }
+
+// Load returns a registry with the embedded defaults overridden by any
+// "<name>.tmpl" files found in dir. Files that fail to parse are logged and
+// ignored. A missing dir is not an error.
+func Load(dir string) *Registry {
+	r := &Registry{templates: make(map[string]*template.Template, len(defaults.templates))}
+	for name, t := range defaults.templates {
+		r.templates[name] = t
+	}
+	if strings.TrimSpace(dir) == "" {
+		return r
+	}
+	for _, name := range Names() {
+		path := filepath.Join(dir, name+".tmpl")
+		b, err := os.ReadFile(path)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				logging.Logf("prompts ", "cannot read %s: %v", path, err)
+			}
+			continue
+		}
+		t, err := parse(name, string(b))
+		if err != nil {
+			logging.Logf("prompts ", "invalid template %s: %v (using default)", path, err)
+			continue
+		}
+		r.templates[name] = t
+	}
+	return r
+}
+
+// Render executes the named prompt with data. If a user template fails to
+// execute, the embedded default is used instead.
+func (r *Registry) Render(name string, data Data) string {
+	if r == nil {
+		r = defaults
+	}
+	t, ok := r.templates[name]
+	if !ok {
+		logging.Logf("prompts ", "unknown prompt %q", name)
+		return ""
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		logging.Logf("prompts ", "render %s: %v (using default)", name, err)
+		b.Reset()
+		if err := defaults.templates[name].Execute(&b, data); err != nil {
+			return ""
+		}
+	}
+	return b.String()
+}
+
+// Names returns all prompt names in sorted order.
+func Names() []string {
+	names := make([]string, 0, len(defaults.templates))
+	for name := range defaults.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Dump writes the embedded defaults to dir as "<name>.tmpl" files for
+// editing. Existing files are kept unless overwrite is set. It returns the
+// paths written.
+func Dump(dir string, overwrite bool) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	var written []string
+	for _, name := range Names() {
+		path := filepath.Join(dir, name+".tmpl")
+		if _, err := os.Stat(path); err == nil && !overwrite {
+			continue
+		}
+		src, err := defaultFS.ReadFile("defaults/" + name + ".tmpl")
+		if err != nil {
+			return written, err
+		}
+		if err := os.WriteFile(path, src, 0o644); err != nil {
+			return written, err
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
+
+// parse compiles a template source. A single trailing newline is dropped so
+// that files saved by editors render exactly like the defaults.
+func parse(name, src string) (*template.Template, error) {
+	src = strings.TrimSuffix(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	return template.New(name).Funcs(funcs).Option("missingkey=zero").Parse(src)
+}
+
+func mustLoadDefaults() *Registry {
+	r := &Registry{templates: make(map[string]*template.Template)}
+	err := fs.WalkDir(defaultFS, "defaults", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, err := defaultFS.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(filepath.Base(path), ".tmpl")
+		t, err := parse(name, string(b))
+		if err != nil {
+			return fmt.Errorf("default prompt %s: %w", name, err)
+		}
+		r.templates[name] = t
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
@@ -0,0 +1,68 @@
+package prompts
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDefaults_RenderLikeFormerHardcodedPrompts(t *testing.T) {
+	got := Default().Render(CompletionUser, Data{File: "file:///a.go", Function: "func f()", Above: "x", Current: "y", Cursor: 1, Below: "z"})
+	want := "Provide the next likely code to insert at the cursor.\nFile: file:///a.go\nFunction/context: func f()\nAbove line: x\nCurrent line (cursor at character 1): y\nBelow line: z\nOnly return the completion snippet."
+	if got != want {
+		t.Fatalf("completion user prompt mismatch:\n%q\nwant\n%q", got, want)
+	}
+	diag := Default().Render(DiagnosticsUser, Data{Selection: "code", Diagnostics: []Diagnostic{{Source: "vet", Message: "bad"}, {Message: "worse"}}})
+	wantDiag := "Diagnostics to resolve (selection only):\n1. [vet] bad\n2. worse\n\nSelected code:\ncode"
+	if diag != wantDiag {
+		t.Fatalf("diagnostics prompt mismatch:\n%q\nwant\n%q", diag, wantDiag)
+	}
+}
+
+func TestNilRegistryRendersDefaults(t *testing.T) {
+	var r *Registry
+	if got := r.Render(ChatSystem, Data{}); !strings.Contains(got, "helpful coding assistant") {
+		t.Fatalf("unexpected chat system prompt: %q", got)
+	}
+}
+
+func TestLoad_OverridesAndIgnoresInvalid(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ChatSystem+".tmpl"), []byte("Be brief about {{.File}}.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, RewriteSystem+".tmpl"), []byte("{{.Broken"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r := Load(dir)
+	if got := r.Render(ChatSystem, Data{File: "x.go"}); got != "Be brief about x.go." {
+		t.Fatalf("override not applied: %q", got)
+	}
+	if got := r.Render(RewriteSystem, Data{}); got != Default().Render(RewriteSystem, Data{}) {
+		t.Fatalf("invalid override should fall back to default, got %q", got)
+	}
+}
+
+func TestDump_WritesAllDefaultsAndKeepsExisting(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "prompts")
+	written, err := Dump(dir, false)
+	if err != nil || len(written) != len(Names()) {
+		t.Fatalf("dump wrote %d of %d files, err=%v", len(written), len(Names()), err)
+	}
+	custom := filepath.Join(dir, ChatSystem+".tmpl")
+	if err := os.WriteFile(custom, []byte("mine"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	written, err = Dump(dir, false)
+	if err != nil || len(written) != 0 {
+		t.Fatalf("expected existing files to be kept; wrote %v err=%v", written, err)
+	}
+	if b, _ := os.ReadFile(custom); string(b) != "mine" {
+		t.Fatalf("custom template overwritten: %q", b)
+	}
+	// Dumped defaults load back to identical prompts
+	if got := Load(dir).Render(CompletionSystem, Data{}); got != Default().Render(CompletionSystem, Data{}) {
+		t.Fatalf("round-trip mismatch: %q", got)
+	}
+}
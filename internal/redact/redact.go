@@ -0,0 +1,209 @@
+// Summary: Detects secrets (API keys, bearer tokens, private keys, .env assignments, custom regexes) and swaps them for stable placeholders.
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// placeholderPrefix starts every placeholder. Placeholders look like
+// identifiers so models keep them intact inside code and strings.
+const placeholderPrefix = "HEXAI_REDACTED_"
+
+// detector finds one kind of secret. With a capture group only the group
+// is replaced (e.g. the value of KEY=value), otherwise the whole match.
+type detector struct {
+	kind string
+	re   *regexp.Regexp
+}
+
+var builtin = []detector{
+	{"PRIVATE_KEY", regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z0-9 ]*PRIVATE KEY-----`)},
+	{"API_KEY", regexp.MustCompile(`\b(?:sk-[A-Za-z0-9_-]{20,}|gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,}|glpat-[A-Za-z0-9_-]{20,}|xox[abprs]-[A-Za-z0-9-]{10,}|AKIA[0-9A-Z]{16}|AIza[0-9A-Za-z_-]{35})`)},
+	{"BEARER_TOKEN", regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9._~+/-]{16,}=*)`)},
+	{"ENV_SECRET", regexp.MustCompile(`(?m)^[ \t]*(?:export[ \t]+)?[A-Z_][A-Z0-9_]*(?:KEY|SECRET|TOKEN|PASSWORD|PASSWD|PWD|CREDENTIALS?)[A-Z0-9_]*[ \t]*[:=][ \t]*["']?([^\s"'#]{4,})["']?[ \t]*(?:#.*)?$`)},
+}
+
+// Redactor replaces secrets with placeholders. The same secret always gets
+// the same placeholder for the lifetime of the Redactor. It is safe for
+// concurrent use.
+type Redactor struct {
+	detectors []detector
+
+	mu      sync.Mutex
+	byValue map[string]string // secret -> placeholder
+	byPH    map[string]string // placeholder -> secret
+	counter map[string]int    // per kind
+}
+
+// New returns a Redactor using the built-in detectors plus the custom
+// regular expressions in patterns.
+func New(patterns []string) (*Redactor, error) {
+	r := &Redactor{
+		detectors: append([]detector(nil), builtin...),
+		byValue:   make(map[string]string),
+		byPH:      make(map[string]string),
+		counter:   make(map[string]int),
+	}
+	for _, p := range patterns {
+		if strings.TrimSpace(p) == "" {
+			continue
+		}
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("redact pattern %q: %v", p, err)
+		}
+		r.detectors = append(r.detectors, detector{kind: "CUSTOM", re: re})
+	}
+	return r, nil
+}
+
+// Session tracks the placeholders used by one request, so that only those
+// are restored in its response.
+type Session struct {
+	r      *Redactor
+	used   map[string]bool
+	counts map[string]int
+}
+
+// Session starts a request-scoped redaction.
+func (r *Redactor) Session() *Session {
+	return &Session{r: r, used: make(map[string]bool), counts: make(map[string]int)}
+}
+
+// span is a region of text to replace.
+type span struct {
+	start, end int
+	kind       string
+}
+
+// Redact returns text with all detected secrets replaced.
+func (s *Session) Redact(text string) string {
+	if s == nil || text == "" {
+		return text
+	}
+	var spans []span
+	for _, d := range s.r.detectors {
+		for _, m := range d.re.FindAllStringSubmatchIndex(text, -1) {
+			start, end := m[0], m[1]
+			if len(m) >= 4 && m[2] >= 0 {
+				start, end = m[2], m[3]
+			}
+			if end > start && !strings.HasPrefix(text[start:end], placeholderPrefix) {
+				spans = append(spans, span{start, end, d.kind})
+			}
+		}
+	}
+	if len(spans) == 0 {
+		return text
+	}
+	sort.Slice(spans, func(i, j int) bool {
+		if spans[i].start != spans[j].start {
+			return spans[i].start < spans[j].start
+		}
+		return spans[i].end > spans[j].end
+	})
+	var b strings.Builder
+	last := 0
+	for _, sp := range spans {
+		if sp.start < last {
+			continue // overlaps an earlier, longer match
+		}
+		ph := s.r.placeholder(sp.kind, text[sp.start:sp.end])
+		s.used[ph] = true
+		s.counts[sp.kind]++
+		b.WriteString(text[last:sp.start])
+		b.WriteString(ph)
+		last = sp.end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+func (r *Redactor) placeholder(kind, secret string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ph, ok := r.byValue[secret]; ok {
+		return ph
+	}
+	r.counter[kind]++
+	ph := fmt.Sprintf("%s%s_%d", placeholderPrefix, kind, r.counter[kind])
+	r.byValue[secret], r.byPH[ph] = ph, secret
+	return ph
+}
+
+// placeholderRE matches a complete placeholder.
+var placeholderRE = regexp.MustCompile(placeholderPrefix + `[A-Z_]+_[0-9]+`)
+
+// Restore puts the secrets back for placeholders used in this session.
+// Placeholders the session did not send are left as they are.
+func (s *Session) Restore(text string) string {
+	if s == nil || len(s.used) == 0 {
+		return text
+	}
+	return placeholderRE.ReplaceAllStringFunc(text, func(ph string) string {
+		if !s.used[ph] {
+			return ph
+		}
+		s.r.mu.Lock()
+		defer s.r.mu.Unlock()
+		return s.r.byPH[ph]
+	})
+}
+
+// RestoreStream wraps onDelta so that streamed text is restored. Text that
+// may be the start of a placeholder is held back until it is complete;
+// flush emits whatever is still held back at the end of the stream.
+func (s *Session) RestoreStream(onDelta func(string)) (write func(string), flush func()) {
+	var pending string
+	write = func(delta string) {
+		pending += delta
+		cut := heldBack(pending)
+		if cut > 0 {
+			onDelta(s.Restore(pending[:cut]))
+			pending = pending[cut:]
+		}
+	}
+	flush = func() {
+		if pending != "" {
+			onDelta(s.Restore(pending))
+			pending = ""
+		}
+	}
+	return write, flush
+}
+
+// heldBack returns the length of the prefix of text that can be emitted:
+// everything before a trailing, possibly incomplete placeholder.
+func heldBack(text string) int {
+	if i := strings.LastIndex(text, placeholderPrefix); i >= 0 {
+		rest := text[i+len(placeholderPrefix):]
+		if strings.IndexFunc(rest, func(r rune) bool { return !(r == '_' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') }) < 0 {
+			return i // may still grow
+		}
+	}
+	for n := min(len(placeholderPrefix)-1, len(text)); n > 0; n-- {
+		if strings.HasSuffix(text, placeholderPrefix[:n]) {
+			return len(text) - n
+		}
+	}
+	return len(text)
+}
+
+// Summary formats the counts as "API_KEY=1 ENV_SECRET=2", sorted by kind,
+// for logging. It never includes secret values.
+func (s *Session) Summary() string {
+	kinds := make([]string, 0, len(s.counts))
+	for k := range s.counts {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	parts := make([]string, len(kinds))
+	for i, k := range kinds {
+		parts[i] = fmt.Sprintf("%s=%d", k, s.counts[k])
+	}
+	return strings.Join(parts, " ")
+}
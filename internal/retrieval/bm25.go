@@ -0,0 +1,211 @@
+// Summary: In-process BM25 inverted index over identifiers and words of workspace chunks; a lexical alternative to embeddings.
+package retrieval
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// BM25 parameters: term frequency saturation and length normalisation.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// lexDoc is an indexed chunk with its number of terms.
+type lexDoc struct {
+	Chunk
+	length int
+}
+
+// LexicalIndex ranks workspace chunks by BM25 over their terms. It is safe
+// for concurrent use.
+type LexicalIndex struct {
+	mu       sync.RWMutex
+	docs     map[int]lexDoc
+	files    map[string][]int       // path -> doc ids
+	postings map[string]map[int]int // term -> doc id -> term frequency
+	nextID   int
+	totalLen int
+}
+
+// NewLexicalIndex returns an empty index.
+func NewLexicalIndex() *LexicalIndex {
+	return &LexicalIndex{
+		docs:     make(map[int]lexDoc),
+		files:    make(map[string][]int),
+		postings: make(map[string]map[int]int),
+	}
+}
+
+// Build indexes all files below root except those skipped (see WalkFiles)
+// and drops other files below root that are no longer found.
+func (ix *LexicalIndex) Build(root string, skip func(path string) bool) error {
+	seen := make(map[string]bool)
+	err := WalkFiles(root, skip, func(path string, content []byte) {
+		seen[path] = true
+		ix.UpdateFile(path, string(content))
+	})
+	ix.mu.RLock()
+	var gone []string
+	for path := range ix.files {
+		if !seen[path] && strings.HasPrefix(path, root) {
+			gone = append(gone, path)
+		}
+	}
+	ix.mu.RUnlock()
+	for _, path := range gone {
+		ix.RemoveFile(path)
+	}
+	return err
+}
+
+// UpdateFile replaces the chunks of path with those of text.
+func (ix *LexicalIndex) UpdateFile(path, text string) {
+	chunks := SplitFile(path, text)
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.removeLocked(path)
+	ids := make([]int, 0, len(chunks))
+	for _, c := range chunks {
+		id := ix.nextID
+		ix.nextID++
+		terms := Terms(c.Text)
+		for _, t := range terms {
+			p := ix.postings[t]
+			if p == nil {
+				p = make(map[int]int)
+				ix.postings[t] = p
+			}
+			p[id]++
+		}
+		ix.docs[id] = lexDoc{Chunk: c, length: len(terms)}
+		ix.totalLen += len(terms)
+		ids = append(ids, id)
+	}
+	ix.files[path] = ids
+}
+
+// RemoveFile drops all chunks of path.
+func (ix *LexicalIndex) RemoveFile(path string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.removeLocked(path)
+}
+
+func (ix *LexicalIndex) removeLocked(path string) {
+	for _, id := range ix.files[path] {
+		for _, t := range Terms(ix.docs[id].Text) {
+			if p := ix.postings[t]; p != nil {
+				delete(p, id)
+				if len(p) == 0 {
+					delete(ix.postings, t)
+				}
+			}
+		}
+		ix.totalLen -= ix.docs[id].length
+		delete(ix.docs, id)
+	}
+	delete(ix.files, path)
+}
+
+// Search returns the k chunks with the highest BM25 score for the terms of
+// query, skipping chunks of the files in exclude. Chunks sharing no term
+// with the query are never returned.
+func (ix *LexicalIndex) Search(query string, k int, exclude ...string) []Result {
+	skip := make(map[string]bool, len(exclude))
+	for _, p := range exclude {
+		skip[p] = true
+	}
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	n := float64(len(ix.docs))
+	if n == 0 {
+		return nil
+	}
+	avgLen := float64(ix.totalLen) / n
+	scores := make(map[int]float64)
+	queried := make(map[string]bool)
+	for _, t := range Terms(query) {
+		p := ix.postings[t]
+		if queried[t] || len(p) == 0 {
+			continue
+		}
+		queried[t] = true
+		df := float64(len(p))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for id, tf := range p {
+			norm := bm25K1 * (1 - bm25B + bm25B*float64(ix.docs[id].length)/avgLen)
+			scores[id] += idf * float64(tf) * (bm25K1 + 1) / (float64(tf) + norm)
+		}
+	}
+	out := make([]Result, 0, len(scores))
+	for id, score := range scores {
+		if d := ix.docs[id]; !skip[d.Path] {
+			out = append(out, Result{Chunk: d.Chunk, Score: score})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		if out[i].Path != out[j].Path {
+			return out[i].Path < out[j].Path
+		}
+		return out[i].Start < out[j].Start
+	})
+	return out[:min(k, len(out))]
+}
+
+// Len returns the number of indexed chunks.
+func (ix *LexicalIndex) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.docs)
+}
+
+// Terms splits text into lower-case words and identifiers. Compound
+// identifiers (camelCase, snake_case) also yield their parts, so
+// "parseConfigFile" matches a query for "config". Single characters and
+// numbers are dropped.
+func Terms(text string) []string {
+	var out []string
+	add := func(w string) {
+		if len(w) >= 2 && !unicode.IsDigit(rune(w[0])) {
+			out = append(out, strings.ToLower(w))
+		}
+	}
+	for _, w := range strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}) {
+		add(w)
+		if parts := identParts(w); len(parts) > 1 {
+			for _, p := range parts {
+				add(p)
+			}
+		}
+	}
+	return out
+}
+
+// identParts splits an identifier at underscores and lower-to-upper case
+// changes: "parseHTTPRequest_v2" -> parse, HTTPRequest, v2.
+func identParts(w string) []string {
+	var parts []string
+	for _, seg := range strings.Split(w, "_") {
+		start := 0
+		for i := 1; i < len(seg); i++ {
+			if unicode.IsLower(rune(seg[i-1])) && unicode.IsUpper(rune(seg[i])) {
+				parts = append(parts, seg[start:i])
+				start = i
+			}
+		}
+		if start < len(seg) {
+			parts = append(parts, seg[start:])
+		}
+	}
+	return parts
+}
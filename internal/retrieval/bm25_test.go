@@ -0,0 +1,46 @@
+package retrieval
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLexicalIndex_RanksByBM25AndUpdates(t *testing.T) {
+	ix := NewLexicalIndex()
+	ix.UpdateFile("/w/db.go", "func openDatabase(dsn string) (*sql.DB, error) {\n\treturn sql.Open(\"pgx\", dsn)\n}")
+	ix.UpdateFile("/w/http.go", "func serveHTTP(w http.ResponseWriter, r *http.Request) {\n\tw.WriteHeader(200)\n}")
+	res := ix.Search("database sql", 5)
+	if len(res) != 1 || res[0].Path != "/w/db.go" {
+		t.Fatalf("expected db.go only, got %+v", res)
+	}
+	if res := ix.Search("database", 5, "/w/db.go"); len(res) != 0 {
+		t.Fatalf("exclude not applied: %+v", res)
+	}
+	ix.UpdateFile("/w/db.go", "package w")
+	if res := ix.Search("database", 5); len(res) != 0 || ix.Len() != 2 {
+		t.Fatalf("stale postings after update: %+v len=%d", res, ix.Len())
+	}
+}
+
+func TestTerms_SplitsCompoundIdentifiers(t *testing.T) {
+	got := strings.Join(Terms("parseHTTPRequest_v2(x, 42)"), " ")
+	if got != "parsehttprequest_v2 parse httprequest v2" {
+		t.Fatalf("unexpected terms %q", got)
+	}
+}
+
+func TestLexicalIndex_BuildHonoursSkip(t *testing.T) {
+	root := t.TempDir()
+	_ = os.MkdirAll(filepath.Join(root, "secrets"), 0o755)
+	_ = os.WriteFile(filepath.Join(root, "main.go"), []byte("func token() {}"), 0o644)
+	_ = os.WriteFile(filepath.Join(root, "secrets", "key.go"), []byte("const token = \"abc\""), 0o644)
+	ix := NewLexicalIndex()
+	if err := ix.Build(root, func(p string) bool { return filepath.Base(p) == "secrets" }); err != nil {
+		t.Fatal(err)
+	}
+	if res := ix.Search("token", 5); len(res) != 1 || res[0].Path != filepath.Join(root, "main.go") {
+		t.Fatalf("skipped directory was indexed: %+v", res)
+	}
+}
@@ -0,0 +1,87 @@
+// Summary: Splits workspace files into line-based chunks and walks the workspace for indexable text files.
+package retrieval
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	chunkMinLines = 20 // a chunk ends at the first blank line after this many lines
+	chunkMaxLines = 60 // hard cap on chunk length
+	maxFileSize   = 256 << 10
+)
+
+// Chunk is a contiguous range of lines of a file.
+type Chunk struct {
+	Path  string `json:"path"`
+	Start int    `json:"start"` // first line, 0-based
+	End   int    `json:"end"`   // last line, inclusive
+	Text  string `json:"text"`
+}
+
+// SplitFile cuts text into chunks of roughly chunkMinLines to
+// chunkMaxLines lines, preferring to end at blank lines so declarations
+// stay together. Blank-only chunks are dropped.
+func SplitFile(path, text string) []Chunk {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	var out []Chunk
+	start := 0
+	emit := func(end int) { // end exclusive
+		if body := strings.Join(lines[start:end], "\n"); strings.TrimSpace(body) != "" {
+			out = append(out, Chunk{Path: path, Start: start, End: end - 1, Text: body})
+		}
+		start = end
+	}
+	for i := range lines {
+		n := i - start + 1
+		if (n >= chunkMinLines && strings.TrimSpace(lines[i]) == "") || n >= chunkMaxLines {
+			emit(i + 1)
+		}
+	}
+	if start < len(lines) {
+		emit(len(lines))
+	}
+	return out
+}
+
+// skipDirs are never descended into when walking a workspace.
+var skipDirs = map[string]bool{"node_modules": true, "vendor": true, "target": true, "dist": true, "build": true}
+
+// WalkFiles calls fn for each indexable file below root: regular text files
+// up to maxFileSize, outside hidden and dependency directories. Files and
+// directories for which skip (may be nil) returns true are left out.
+func WalkFiles(root string, skip func(path string) bool, fn func(path string, content []byte)) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // unreadable entries are skipped
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != root && (strings.HasPrefix(name, ".") || skipDirs[name] || (skip != nil && skip(path))) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || strings.HasPrefix(name, ".") || (skip != nil && skip(path)) {
+			return nil
+		}
+		if info, err := d.Info(); err != nil || info.Size() > maxFileSize {
+			return nil
+		}
+		b, err := os.ReadFile(path)
+		if err != nil || isBinary(b) {
+			return nil
+		}
+		fn(path, b)
+		return nil
+	})
+}
+
+// isBinary reports whether b looks like binary data (a NUL byte early on).
+func isBinary(b []byte) bool {
+	return bytes.IndexByte(b[:min(len(b), 8000)], 0) >= 0
+}
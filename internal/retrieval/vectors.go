@@ -0,0 +1,242 @@
+// Summary: Vector store of embedded workspace chunks; incremental updates, cosine search, and a JSON file under the cache dir.
+package retrieval
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"hexai/internal/llm"
+)
+
+// embedBatch is the number of chunks embedded per provider request.
+const embedBatch = 32
+
+// Result is a chunk returned by a search with its similarity score.
+type Result struct {
+	Chunk
+	Score float64
+}
+
+// vectorEntry is a stored chunk with its embedding. Hash identifies the
+// chunk text so unchanged chunks are not embedded again.
+type vectorEntry struct {
+	Chunk
+	Hash   string    `json:"hash"`
+	Vector []float32 `json:"vector"`
+}
+
+// vectorFile is the on-disk format of a VectorIndex.
+type vectorFile struct {
+	Model string                   `json:"model"`
+	Files map[string][]vectorEntry `json:"files"`
+}
+
+// VectorIndex holds embedded chunks of workspace files. It is safe for
+// concurrent use.
+type VectorIndex struct {
+	storePath string // empty keeps the index in memory only
+
+	mu       sync.RWMutex
+	embedder llm.Embedder
+	files    map[string][]vectorEntry
+}
+
+// NewVectorIndex returns an index using embedder. When storePath names an
+// existing store of the same embedding model it is loaded, so only files
+// changed since then need embedding again.
+func NewVectorIndex(embedder llm.Embedder, storePath string) *VectorIndex {
+	ix := &VectorIndex{embedder: embedder, storePath: storePath, files: make(map[string][]vectorEntry)}
+	if storePath == "" {
+		return ix
+	}
+	if b, err := os.ReadFile(storePath); err == nil {
+		var vf vectorFile
+		if json.Unmarshal(b, &vf) == nil && vf.Model == embedder.EmbeddingModel() && vf.Files != nil {
+			ix.files = vf.Files
+		}
+	}
+	return ix
+}
+
+// Build indexes all files below root except those skipped (see WalkFiles)
+// and drops all other files, including previously stored ones now skipped.
+func (ix *VectorIndex) Build(ctx context.Context, root string, skip func(path string) bool) error {
+	seen := make(map[string]bool)
+	var firstErr error
+	err := WalkFiles(root, skip, func(path string, content []byte) {
+		seen[path] = true
+		if ctx.Err() == nil {
+			if err := ix.UpdateFile(ctx, path, string(content)); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	ix.mu.Lock()
+	for path := range ix.files {
+		if !seen[path] {
+			delete(ix.files, path)
+		}
+	}
+	ix.mu.Unlock()
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// UpdateFile re-chunks path and embeds chunks whose text is not indexed yet.
+func (ix *VectorIndex) UpdateFile(ctx context.Context, path, text string) error {
+	chunks := SplitFile(path, text)
+	ix.mu.RLock()
+	known := make(map[string][]float32)
+	for _, e := range ix.files[path] {
+		known[e.Hash] = e.Vector
+	}
+	ix.mu.RUnlock()
+	entries := make([]vectorEntry, len(chunks))
+	var missing []int
+	for i, c := range chunks {
+		entries[i] = vectorEntry{Chunk: c, Hash: hashText(c.Text)}
+		if v, ok := known[entries[i].Hash]; ok {
+			entries[i].Vector = v
+		} else {
+			missing = append(missing, i)
+		}
+	}
+	for len(missing) > 0 {
+		batch := missing[:min(embedBatch, len(missing))]
+		missing = missing[len(batch):]
+		texts := make([]string, len(batch))
+		for j, i := range batch {
+			texts[j] = entries[i].Text
+		}
+		vecs, err := ix.currentEmbedder().Embed(ctx, texts)
+		if err != nil {
+			return err
+		}
+		for j, i := range batch {
+			entries[i].Vector = vecs[j]
+		}
+	}
+	ix.mu.Lock()
+	ix.files[path] = entries
+	ix.mu.Unlock()
+	return nil
+}
+
+// SetEmbedder replaces the embedder for chunks embedded from now on and for
+// queries, e.g. to apply changed redaction rules. It must use the same
+// embedding model, since the stored vectors are kept.
+func (ix *VectorIndex) SetEmbedder(e llm.Embedder) {
+	ix.mu.Lock()
+	ix.embedder = e
+	ix.mu.Unlock()
+}
+
+func (ix *VectorIndex) currentEmbedder() llm.Embedder {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.embedder
+}
+
+// RemoveFile drops all chunks of path.
+func (ix *VectorIndex) RemoveFile(path string) {
+	ix.mu.Lock()
+	delete(ix.files, path)
+	ix.mu.Unlock()
+}
+
+// Search embeds query and returns the k chunks with the highest cosine
+// similarity, skipping chunks of the files in exclude.
+func (ix *VectorIndex) Search(ctx context.Context, query string, k int, exclude ...string) ([]Result, error) {
+	vecs, err := ix.currentEmbedder().Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	q := vecs[0]
+	skip := make(map[string]bool, len(exclude))
+	for _, p := range exclude {
+		skip[p] = true
+	}
+	var out []Result
+	ix.mu.RLock()
+	for path, entries := range ix.files {
+		if skip[path] {
+			continue
+		}
+		for _, e := range entries {
+			out = append(out, Result{Chunk: e.Chunk, Score: cosine(q, e.Vector)})
+		}
+	}
+	ix.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	return out[:min(k, len(out))], nil
+}
+
+// Len returns the number of indexed chunks.
+func (ix *VectorIndex) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	n := 0
+	for _, entries := range ix.files {
+		n += len(entries)
+	}
+	return n
+}
+
+// Save writes the index to its store file, replacing it atomically.
+func (ix *VectorIndex) Save() error {
+	if ix.storePath == "" {
+		return nil
+	}
+	ix.mu.RLock()
+	b, err := json.Marshal(vectorFile{Model: ix.embedder.EmbeddingModel(), Files: ix.files})
+	ix.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(ix.storePath), 0o755); err != nil {
+		return err
+	}
+	tmp := ix.storePath + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ix.storePath)
+}
+
+// StorePath returns the store file for the workspace root inside dir.
+func StorePath(dir, root string) string {
+	return filepath.Join(dir, "index-"+hashText(root)[:16]+".json")
+}
+
+func hashText(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
@@ -0,0 +1,102 @@
+package retrieval
+
+import (
+	"context"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeEmbedder hashes words into a small bag-of-words vector, so similar
+// texts get similar vectors without any provider.
+type fakeEmbedder struct {
+	model string
+	texts int // texts embedded so far
+}
+
+func (f *fakeEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, t := range texts {
+		v := make([]float32, 64)
+		for _, w := range strings.Fields(t) {
+			h := fnv.New32a()
+			_, _ = h.Write([]byte(w))
+			v[h.Sum32()%64]++
+		}
+		out[i] = v
+	}
+	f.texts += len(texts)
+	return out, nil
+}
+
+func (f *fakeEmbedder) EmbeddingModel() string { return f.model }
+
+func TestSplitFile_PrefersBlankLines(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 25; i++ {
+		b.WriteString("line\n")
+	}
+	b.WriteString("\nnext\n")
+	chunks := SplitFile("a.go", b.String())
+	if len(chunks) != 2 || chunks[0].End != 25 || chunks[1].Start != 26 {
+		t.Fatalf("unexpected chunks: %+v", chunks)
+	}
+}
+
+func TestVectorIndex_SearchAndIncrementalUpdate(t *testing.T) {
+	emb := &fakeEmbedder{model: "fake"}
+	ix := NewVectorIndex(emb, "")
+	ctx := context.Background()
+	_ = ix.UpdateFile(ctx, "db.go", "func openDatabase connection pool sql")
+	_ = ix.UpdateFile(ctx, "http.go", "func serveHTTP handler request response")
+	res, err := ix.Search(ctx, "sql connection pool", 1)
+	if err != nil || len(res) != 1 || res[0].Path != "db.go" {
+		t.Fatalf("unexpected search result %+v %v", res, err)
+	}
+	if res, _ := ix.Search(ctx, "sql connection pool", 5, "db.go"); len(res) != 1 || res[0].Path != "http.go" {
+		t.Fatalf("exclude not applied: %+v", res)
+	}
+	before := emb.texts
+	_ = ix.UpdateFile(ctx, "db.go", "func openDatabase connection pool sql")
+	if emb.texts != before {
+		t.Fatalf("unchanged file was embedded again")
+	}
+}
+
+func TestVectorIndex_PersistsPerModel(t *testing.T) {
+	store := StorePath(t.TempDir(), "/work")
+	ctx := context.Background()
+	ix := NewVectorIndex(&fakeEmbedder{model: "fake"}, store)
+	_ = ix.UpdateFile(ctx, "a.go", "package a")
+	if err := ix.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if n := NewVectorIndex(&fakeEmbedder{model: "fake"}, store).Len(); n != 1 {
+		t.Fatalf("expected stored chunk to load, got %d", n)
+	}
+	if n := NewVectorIndex(&fakeEmbedder{model: "other"}, store).Len(); n != 0 {
+		t.Fatalf("vectors of another model must not load, got %d", n)
+	}
+}
+
+func TestVectorIndex_BuildSkipsHiddenAndBinaryAndDropsDeleted(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel string, b []byte) {
+		p := filepath.Join(root, rel)
+		_ = os.MkdirAll(filepath.Dir(p), 0o755)
+		_ = os.WriteFile(p, b, 0o644)
+	}
+	write("main.go", []byte("package main"))
+	write(".git/config", []byte("secret"))
+	write("bin/tool", []byte{0x7f, 'E', 'L', 'F', 0})
+	ix := NewVectorIndex(&fakeEmbedder{model: "fake"}, "")
+	ix.files["gone.go"] = []vectorEntry{{}}
+	if err := ix.Build(context.Background(), root, nil); err != nil {
+		t.Fatal(err)
+	}
+	if ix.Len() != 1 || ix.files[filepath.Join(root, "main.go")] == nil {
+		t.Fatalf("unexpected index contents: %v", ix.files)
+	}
+}